@@ -0,0 +1,94 @@
+package awsauth
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/chuxorg/chux-parser/config"
+	"github.com/chuxorg/chux-parser/errors"
+)
+
+// New creates the AWS session shared by the AWS clients of a run. The
+// credentials are, in this order: static credentials, web identity,
+// the named profile and otherwise the SDK's default chain, which
+// covers environment variables and ECS task roles.
+func New(settings config.AWS) (*session.Session, error) {
+	options := session.Options{
+		Config: aws.Config{
+			Region: aws.String(settings.Region),
+		},
+		Profile:           settings.Profile,
+		SharedConfigState: session.SharedConfigEnable,
+	}
+	if settings.AccessKeyID != "" {
+		options.Config.Credentials = credentials.NewStaticCredentials(
+			settings.AccessKeyID,
+			settings.SecretAccessKey,
+			settings.SessionToken,
+		)
+	}
+
+	sess, err := session.NewSessionWithOptions(options)
+	if err != nil {
+		return nil, errors.NewChuxParserError("awsauth.New() failed to create AWS session", err)
+	}
+
+	if settings.AccessKeyID == "" && settings.WebIdentityTokenFile != "" {
+		creds := stscreds.NewWebIdentityCredentials(
+			sess,
+			settings.WebIdentityRoleARN,
+			settings.AssumeRole.SessionName,
+			settings.WebIdentityTokenFile,
+		)
+		sess = sess.Copy(&aws.Config{Credentials: creds})
+	}
+	return sess, nil
+}
+
+// WithRole returns a copy of sess that assumes role with the
+// credentials of sess, or sess itself when no role is configured.
+// It is meant for the crawl bucket, which may live in another account
+// than the queue and the secrets.
+func WithRole(sess *session.Session, role config.AssumeRole) *session.Session {
+	if role.RoleARN == "" {
+		return sess
+	}
+	creds := stscreds.NewCredentials(sess, role.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = role.SessionName
+		if role.ExternalID != "" {
+			p.ExternalID = aws.String(role.ExternalID)
+		}
+		if role.Duration > 0 {
+			p.Duration = role.Duration
+		}
+	})
+	return sess.Copy(&aws.Config{Credentials: creds})
+}
+
+// Describe names the credential source New and WithRole use for
+// settings, for logs and config check. It never includes secret values.
+func Describe(settings config.AWS) string {
+	var base string
+	switch {
+	case settings.AccessKeyID != "":
+		base = "static credentials"
+	case settings.WebIdentityTokenFile != "":
+		base = "web identity for " + settings.WebIdentityRoleARN
+	case settings.Profile != "":
+		base = "profile " + settings.Profile
+	default:
+		base = "default credential chain"
+	}
+
+	role := settings.AssumeRole
+	if role.RoleARN == "" {
+		return base
+	}
+	if role.ExternalID != "" {
+		return fmt.Sprintf("%s assuming %s with external id", base, role.RoleARN)
+	}
+	return fmt.Sprintf("%s assuming %s", base, role.RoleARN)
+}
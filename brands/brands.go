@@ -0,0 +1,171 @@
+package brands
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+const (
+	// PropertyModel is the additional property holding the model of a
+	// product.
+	PropertyModel = "model"
+	// PropertyVariant is the additional property holding the variant of
+	// a product.
+	PropertyVariant = "variant"
+)
+
+// separator splits the name of a product into the model and the parts
+// describing its variant, as in "Player Stratocaster - Buttercream" or
+// "Les Paul Standard (Left-Handed)".
+var separator = regexp.MustCompile(`\s+[-–—|/]\s+|,\s*|\s*\(|\)(?:\s+[-–—|/])?\s*`)
+
+// Title is the name of a product split into its parts.
+type Title struct {
+	Brand   string
+	Model   string
+	Variant string
+	Color   string
+}
+
+// Split splits the name of a product into its brand, model, variant and
+// color. brand is the brand of the product when it is already known.
+func (d *Dictionary) Split(name, brand string) Title {
+	var segments [][]string
+	for _, part := range separator.Split(name, -1) {
+		if tokens := tokenize(part); len(tokens) > 0 {
+			segments = append(segments, tokens)
+		}
+	}
+	if len(segments) == 0 {
+		return Title{Brand: d.canonical(brand)}
+	}
+
+	var title Title
+	head := segments[0]
+	// Brands are only looked up at the start of the name, where retailers
+	// put them, so "Orange" is not taken from "Fender Strat Orange".
+	start, end, found := d.brands.find(head)
+	switch known := d.canonical(brand); {
+	case start == 0 && (known == "" || strings.EqualFold(known, found)):
+		title.Brand = found
+		head = head[end:]
+	default:
+		title.Brand = known
+		if n := len(tokenize(brand)); n > 0 && n <= len(head) && key(head[:n]) == key(tokenize(brand)) {
+			head = head[n:]
+		}
+	}
+
+	// Colors are looked up in the variant parts before the model, so
+	// "Telecaster - Black" does not lose a "Black" of its model. A
+	// variant part ending in a color is a color as a whole, as "Faded
+	// Blue" or "Charcoal Burst".
+	tail := segments[1:]
+	for i := range tail {
+		if start, color := d.colors.suffix(tail[i]); start >= 0 {
+			if start > 0 {
+				color = strings.Join(tail[i], " ")
+			}
+			title.Color = color
+			tail[i] = nil
+			break
+		}
+	}
+	if title.Color == "" {
+		if start, color := d.colors.suffix(head); start > 0 {
+			title.Color = color
+			head = head[:start]
+		}
+	}
+
+	var variants []string
+	for {
+		start, end, variant := d.variants.find(head)
+		if start < 0 {
+			break
+		}
+		variants = append(variants, variant)
+		head = cut(head, start, end)
+	}
+	for _, segment := range tail {
+		if len(segment) > 0 {
+			variants = append(variants, strings.Join(segment, " "))
+		}
+	}
+	title.Model = strings.Join(head, " ")
+	title.Variant = strings.Join(variants, ", ")
+	return title
+}
+
+// canonical returns the name of a brand in the Dictionary, or brand
+// itself when it is not known.
+func (d *Dictionary) canonical(brand string) string {
+	if known := d.Brand(brand); known != "" {
+		return known
+	}
+	return strings.TrimSpace(brand)
+}
+
+// cut returns tokens without tokens[start:end].
+func cut(tokens []string, start, end int) []string {
+	return append(append([]string{}, tokens[:start]...), tokens[end:]...)
+}
+
+// Extractor is a pipeline stage setting the brand and color of products
+// and their model and variant as additional properties, split from the
+// name of the product. Fields already set are kept, except for the
+// brand which is always set as the name of the brand, replacing
+// aliases and schema.org Brand objects.
+type Extractor struct {
+	Dictionary *Dictionary
+}
+
+// New returns a new Extractor of the brands, colors and variants of a
+// Dictionary.
+func New(dictionary *Dictionary) *Extractor {
+	return &Extractor{Dictionary: dictionary}
+}
+
+func (e *Extractor) Name() string {
+	return "brands"
+}
+
+func (e *Extractor) Process(record *pipeline.Record) error {
+	if !record.IsProduct {
+		return nil
+	}
+	name, _ := record.Fields["name"].(string)
+	brand := brandOf(record.Fields["brand"])
+	title := e.Dictionary.Split(name, brand)
+	if title.Brand == "" {
+		record.Report("brand", "no known brand in %q", name)
+	} else {
+		record.Fields["brand"] = title.Brand
+	}
+	if color, _ := record.Fields["color"].(string); strings.TrimSpace(color) == "" && title.Color != "" {
+		record.Fields["color"] = title.Color
+	}
+	// Without a brand the model would start with the unknown brand.
+	if title.Brand != "" && title.Model != "" && record.Property(PropertyModel) == "" {
+		record.SetProperty(PropertyModel, title.Model)
+	}
+	if title.Variant != "" && record.Property(PropertyVariant) == "" {
+		record.SetProperty(PropertyVariant, title.Variant)
+	}
+	return nil
+}
+
+// brandOf returns the brand of a product, given as a string or as a
+// schema.org Brand with a name.
+func brandOf(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		name, _ := v["name"].(string)
+		return name
+	}
+	return ""
+}
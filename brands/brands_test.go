@@ -0,0 +1,84 @@
+package brands
+
+import (
+	"testing"
+
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+func dictionary() *Dictionary {
+	d := &Dictionary{Brands: map[string][]string{
+		"Fender":      {"Fender Musical Instruments"},
+		"Squier":      {"Squier by Fender"},
+		"Gibson":      nil,
+		"C.F. Martin": {"Martin"},
+		"PRS":         {"Paul Reed Smith"},
+		"Orange":      nil,
+	}}
+	d.compile()
+	return d
+}
+
+func TestSplit(t *testing.T) {
+	d := dictionary()
+	tests := []struct {
+		name  string
+		brand string
+		want  Title
+	}{
+		{"Fender Player Stratocaster HSS - Buttercream", "", Title{"Fender", "Player Stratocaster", "HSS", "Buttercream"}},
+		{"Squier by Fender Classic Vibe '50s Telecaster, Maple Fingerboard, Butterscotch Blonde", "", Title{"Squier", "Classic Vibe '50s Telecaster", "Maple Fingerboard", "Butterscotch Blonde"}},
+		{"Gibson Les Paul Standard '60s (Left-Handed) - Iced Tea", "", Title{"Gibson", "Les Paul Standard '60s", "Left-Handed, Iced Tea", ""}},
+		{"Les Paul Standard 50s Heritage Cherry Sunburst", "Gibson", Title{"Gibson", "Les Paul Standard 50s", "", "Heritage Cherry Sunburst"}},
+		{"Paul Reed Smith SE Custom 24 - Faded Blue", "", Title{"PRS", "SE Custom 24", "", "Faded Blue"}},
+		{"CF Martin D-28 Dreadnought Acoustic Guitar - Natural", "", Title{"C.F. Martin", "D-28 Dreadnought Acoustic Guitar", "", "Natural"}},
+		{"Fender American Professional II Telecaster Black", "", Title{"Fender", "American Professional II Telecaster", "", "Black"}},
+		{"Orange Rockerverb 50 MkIII Head", "", Title{"Orange", "Rockerverb 50 MkIII Head", "", ""}},
+		{"Fender Strat Orange", "", Title{"Fender", "Strat", "", "Orange"}},
+		{"Player Stratocaster", "Fender Musical Instruments", Title{"Fender", "Player Stratocaster", "", ""}},
+		{"Ibanez RG550 - Road Flare Red", "Ibanez", Title{"Ibanez", "RG550", "", "Road Flare Red"}},
+		{"Ibanez RG550 - Road Flare Red", "", Title{"", "Ibanez RG550", "", "Road Flare Red"}},
+		{"Fender Telecaster | Black | Maple", "Gibson", Title{"Gibson", "Fender Telecaster", "Maple", "Black"}},
+		{"", "Fender Musical Instruments", Title{Brand: "Fender"}},
+	}
+	for _, tt := range tests {
+		if got := d.Split(tt.name, tt.brand); got != tt.want {
+			t.Errorf("Split(%q, %q) = %+v, want %+v", tt.name, tt.brand, got, tt.want)
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		brand interface{}
+		want  interface{}
+	}{
+		{nil, "Fender"},
+		{"Fender", "Fender"},
+		{"Fender Musical Instruments", "Fender"},
+		{map[string]interface{}{"@type": "Brand", "name": "Fender"}, "Fender"},
+		{map[string]interface{}{"@type": "Brand", "name": "Fender Musical Instruments"}, "Fender"},
+	}
+	e := New(dictionary())
+	for _, tt := range tests {
+		record := &pipeline.Record{IsProduct: true, Fields: map[string]interface{}{
+			"name":  "Fender Player Stratocaster HSS - Buttercream",
+			"brand": tt.brand,
+		}}
+		if err := e.Process(record); err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+		if got := record.Fields["brand"]; got != tt.want {
+			t.Errorf("brand %v = %v, want %v", tt.brand, got, tt.want)
+		}
+		if got := record.Fields["color"]; got != "Buttercream" {
+			t.Errorf("color = %v, want Buttercream", got)
+		}
+		if got := record.Property(PropertyModel); got != "Player Stratocaster" {
+			t.Errorf("%s = %q, want %q", PropertyModel, got, "Player Stratocaster")
+		}
+		if got := record.Property(PropertyVariant); got != "HSS" {
+			t.Errorf("%s = %q, want %q", PropertyVariant, got, "HSS")
+		}
+	}
+}
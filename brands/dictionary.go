@@ -0,0 +1,174 @@
+package brands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultColors are the finishes recognised in addition to the colors
+// of a Dictionary.
+var DefaultColors = []string{
+	"Black", "White", "Red", "Blue", "Green", "Yellow", "Orange", "Purple", "Pink", "Gold", "Silver",
+	"Natural", "Sunburst", "3-Color Sunburst", "3-Tone Sunburst", "2-Color Sunburst", "Tobacco Sunburst",
+	"Cherry Sunburst", "Honey Burst", "Cherry", "Butterscotch Blonde", "Blonde", "Olympic White",
+	"Arctic White", "Vintage White", "Polar White", "Candy Apple Red", "Fiesta Red", "Lake Placid Blue",
+	"Sonic Blue", "Daphne Blue", "Surf Green", "Sea Foam Green", "Shell Pink", "Buttercream",
+	"Tidepool", "Black Gold", "Dark Night", "Mystic Surf Green", "Ebony", "Vintage Sunburst",
+	"Heritage Cherry Sunburst", "Wine Red", "Pelham Blue", "TV Yellow", "Goldtop", "Alpine White",
+	"Satin Black", "Gloss Black", "Matte Black", "Charcoal Frost Metallic", "Sienna Sunburst",
+	"Burst", "Metallic", "Sparkle", "Flame",
+}
+
+// DefaultVariants are the configurations recognised in addition to
+// the variants of a Dictionary.
+var DefaultVariants = []string{
+	"HSS", "HH", "SSS", "HSH", "P90", "Floyd Rose", "Bigsby", "Left-Handed", "Left Handed", "Lefty",
+	"12-String", "7-String", "8-String", "5-String", "Fretless", "Limited Edition", "FSR",
+	"Relic", "Journeyman Relic", "Heavy Relic", "NOS", "Closet Classic",
+	"Maple Fingerboard", "Rosewood Fingerboard", "Ebony Fingerboard", "Pau Ferro Fingerboard",
+	"Laurel Fingerboard",
+}
+
+// Dictionary holds the brands, colors and variants recognised in the
+// names of products, read from a YAML file:
+//
+//	brands:
+//	  Fender: [Fender Musical Instruments]
+//	  Squier: [Squier by Fender]
+//	colors: [Buttercream]
+//	variants: [HSS]
+//
+// Brands map their name to their aliases.
+type Dictionary struct {
+	Brands   map[string][]string `yaml:"brands"`
+	Colors   []string            `yaml:"colors"`
+	Variants []string            `yaml:"variants"`
+
+	brands   *matcher
+	colors   *matcher
+	variants *matcher
+}
+
+// Load reads the Dictionary of a YAML file.
+func Load(path string) (*Dictionary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("brands.Load() Error reading %s: %v", path, err), err)
+	}
+	var d Dictionary
+	if err := yaml.Unmarshal(data, &d); err != nil {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("brands.Load() Error parsing %s: %v", path, err), err)
+	}
+	if len(d.Brands) == 0 {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("brands.Load() %s holds no brands", path), nil)
+	}
+	d.compile()
+	return &d, nil
+}
+
+// compile builds the matchers of the Dictionary.
+func (d *Dictionary) compile() {
+	d.brands = newMatcher()
+	for name, aliases := range d.Brands {
+		d.brands.add(name, name)
+		for _, alias := range aliases {
+			d.brands.add(alias, name)
+		}
+	}
+	d.colors = newMatcher()
+	for _, color := range append(append([]string{}, DefaultColors...), d.Colors...) {
+		d.colors.add(color, color)
+	}
+	d.variants = newMatcher()
+	for _, variant := range append(append([]string{}, DefaultVariants...), d.Variants...) {
+		d.variants.add(variant, variant)
+	}
+}
+
+// Brand returns the brand name or alias as the name in the Dictionary,
+// an empty string when it is not known.
+func (d *Dictionary) Brand(name string) string {
+	tokens := tokenize(name)
+	if start, end, canonical := d.brands.find(tokens); start == 0 && end == len(tokens) {
+		return canonical
+	}
+	return ""
+}
+
+// matcher finds phrases of whole words, ignoring case.
+type matcher struct {
+	phrases  map[string]string
+	maxWords int
+}
+
+func newMatcher() *matcher {
+	return &matcher{phrases: map[string]string{}}
+}
+
+// add makes the matcher find phrase as canonical.
+func (m *matcher) add(phrase, canonical string) {
+	tokens := tokenize(phrase)
+	if len(tokens) == 0 {
+		return
+	}
+	m.phrases[key(tokens)] = canonical
+	if len(tokens) > m.maxWords {
+		m.maxWords = len(tokens)
+	}
+}
+
+// find returns the leftmost longest phrase in tokens, as the range of
+// its tokens and its canonical form. start is -1 when there is none.
+func (m *matcher) find(tokens []string) (start, end int, canonical string) {
+	for start = 0; start < len(tokens); start++ {
+		for n := m.maxWords; n > 0; n-- {
+			if start+n > len(tokens) {
+				continue
+			}
+			if canonical, ok := m.phrases[key(tokens[start:start+n])]; ok {
+				return start, start + n, canonical
+			}
+		}
+	}
+	return -1, -1, ""
+}
+
+// suffix returns the longest phrase ending tokens, as the index of its
+// first token and its canonical form. start is -1 when there is none.
+func (m *matcher) suffix(tokens []string) (start int, canonical string) {
+	for start = 0; start < len(tokens); start++ {
+		if len(tokens)-start > m.maxWords {
+			continue
+		}
+		if canonical, ok := m.phrases[key(tokens[start:])]; ok {
+			return start, canonical
+		}
+	}
+	return -1, ""
+}
+
+// tokenize splits text into words, without the punctuation separating
+// them.
+func tokenize(text string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(text) {
+		if token := strings.Trim(field, ",;:!?\"()[]"); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// key returns the phrase of tokens as matched, in lower case and without
+// quotes and periods, so "C.F. Martin" matches "CF Martin".
+func key(tokens []string) string {
+	words := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		words = append(words, strings.NewReplacer("'", "", "’", "", ".", "").Replace(strings.ToLower(token)))
+	}
+	return strings.Join(words, " ")
+}
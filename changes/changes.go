@@ -0,0 +1,254 @@
+// Package changes compares each product with the version last saved
+// from the same url, keeps a history of the changes of the tracked
+// fields, such as prices, and publishes them as Events.
+package changes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chuxorg/chux-parser/dedup"
+	"github.com/chuxorg/chux-parser/logging"
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+// DefaultFields are the fields tracked without WithFields, as dot
+// paths in which * stands for every element of an array.
+var DefaultFields = []string{"name", "offers.*.price", "offers.*.currency", "offers.*.availability"}
+
+// Version holds the tracked fields of a product as last saved.
+type Version struct {
+	// Key identifies the product, its company and canonical url.
+	Key     string            `bson:"_id" json:"key"`
+	Company string            `bson:"company" json:"company"`
+	URL     string            `bson:"url" json:"url"`
+	Fields  map[string]string `bson:"fields" json:"fields"`
+	// Source is the object key of the record the Version was saved
+	// from.
+	Source  string    `bson:"source" json:"source"`
+	Updated time.Time `bson:"updated" json:"updated"`
+	// Number counts the Versions of the product, from 1 for the first.
+	Number int `bson:"number" json:"number"`
+}
+
+// Change is a tracked field of a product that changed, or was first
+// seen when Old is empty. Version is the Number of the Version it
+// changed into.
+type Change struct {
+	Key     string    `bson:"key" json:"key"`
+	Version int       `bson:"version" json:"version"`
+	Field   string    `bson:"field" json:"field"`
+	Old     string    `bson:"old,omitempty" json:"old,omitempty"`
+	New     string    `bson:"new,omitempty" json:"new,omitempty"`
+	Time    time.Time `bson:"time" json:"time"`
+	Source  string    `bson:"source" json:"source"`
+}
+
+// Tracker is a pipeline.Stage comparing the tracked fields of each
+// product with its last Version in the Store. When the product was
+// saved, the new Version and the Changes are stored and an Event is
+// published.
+type Tracker struct {
+	Store     Store
+	Publisher Publisher
+	// Fields are the tracked fields, see DefaultFields.
+	Fields []string
+	Logger *logging.Logger
+}
+
+// New returns a new Tracker
+func New(options ...func(*Tracker)) *Tracker {
+
+	t := &Tracker{
+		Store:  NewMemoryStore(),
+		Fields: DefaultFields,
+	}
+	for _, option := range options {
+		option(t)
+	}
+	return t
+}
+
+func WithStore(store Store) func(*Tracker) {
+	return func(t *Tracker) {
+		t.Store = store
+	}
+}
+
+func WithPublisher(publisher Publisher) func(*Tracker) {
+	return func(t *Tracker) {
+		t.Publisher = publisher
+	}
+}
+
+func WithFields(fields ...string) func(*Tracker) {
+	return func(t *Tracker) {
+		t.Fields = fields
+	}
+}
+
+func WithLogger(logger *logging.Logger) func(*Tracker) {
+	return func(t *Tracker) {
+		t.Logger = logger
+	}
+}
+
+func (t *Tracker) Name() string {
+	return "changes"
+}
+
+// pending is what Process keeps of a product for Commit.
+type pending struct {
+	version Version
+	changes []Change
+	isNew   bool
+}
+
+// Process compares the product with its last Version. Products
+// without a url are not tracked, the Store failing is reported.
+func (t *Tracker) Process(record *pipeline.Record) error {
+	if !record.IsProduct {
+		return nil
+	}
+	url := dedup.CanonicalURL(record.Fields)
+	if url == "" {
+		return nil
+	}
+	key := record.Company + ":" + url
+	last, err := t.Store.Last(context.Background(), key)
+	if err != nil {
+		record.Report("url", "%v", err)
+		return nil
+	}
+
+	p := &pending{
+		version: Version{Key: key, Company: record.Company, URL: url, Number: 1, Fields: Snapshot(record.Fields, t.Fields), Source: record.Key},
+		isNew:   last == nil,
+	}
+	var old map[string]string
+	if last != nil {
+		old = last.Fields
+		p.version.Number = last.Number + 1
+	}
+	for _, field := range Diff(old, p.version.Fields) {
+		p.changes = append(p.changes, Change{Key: key, Version: p.version.Number, Field: field, Old: old[field], New: p.version.Fields[field], Source: record.Key})
+	}
+	if !p.isNew && len(p.changes) == 0 {
+		return nil
+	}
+	if record.State == nil {
+		record.State = map[string]interface{}{}
+	}
+	record.State[t.Name()] = p
+	return nil
+}
+
+// Commit stores the Version and the Changes of a saved product and
+// publishes its Event.
+func (t *Tracker) Commit(record *pipeline.Record) error {
+	p, ok := record.State[t.Name()].(*pending)
+	if !ok {
+		return nil
+	}
+	now := time.Now().UTC()
+	p.version.Updated = now
+	for i := range p.changes {
+		p.changes[i].Time = now
+	}
+	ctx := context.Background()
+	if err := t.Store.Save(ctx, p.version, p.changes); err != nil {
+		return err
+	}
+	if t.Publisher == nil {
+		return nil
+	}
+
+	event := Event{
+		Type:    EventChanged,
+		Key:     p.version.Key,
+		Company: p.version.Company,
+		URL:     p.version.URL,
+		Source:  p.version.Source,
+		Time:    now,
+		Changes: p.changes,
+	}
+	if p.isNew {
+		event.Type = EventNew
+	}
+	event.ProductID = record.Property(dedup.PropertyProductID)
+	if err := t.Publisher.Publish(ctx, event); err != nil {
+		return err
+	}
+	t.Logger.Debug("changes.Commit() Published %s event of %s with %d changes", event.Type, event.Key, len(event.Changes))
+	return nil
+}
+
+// Snapshot returns the values of the tracked fields of a record as
+// strings by their path, with the indices of arrays in place of *,
+// e.g. offers.0.price.
+func Snapshot(fields map[string]interface{}, tracked []string) map[string]string {
+	snapshot := map[string]string{}
+	for _, path := range tracked {
+		collect(fields, strings.Split(path, "."), "", snapshot)
+	}
+	return snapshot
+}
+
+func collect(value interface{}, segments []string, prefix string, snapshot map[string]string) {
+	if len(segments) == 0 {
+		if text := toString(value); text != "" {
+			snapshot[strings.TrimPrefix(prefix, ".")] = text
+		}
+		return
+	}
+	segment := segments[0]
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if child, ok := v[segment]; ok {
+			collect(child, segments[1:], prefix+"."+segment, snapshot)
+		}
+	case []interface{}:
+		for i, child := range v {
+			if segment == "*" || segment == fmt.Sprint(i) {
+				collect(child, segments[1:], fmt.Sprintf("%s.%d", prefix, i), snapshot)
+			}
+		}
+	}
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+// Diff returns the sorted paths whose values differ between old and
+// current, including those only one of them holds.
+func Diff(old, current map[string]string) []string {
+	var paths []string
+	for path, value := range current {
+		if previous, ok := old[path]; !ok || previous != value {
+			paths = append(paths, path)
+		}
+	}
+	for path := range old {
+		if _, ok := current[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,184 @@
+package changes
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/chuxorg/chux-parser/dedup"
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		old     map[string]string
+		current map[string]string
+		want    []string
+	}{
+		{nil, nil, nil},
+		{nil, map[string]string{"name": "Strat", "offers.0.price": "849.99"}, []string{"name", "offers.0.price"}},
+		{map[string]string{"name": "Strat"}, map[string]string{"name": "Strat"}, nil},
+		{map[string]string{"name": "Strat", "offers.0.price": "849.99"}, map[string]string{"name": "Strat", "offers.0.price": "799.99"}, []string{"offers.0.price"}},
+		{map[string]string{"offers.0.price": "849.99", "offers.1.price": "899.99"}, map[string]string{"offers.0.price": "849.99"}, []string{"offers.1.price"}},
+		{map[string]string{"offers.0.availability": "InStock"}, map[string]string{"offers.0.availability": "OutOfStock", "name": "Strat"}, []string{"name", "offers.0.availability"}},
+	}
+	for _, tt := range tests {
+		if got := Diff(tt.old, tt.current); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Diff(%v, %v) = %v, want %v", tt.old, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	fields := map[string]interface{}{
+		"name": "Fender Player Stratocaster",
+		"offers": []interface{}{
+			map[string]interface{}{"price": json.Number("849.99"), "currency": "USD", "availability": "InStock"},
+			map[string]interface{}{"price": "799.99"},
+		},
+		"brand": "Fender",
+	}
+	want := map[string]string{
+		"name":                  "Fender Player Stratocaster",
+		"offers.0.price":        "849.99",
+		"offers.0.currency":     "USD",
+		"offers.0.availability": "InStock",
+		"offers.1.price":        "799.99",
+	}
+	got := Snapshot(fields, DefaultFields)
+	if len(got) != len(want) {
+		t.Fatalf("Snapshot() = %v, want %v", got, want)
+	}
+	for path, value := range want {
+		if got[path] != value {
+			t.Errorf("Snapshot()[%s] = %q, want %q", path, got[path], value)
+		}
+	}
+}
+
+const url = "https://www.sweetwater.com/store/detail/StratPlayer"
+
+func strat(source, price, availability string) *pipeline.Record {
+	return &pipeline.Record{Key: source, Company: "sweetwater", IsProduct: true, Fields: map[string]interface{}{
+		"url":  url,
+		"name": "Fender Player Stratocaster",
+		"offers": []interface{}{
+			map[string]interface{}{"price": price, "currency": "USD", "availability": availability},
+		},
+		"crawledAt": source,
+	}}
+}
+
+// save processes record like the Parser, committing it when it was not
+// skipped.
+func save(t *testing.T, tracker *Tracker, record *pipeline.Record) {
+	t.Helper()
+	if err := tracker.Process(record); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if err := tracker.Commit(record); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	store := NewMemoryStore()
+	bus := NewBus()
+	var events []Event
+	bus.Subscribe(func(event Event) { events = append(events, event) })
+	tracker := New(WithStore(store), WithPublisher(bus))
+	key := "sweetwater:" + dedup.CanonicalURL(map[string]interface{}{"url": url})
+
+	tests := []struct {
+		name    string
+		record  *pipeline.Record
+		event   string
+		changes []string
+		version int
+	}{
+		{"first seen", strat("day1.jl", "849.99", "InStock"), EventNew, []string{"name", "offers.0.availability", "offers.0.currency", "offers.0.price"}, 1},
+		{"unchanged", strat("day2.jl", "849.99", "InStock"), "", nil, 1},
+		{"price changed", strat("day3.jl", "799.99", "InStock"), EventChanged, []string{"offers.0.price"}, 2},
+		{"availability changed", strat("day4.jl", "799.99", "OutOfStock"), EventChanged, []string{"offers.0.availability"}, 3},
+		{"unchanged again", strat("day5.jl", "799.99", "OutOfStock"), "", nil, 3},
+	}
+	for _, tt := range tests {
+		events = nil
+		save(t, tracker, tt.record)
+		last, err := store.Last(nil, key)
+		if err != nil || last == nil {
+			t.Fatalf("%s: Last() = %v, %v", tt.name, last, err)
+		}
+		if last.Number != tt.version {
+			t.Errorf("%s: version %d, want %d", tt.name, last.Number, tt.version)
+		}
+		if tt.event == "" {
+			if len(events) != 0 {
+				t.Errorf("%s: published %v, want nothing", tt.name, events)
+			}
+			if last.Source == tt.record.Key {
+				t.Errorf("%s: unchanged product saved as a new version", tt.name)
+			}
+			continue
+		}
+		if last.Source != tt.record.Key {
+			t.Errorf("%s: version saved from %s, want %s", tt.name, last.Source, tt.record.Key)
+		}
+		if len(events) != 1 || events[0].Type != tt.event || events[0].Key != key || events[0].Source != tt.record.Key {
+			t.Fatalf("%s: published %+v, want one %s event of %s", tt.name, events, tt.event, key)
+		}
+		var fields []string
+		for _, change := range events[0].Changes {
+			fields = append(fields, change.Field)
+			if change.Version != tt.version || change.Time.IsZero() {
+				t.Errorf("%s: change %+v, want version %d with a time", tt.name, change, tt.version)
+			}
+		}
+		if strings.Join(fields, ",") != strings.Join(tt.changes, ",") {
+			t.Errorf("%s: changed %v, want %v", tt.name, fields, tt.changes)
+		}
+	}
+
+	history := store.History(key)
+	if len(history) != 6 {
+		t.Fatalf("history %+v, want 6 changes", history)
+	}
+	price := history[4]
+	if price.Field != "offers.0.price" || price.Old != "849.99" || price.New != "799.99" || price.Source != "day3.jl" {
+		t.Errorf("price change %+v, want 849.99 to 799.99 from day3.jl", price)
+	}
+}
+
+// A product is only stored once it was committed, so a product whose
+// model failed to save is compared again with the older version.
+func TestTrackerNotCommitted(t *testing.T) {
+	store := NewMemoryStore()
+	tracker := New(WithStore(store))
+	save(t, tracker, strat("day1.jl", "849.99", "InStock"))
+
+	changed := strat("day2.jl", "799.99", "InStock")
+	if err := tracker.Process(changed); err != nil {
+		t.Fatal(err)
+	}
+	again := strat("day3.jl", "799.99", "InStock")
+	save(t, tracker, again)
+	key := "sweetwater:" + dedup.CanonicalURL(map[string]interface{}{"url": url})
+	if last, _ := store.Last(nil, key); last == nil || last.Number != 2 || last.Source != "day3.jl" {
+		t.Errorf("Last() = %+v, want version 2 from day3.jl", last)
+	}
+}
+
+func TestTrackerIgnores(t *testing.T) {
+	store := NewMemoryStore()
+	tracker := New(WithStore(store))
+	article := strat("day1.jl", "849.99", "InStock")
+	article.IsProduct = false
+	withoutURL := strat("day1.jl", "849.99", "InStock")
+	delete(withoutURL.Fields, "url")
+	for _, record := range []*pipeline.Record{article, withoutURL} {
+		save(t, tracker, record)
+		if len(store.versions) != 0 {
+			t.Errorf("%v tracked", record.Fields)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package changes
+
+import (
+	"context"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The default MongoDB collections of the Versions and the history.
+const (
+	DefaultVersions = "versions"
+	DefaultHistory  = "changes"
+)
+
+// MongoStore is a Store keeping the Versions and the history in two
+// MongoDB collections.
+type MongoStore struct {
+	Client   *mongodb.Client
+	Versions string
+	History  string
+}
+
+// NewMongoStore returns a MongoStore of the collections in the
+// database of client.
+func NewMongoStore(client *mongodb.Client, versions, history string) *MongoStore {
+	if versions == "" {
+		versions = DefaultVersions
+	}
+	if history == "" {
+		history = DefaultHistory
+	}
+	return &MongoStore{Client: client, Versions: versions, History: history}
+}
+
+func (s *MongoStore) Last(ctx context.Context, key string) (*Version, error) {
+	ctx, cancel := s.Client.Context(ctx)
+	defer cancel()
+	versions, err := s.Client.Collection(ctx, s.Versions)
+	if err != nil {
+		return nil, err
+	}
+	var version Version
+	err = versions.FindOne(ctx, bson.M{"_id": key}).Decode(&version)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.NewChuxParserError("changes.MongoStore.Last() Error finding "+key, err)
+	}
+	return &version, nil
+}
+
+func (s *MongoStore) Save(ctx context.Context, version Version, changes []Change) error {
+	ctx, cancel := s.Client.Context(ctx)
+	defer cancel()
+	versions, err := s.Client.Collection(ctx, s.Versions)
+	if err != nil {
+		return err
+	}
+	history, err := s.Client.Collection(ctx, s.History)
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		documents := make([]interface{}, len(changes))
+		for i, change := range changes {
+			documents[i] = change
+		}
+		if _, err := history.InsertMany(ctx, documents); err != nil {
+			return errors.NewChuxParserError("changes.MongoStore.Save() Error saving the changes of "+version.Key, err)
+		}
+	}
+	_, err = versions.ReplaceOne(ctx, bson.M{"_id": version.Key}, version, options.Replace().SetUpsert(true))
+	if err != nil {
+		return errors.NewChuxParserError("changes.MongoStore.Save() Error saving "+version.Key, err)
+	}
+	return nil
+}
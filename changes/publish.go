@@ -0,0 +1,103 @@
+package changes
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/chuxorg/chux-parser/errors"
+)
+
+// The types of Events.
+const (
+	// EventNew is published for a product seen for the first time, its
+	// Changes hold the initial values.
+	EventNew = "new"
+	// EventChanged is published for a product whose tracked fields
+	// changed.
+	EventChanged = "changed"
+)
+
+// Event tells the subscribers of a Publisher about a new or changed
+// product.
+type Event struct {
+	Type    string `json:"type"`
+	Key     string `json:"key"`
+	Company string `json:"company"`
+	URL     string `json:"url"`
+	// ProductID is the canonical product ID, when products are
+	// deduplicated.
+	ProductID string    `json:"productId,omitempty"`
+	Source    string    `json:"source"`
+	Time      time.Time `json:"time"`
+	Changes   []Change  `json:"changes"`
+}
+
+// Publisher delivers Events to their subscribers. SNSPublisher
+// publishes them to an Amazon SNS topic, Bus to functions in the
+// process.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Bus is a Publisher calling the functions subscribed to it.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers []func(Event)
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds fn to the functions called with each Event.
+func (b *Bus) Subscribe(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, fn)
+}
+
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	b.mu.Lock()
+	subscribers := append([]func(Event){}, b.subscribers...)
+	b.mu.Unlock()
+	for _, fn := range subscribers {
+		fn(event)
+	}
+	return nil
+}
+
+// SNSPublisher publishes Events as JSON to an Amazon SNS topic. The
+// type and the company of an Event are message attributes, so
+// subscriptions can filter on them.
+type SNSPublisher struct {
+	TopicARN string
+	svc      *sns.SNS
+}
+
+func NewSNSPublisher(sess *session.Session, topicARN string) *SNSPublisher {
+	return &SNSPublisher{TopicARN: topicARN, svc: sns.New(sess)}
+}
+
+func (p *SNSPublisher) Publish(ctx context.Context, event Event) error {
+	message, err := json.Marshal(event)
+	if err != nil {
+		return errors.NewChuxParserError("SNSPublisher.Publish() Error encoding event of "+event.Key, err)
+	}
+	_, err = p.svc.PublishWithContext(ctx, &sns.PublishInput{
+		TopicArn: aws.String(p.TopicARN),
+		Message:  aws.String(string(message)),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			"type":    {DataType: aws.String("String"), StringValue: aws.String(event.Type)},
+			"company": {DataType: aws.String("String"), StringValue: aws.String(event.Company)},
+		},
+	})
+	if err != nil {
+		return errors.NewChuxParserError("SNSPublisher.Publish() Error publishing event of "+event.Key, err)
+	}
+	return nil
+}
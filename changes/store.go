@@ -0,0 +1,59 @@
+package changes
+
+import (
+	"context"
+	"sync"
+)
+
+// Store keeps the last Version of each product and the history of
+// their Changes. MongoStore keeps them across runs, MemoryStore
+// within a run.
+type Store interface {
+	// Last returns the Version of key, nil when there is none.
+	Last(ctx context.Context, key string) (*Version, error)
+	// Save replaces the Version of its Key and adds the changes to the
+	// history.
+	Save(ctx context.Context, version Version, changes []Change) error
+}
+
+// MemoryStore is an in-process Store.
+type MemoryStore struct {
+	mu       sync.Mutex
+	versions map[string]Version
+	history  []Change
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{versions: map[string]Version{}}
+}
+
+func (s *MemoryStore) Last(ctx context.Context, key string) (*Version, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	version, ok := s.versions[key]
+	if !ok {
+		return nil, nil
+	}
+	return &version, nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, version Version, changes []Change) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[version.Key] = version
+	s.history = append(s.history, changes...)
+	return nil
+}
+
+// History returns the Changes of key in the order they were saved.
+func (s *MemoryStore) History(key string) []Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var changes []Change
+	for _, change := range s.history {
+		if change.Key == key {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,235 @@
+// Package charset detects the text encoding of crawl files and
+// transcodes them to UTF-8, which the record decoders expect.
+package charset
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// headerSize is the number of bytes Detect is given by NewReader.
+const headerSize = 64 * 1024
+
+// The names of the encodings Detect returns without a hint.
+const (
+	UTF8    = "utf-8"
+	UTF16LE = "utf-16le"
+	UTF16BE = "utf-16be"
+	// Windows1252 is read for content that is not UTF-8. It is a
+	// superset of the printable characters of ISO-8859-1 (Latin-1) and
+	// what browsers use for both.
+	Windows1252 = "windows-1252"
+)
+
+// boms maps the byte order marks to the encoding they introduce.
+var boms = []struct {
+	bom      []byte
+	encoding string
+}{
+	{[]byte{0xef, 0xbb, 0xbf}, UTF8},
+	{[]byte{0xff, 0xfe}, UTF16LE},
+	{[]byte{0xfe, 0xff}, UTF16BE},
+}
+
+// FromContentType returns the charset parameter of a Content-Type,
+// e.g. iso-8859-1 for text/plain; charset=ISO-8859-1.
+func FromContentType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(params["charset"])
+}
+
+// Lookup returns the canonical name of a charset label such as latin1
+// or ISO-8859-1, or an empty string for labels that are not known.
+func Lookup(label string) string {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return ""
+	}
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return ""
+	}
+	name, err := htmlindex.Name(enc)
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// Detect determines the encoding of content starting with header. A
+// byte order mark decides, then the content itself: a header holding
+// more valid multi-byte UTF-8 sequences than invalid ones is UTF-8
+// whatever the hint says, since such sequences rarely occur in other
+// encodings by chance. Otherwise the hint, a charset label, is
+// followed. Plain ASCII without a hint is UTF-8 and invalid UTF-8
+// without a hint is read as Windows1252.
+func Detect(header []byte, hint string) string {
+	for _, b := range boms {
+		if bytes.HasPrefix(header, b.bom) {
+			return b.encoding
+		}
+	}
+
+	multiByte, invalid := countRunes(trimPartialRune(header))
+	if multiByte > invalid {
+		return UTF8
+	}
+	if hinted := Lookup(hint); hinted != "" {
+		return hinted
+	}
+	if invalid == 0 {
+		return UTF8
+	}
+	return Windows1252
+}
+
+// countRunes counts the valid multi-byte UTF-8 sequences of p and the
+// bytes that are not part of a valid sequence.
+func countRunes(p []byte) (multiByte, invalid int) {
+	for len(p) > 0 {
+		if p[0] < utf8.RuneSelf {
+			p = p[1:]
+			continue
+		}
+		r, size := utf8.DecodeRune(p)
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+		} else {
+			multiByte++
+		}
+		p = p[size:]
+	}
+	return multiByte, invalid
+}
+
+// trimPartialRune removes a multi-byte sequence cut off at the end of
+// header.
+func trimPartialRune(header []byte) []byte {
+	for i := len(header) - 1; i >= 0 && i >= len(header)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(header[i]) {
+			if !utf8.FullRune(header[i:]) {
+				return header[:i]
+			}
+			break
+		}
+	}
+	return header
+}
+
+// Reader reads content transcoded to UTF-8.
+type Reader struct {
+	io.Reader
+	encoding string
+	counter  *validator
+}
+
+// Encoding returns the name of the encoding the content was read in.
+func (r *Reader) Encoding() string {
+	return r.encoding
+}
+
+// Invalid returns the number of invalid sequences read so far. Each
+// was replaced by U+FFFD.
+func (r *Reader) Invalid() int {
+	return r.counter.invalid
+}
+
+// NewReader returns a Reader of the content of r transcoded to UTF-8.
+// The encoding is determined with Detect from the first 64 KiB of the
+// content only, a byte order mark is removed. Invalid sequences do not
+// fail the Reader, they are replaced and counted: content that is
+// plain ASCII in its first 64 KiB and Windows-1252 further on is read
+// as UTF-8 with its accented characters replaced, see Invalid. An
+// error reading r is returned unchanged.
+func NewReader(r io.Reader, name, hint string) (*Reader, error) {
+	buffered := bufio.NewReaderSize(r, headerSize)
+	header, err := buffered.Peek(headerSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	detected := Detect(header, hint)
+	for _, b := range boms {
+		if bytes.HasPrefix(header, b.bom) {
+			buffered.Discard(len(b.bom))
+			break
+		}
+	}
+
+	if detected == UTF8 {
+		counter := &validator{}
+		return &Reader{Reader: transform.NewReader(buffered, counter), encoding: detected, counter: counter}, nil
+	}
+	enc, err := htmlindex.Get(detected)
+	if err != nil {
+		return nil, errors.NewChuxParserError("charset.NewReader() Unsupported charset "+detected+" of "+name, err)
+	}
+	// The decoders of other encodings replace what they cannot decode,
+	// so replacement characters are counted instead.
+	counter := &validator{countReplacements: true}
+	decoder := transform.Chain(enc.NewDecoder(), counter)
+	return &Reader{Reader: transform.NewReader(buffered, decoder), encoding: detected, counter: counter}, nil
+}
+
+// replacement is U+FFFD encoded as UTF-8.
+var replacement = []byte(string(utf8.RuneError))
+
+// validator is a transform.Transformer that copies valid UTF-8 and
+// replaces each invalid sequence with U+FFFD, counting them.
+type validator struct {
+	countReplacements bool
+	invalid           int
+}
+
+func (v *validator) Reset() {}
+
+func (v *validator) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		c := src[nSrc]
+		if c < utf8.RuneSelf {
+			if nDst == len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			dst[nDst] = c
+			nDst++
+			nSrc++
+			continue
+		}
+		if !atEOF && !utf8.FullRune(src[nSrc:]) {
+			return nDst, nSrc, transform.ErrShortSrc
+		}
+		r, size := utf8.DecodeRune(src[nSrc:])
+		invalid := r == utf8.RuneError && (size == 1 || v.countReplacements)
+		if size == 1 {
+			// Replaced by the three bytes of U+FFFD.
+			if nDst+len(replacement) > len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			nDst += copy(dst[nDst:], replacement)
+		} else {
+			if nDst+size > len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			nDst += copy(dst[nDst:], src[nSrc:nSrc+size])
+		}
+		if invalid {
+			v.invalid++
+		}
+		nSrc += size
+	}
+	return nDst, nSrc, nil
+}
@@ -0,0 +1,87 @@
+package charset
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		header string
+		hint   string
+		want   string
+	}{
+		{`{"name":"Stratocaster"}`, "", UTF8},
+		{`{"name":"Stratocaster"}`, "latin1", Windows1252},
+		{"\xef\xbb\xbf{}", "latin1", UTF8},
+		{"\xff\xfe{\x00}\x00", "", UTF16LE},
+		{"\xfe\xff\x00{\x00}", "", UTF16BE},
+		{`{"name":"Café"}`, "latin1", UTF8},
+		{"{\"name\":\"Caf\xe9\"}", "", Windows1252},
+		{"{\"name\":\"Caf\xe9\"}", "iso-8859-15", "iso-8859-15"},
+		// A sequence cut off at the end of the header is not invalid.
+		{"{\"name\":\"Caf\xc3", "", UTF8},
+	}
+	for _, tt := range tests {
+		if got := Detect([]byte(tt.header), tt.hint); got != tt.want {
+			t.Errorf("Detect(%q, %q) = %s, want %s", tt.header, tt.hint, got, tt.want)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	tests := []struct {
+		content string
+		hint    string
+		want    string
+		invalid int
+	}{
+		{"\xef\xbb\xbf{\"name\":\"Café\"}", "", `{"name":"Café"}`, 0},
+		{"{\"name\":\"Caf\xe9\"}", "", `{"name":"Café"}`, 0},
+		{"{\"name\":\"Caf\xc3\xa9 Stra\xc3\x9fe \xff\"}", "", "{\"name\":\"Café Straße �\"}", 1},
+	}
+	for _, tt := range tests {
+		reader, err := NewReader(strings.NewReader(tt.content), "products.jl", tt.hint)
+		if err != nil {
+			t.Fatalf("NewReader(%q) error = %v", tt.content, err)
+		}
+		got, err := io.ReadAll(reader)
+		if err != nil || string(got) != tt.want || reader.Invalid() != tt.invalid {
+			t.Errorf("NewReader(%q) read %q with %d invalid, %v, want %q with %d", tt.content, got, reader.Invalid(), err, tt.want, tt.invalid)
+		}
+	}
+}
+
+// The encoding is decided from the first headerSize bytes, later
+// Windows-1252 is replaced and counted.
+func TestNewReaderDecidesOnHeader(t *testing.T) {
+	content := strings.Repeat("a", headerSize) + "Caf\xe9"
+	reader, err := NewReader(strings.NewReader(content), "products.jl", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Encoding() != UTF8 || !strings.HasSuffix(string(got), "Caf�") || reader.Invalid() != 1 {
+		t.Errorf("read %s ending in %q with %d invalid, want utf-8 with 1 invalid", reader.Encoding(), got[len(got)-5:], reader.Invalid())
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestNewReaderReturnsReadErrors(t *testing.T) {
+	readErr := errors.New("object failed integrity verification")
+	if _, err := NewReader(failingReader{readErr}, "products.jl", ""); err != readErr {
+		t.Errorf("NewReader() error = %v, want %v", err, readErr)
+	}
+}
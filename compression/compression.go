@@ -0,0 +1,178 @@
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Format is the compression format of a crawl file.
+type Format int
+
+const (
+	None Format = iota
+	Gzip
+	Zstd
+	Bzip2
+)
+
+var formatNames = map[Format]string{
+	None:  "none",
+	Gzip:  "gzip",
+	Zstd:  "zstd",
+	Bzip2: "bzip2",
+}
+
+func (f Format) String() string {
+	return formatNames[f]
+}
+
+// extensions maps the file name suffixes of compressed files to
+// their Format.
+var extensions = map[string]Format{
+	".gz":   Gzip,
+	".gzip": Gzip,
+	".zst":  Zstd,
+	".zstd": Zstd,
+	".bz2":  Bzip2,
+}
+
+// encodings maps Content-Encoding values to their Format.
+var encodings = map[string]Format{
+	"gzip":    Gzip,
+	"x-gzip":  Gzip,
+	"zstd":    Zstd,
+	"bzip2":   Bzip2,
+	"x-bzip2": Bzip2,
+}
+
+// magic holds the leading bytes every stream of a Format starts with.
+// bzip2 streams continue with their block size, '1' to '9'.
+var magic = map[Format][]byte{
+	Gzip:  {0x1f, 0x8b},
+	Zstd:  {0x28, 0xb5, 0x2f, 0xfd},
+	Bzip2: []byte("BZh"),
+}
+
+// hasMagic returns true when header starts with the magic bytes of
+// format.
+func hasMagic(format Format, header []byte) bool {
+	prefix, ok := magic[format]
+	if !ok || !bytes.HasPrefix(header, prefix) {
+		return false
+	}
+	if format == Bzip2 {
+		return len(header) > len(prefix) && header[len(prefix)] >= '1' && header[len(prefix)] <= '9'
+	}
+	return true
+}
+
+// FromName returns the Format indicated by the suffix of a file name
+// or object key, e.g. products.jl.gz.
+func FromName(name string) Format {
+	return extensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// FromEncoding returns the Format indicated by a Content-Encoding.
+func FromEncoding(contentEncoding string) Format {
+	return encodings[strings.ToLower(strings.TrimSpace(contentEncoding))]
+}
+
+// Sniff returns the Format whose magic bytes header starts with.
+func Sniff(header []byte) Format {
+	for format := range magic {
+		if hasMagic(format, header) {
+			return format
+		}
+	}
+	return None
+}
+
+// TrimExt removes a compression suffix from name, so that
+// products.jl.gz becomes products.jl.
+func TrimExt(name string) string {
+	if FromName(name) == None {
+		return name
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// Detect determines the Format of a stream. The key suffix is
+// consulted first, then the Content-Encoding. A hint is only trusted
+// when the header carries the magic bytes of its Format, since the
+// HTTP transport may already have decoded a Content-Encoding. Without
+// a usable hint the header is sniffed.
+func Detect(name, contentEncoding string, header []byte) Format {
+	for _, hint := range []Format{FromName(name), FromEncoding(contentEncoding)} {
+		if hint != None && hasMagic(hint, header) {
+			return hint
+		}
+	}
+	return Sniff(header)
+}
+
+// NewReader returns a reader of the decompressed content of r. The
+// Format is determined with Detect, plain content is passed through.
+// Closing the returned reader releases the decompressor but does not
+// close r.
+func NewReader(r io.Reader, name, contentEncoding string) (io.ReadCloser, Format, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, None, errors.NewChuxParserError("compression.NewReader() Error reading header of "+name+": "+err.Error(), err)
+	}
+
+	format := Detect(name, contentEncoding, header)
+	switch format {
+	case Gzip:
+		reader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, format, errors.NewChuxParserError("compression.NewReader() Error opening gzip stream of "+name, err)
+		}
+		return reader, format, nil
+	case Zstd:
+		decoder, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, format, errors.NewChuxParserError("compression.NewReader() Error opening zstd stream of "+name, err)
+		}
+		return decoder.IOReadCloser(), format, nil
+	case Bzip2:
+		return io.NopCloser(bzip2.NewReader(buffered)), format, nil
+	default:
+		return io.NopCloser(buffered), format, nil
+	}
+}
+
+// Open opens a local file for reading its decompressed content.
+// Closing the returned reader closes the file.
+func Open(path string) (io.ReadCloser, Format, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, None, errors.NewChuxParserError("compression.Open() Error opening "+path, err)
+	}
+	content, format, err := NewReader(file, path, "")
+	if err != nil {
+		file.Close()
+		return nil, format, err
+	}
+	return fileReader{ReadCloser: content, file: file}, format, nil
+}
+
+// fileReader closes the decompressor and then the file it reads.
+type fileReader struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (r fileReader) Close() error {
+	r.ReadCloser.Close()
+	return r.file.Close()
+}
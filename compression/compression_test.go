@@ -0,0 +1,207 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const content = "{\"name\":\"Player Stratocaster\"}\n"
+
+// bzip2Content is content compressed with bzip2, which the standard
+// library cannot write.
+var bzip2Content = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x0a, 0x28, 0x10, 0x02, 0x00, 0x00,
+	0x0e, 0xdb, 0x80, 0x00, 0x10, 0x50, 0x00, 0x00, 0x10, 0x48, 0x00, 0x2a, 0x07, 0x9c, 0x2a, 0x20,
+	0x00, 0x22, 0x26, 0x9a, 0x32, 0x06, 0x23, 0xd4, 0x29, 0x93, 0x13, 0x20, 0xc8, 0xc1, 0x1a, 0x50,
+	0x8c, 0x4d, 0x1c, 0xb3, 0xcc, 0x81, 0x3e, 0xda, 0x41, 0x1a, 0x03, 0x06, 0x17, 0xe2, 0xee, 0x48,
+	0xa7, 0x0a, 0x12, 0x01, 0x45, 0x02, 0x00, 0x40,
+}
+
+func compress(t *testing.T, format Format) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	switch format {
+	case Gzip:
+		w := gzip.NewWriter(&buf)
+		w.Write([]byte(content))
+		w.Close()
+	case Zstd:
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+		w.Close()
+	case Bzip2:
+		buf.Write(bzip2Content)
+	default:
+		buf.WriteString(content)
+	}
+	return buf.Bytes()
+}
+
+func TestFromName(t *testing.T) {
+	tests := map[string]Format{
+		"sweetwater/products.jl":      None,
+		"sweetwater/products.jl.gz":   Gzip,
+		"sweetwater/products.JL.GZ":   Gzip,
+		"sweetwater/products.jl.gzip": Gzip,
+		"sweetwater/products.jl.zst":  Zstd,
+		"sweetwater/products.jl.zstd": Zstd,
+		"sweetwater/products.jl.bz2":  Bzip2,
+		"sweetwater/products.tgz":     None,
+		"sweetwater.gz/products.jl":   None,
+	}
+	for name, want := range tests {
+		if got := FromName(name); got != want {
+			t.Errorf("FromName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestFromEncoding(t *testing.T) {
+	tests := map[string]Format{
+		"":          None,
+		"identity":  None,
+		"gzip":      Gzip,
+		" GZIP ":    Gzip,
+		"x-gzip":    Gzip,
+		"zstd":      Zstd,
+		"bzip2":     Bzip2,
+		"x-bzip2":   Bzip2,
+		"br":        None,
+		"gzip, br":  None,
+		"deflate":   None,
+		"x-unknown": None,
+	}
+	for encoding, want := range tests {
+		if got := FromEncoding(encoding); got != want {
+			t.Errorf("FromEncoding(%q) = %v, want %v", encoding, got, want)
+		}
+	}
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Format
+	}{
+		{"\x1f\x8b\x08\x00", Gzip},
+		{"\x28\xb5\x2f\xfd", Zstd},
+		{"BZh9", Bzip2},
+		{"BZh1", Bzip2},
+		{"BZh0", None},
+		{"BZhA", None},
+		{"BZh", None},
+		{"BZhouse,price\n", None},
+		{"{\"na", None},
+		{"\x1f", None},
+		{"", None},
+	}
+	for _, tt := range tests {
+		if got := Sniff([]byte(tt.header)); got != tt.want {
+			t.Errorf("Sniff(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	gzipped := compress(t, Gzip)[:4]
+	zstded := compress(t, Zstd)[:4]
+	plain := []byte(content[:4])
+	tests := []struct {
+		name     string
+		encoding string
+		header   []byte
+		want     Format
+	}{
+		{"products.jl.gz", "", gzipped, Gzip},
+		{"products.jl", "gzip", gzipped, Gzip},
+		{"products.jl", "", gzipped, Gzip},
+		{"products.jl.zst", "gzip", zstded, Zstd},
+		{"products.jl", "zstd", zstded, Zstd},
+		{"products.jl", "", bzip2Content[:4], Bzip2},
+		// The transport already decoded the Content-Encoding, or the key
+		// lies about the content.
+		{"products.jl", "gzip", plain, None},
+		{"products.jl.gz", "", plain, None},
+		{"products.jl.bz2", "", []byte("BZh,"), None},
+		{"products.jl.gz", "", zstded, Zstd},
+		{"products.jl", "", plain, None},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.name, tt.encoding, tt.header); got != tt.want {
+			t.Errorf("Detect(%q, %q, %q) = %v, want %v", tt.name, tt.encoding, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestTrimExt(t *testing.T) {
+	tests := map[string]string{
+		"products.jl.gz":  "products.jl",
+		"products.jl.zst": "products.jl",
+		"products.jl":     "products.jl",
+		"products.csv.gz": "products.csv",
+	}
+	for name, want := range tests {
+		if got := TrimExt(name); got != want {
+			t.Errorf("TrimExt(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	for _, format := range []Format{None, Gzip, Zstd, Bzip2} {
+		reader, got, err := NewReader(bytes.NewReader(compress(t, format)), "products.jl", "")
+		if err != nil {
+			t.Fatalf("NewReader(%v) error = %v", format, err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil || string(data) != content || got != format {
+			t.Errorf("NewReader(%v) read %q, %v as %v, want %q", format, data, err, got, content)
+		}
+	}
+
+	for _, empty := range []string{"", "products.jl.gz"} {
+		reader, format, err := NewReader(bytes.NewReader(nil), empty, "gzip")
+		if err != nil || format != None {
+			t.Fatalf("NewReader() of empty content = %v, %v, want None", format, err)
+		}
+		if data, err := io.ReadAll(reader); err != nil || len(data) != 0 {
+			t.Errorf("NewReader() of empty content read %q, %v", data, err)
+		}
+	}
+
+	if _, _, err := NewReader(bytes.NewReader([]byte("\x1f\x8b\x00\x00")), "products.jl.gz", ""); err == nil {
+		t.Error("NewReader() of a broken gzip header succeeded")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.jl.gz")
+	if err := os.WriteFile(path, compress(t, Gzip), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reader, format, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil || string(data) != content || format != Gzip {
+		t.Errorf("Open() read %q, %v as %v", data, err, format)
+	}
+	if err := reader.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	if _, _, err := Open(filepath.Join(t.TempDir(), "missing.jl")); err == nil {
+		t.Error("Open() of a missing file succeeded")
+	}
+}
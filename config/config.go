@@ -0,0 +1,540 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chuxorg/chux-parser/changes"
+	"github.com/chuxorg/chux-parser/dedup"
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/records"
+	"github.com/chuxorg/chux-parser/retry"
+	"github.com/chuxorg/chux-parser/secrets"
+	"github.com/chuxorg/chux-parser/taxonomy"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the configuration file read when neither the -config
+// flag nor CHUX_CONFIG names one. It is optional.
+const DefaultFile = "chux-parser.yaml"
+
+// Config holds every setting of chux-parser. It is built in layers,
+// later layers overriding earlier ones: defaults, the YAML file,
+// secrets, the environment and command line flags. Each field names
+// its YAML key and environment variable, secrets use the environment
+// variable names as well.
+type Config struct {
+	AWS         AWS         `yaml:"aws"`
+	Mongo       Mongo       `yaml:"mongo"`
+	Log         Log         `yaml:"log"`
+	Secrets     Secrets     `yaml:"secrets"`
+	Queue       Queue       `yaml:"queue"`
+	Parse       Parse       `yaml:"parse"`
+	Retry       Retry       `yaml:"retry"`
+	Tags        Tags        `yaml:"tags"`
+	Quarantine  Quarantine  `yaml:"quarantine"`
+	Schema      Schema      `yaml:"schema"`
+	Mapping     Mapping     `yaml:"mapping"`
+	URLs        URLs        `yaml:"urls"`
+	Prices      Prices      `yaml:"prices"`
+	Brands      Brands      `yaml:"brands"`
+	Identifiers Identifiers `yaml:"identifiers"`
+	Taxonomy    Taxonomy    `yaml:"taxonomy"`
+	Dedup       Dedup       `yaml:"dedup"`
+	Changes     Changes     `yaml:"changes"`
+
+	// File is the YAML file the Config was read from, if any.
+	File string `yaml:"-"`
+	// flags holds the command line flags that were set, so they can be
+	// re-applied on top of secrets.
+	flags map[string]string
+	args  []string
+}
+
+// AWS holds the AWS settings. Credentials are resolved by awsauth in
+// this order: static credentials, web identity, the named Profile and
+// finally the default chain, which includes ECS task roles. AssumeRole
+// is applied on top of any of them for the source bucket only.
+type AWS struct {
+	Region       string `yaml:"region" env:"AWS_REGION"`
+	Profile      string `yaml:"profile" env:"AWS_PROFILE"`
+	SourceBucket string `yaml:"sourceBucket" env:"AWS_SOURCE_BUCKET"`
+	DownloadPath string `yaml:"downloadPath" env:"AWS_DOWNLOAD_PATH"`
+
+	// Static credentials, meant for local testing.
+	AccessKeyID     string `yaml:"accessKeyId" env:"AWS_ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secretAccessKey" env:"AWS_SECRET_ACCESS_KEY"`
+	SessionToken    string `yaml:"sessionToken" env:"AWS_SESSION_TOKEN"`
+
+	// Web identity federation, e.g. with an OIDC token mounted into
+	// the container.
+	WebIdentityTokenFile string `yaml:"webIdentityTokenFile" env:"AWS_WEB_IDENTITY_TOKEN_FILE"`
+	WebIdentityRoleARN   string `yaml:"webIdentityRoleArn" env:"AWS_ROLE_ARN"`
+
+	AssumeRole AssumeRole `yaml:"assumeRole"`
+
+	// S3 endpoint of an S3 compatible server such as MinIO, used for
+	// the source bucket only.
+	S3Endpoint       string `yaml:"s3Endpoint" env:"AWS_S3_ENDPOINT"`
+	S3ForcePathStyle bool   `yaml:"s3ForcePathStyle" env:"AWS_S3_FORCE_PATH_STYLE"`
+	S3DisableSSL     bool   `yaml:"s3DisableSSL" env:"AWS_S3_DISABLE_SSL"`
+
+	// Objects larger than S3PartSize bytes are downloaded in parts
+	// over S3Concurrency connections, 1 disables parallel downloads.
+	S3PartSize    int64 `yaml:"s3PartSize" env:"AWS_S3_PART_SIZE"`
+	S3Concurrency int   `yaml:"s3Concurrency" env:"AWS_S3_CONCURRENCY"`
+	// S3Verify checks the size and checksum of downloaded objects. The
+	// objects streamed by the consumer and the Lambda handler are
+	// verified after their records were saved, a failure marks them
+	// failed but leaves their records saved.
+	S3Verify bool `yaml:"s3Verify" env:"AWS_S3_VERIFY"`
+}
+
+// AssumeRole describes a role to assume, typically in the account
+// owning a cross-account crawl bucket.
+type AssumeRole struct {
+	RoleARN     string        `yaml:"roleArn" env:"AWS_ASSUME_ROLE_ARN"`
+	ExternalID  string        `yaml:"externalId" env:"AWS_ASSUME_ROLE_EXTERNAL_ID"`
+	SessionName string        `yaml:"sessionName" env:"AWS_ASSUME_ROLE_SESSION_NAME"`
+	Duration    time.Duration `yaml:"duration" env:"AWS_ASSUME_ROLE_DURATION"`
+}
+
+type Mongo struct {
+	// URI holds two %s verbs for the user name and password, e.g.
+	// mongodb+srv://%s:%s@cluster.example.net/
+	URI      string `yaml:"uri" env:"MONGO_URI"`
+	User     string `yaml:"user" env:"MONGO_USER_NAME"`
+	Password string `yaml:"password" env:"MONGO_PASSWORD"`
+	Database string `yaml:"database" env:"MONGO_DATABASE"`
+}
+
+type Log struct {
+	Level int    `yaml:"level" env:"LOG_LEVEL"`
+	Dir   string `yaml:"dir" env:"LOG_DIR"`
+}
+
+type Secrets struct {
+	// Provider is one of secretsmanager, ssm, file, env or none.
+	Provider string `yaml:"provider" env:"SECRETS_PROVIDER"`
+	// ID is the secret id, parameter path, file path or variable
+	// prefix the Provider reads from.
+	ID string `yaml:"id" env:"SECRETS_ID"`
+}
+
+type Queue struct {
+	URL               string        `yaml:"url" env:"AWS_QUEUE_URL"`
+	Dir               string        `yaml:"dir" env:"QUEUE_DIR"`
+	VisibilityTimeout time.Duration `yaml:"visibilityTimeout" env:"QUEUE_VISIBILITY_TIMEOUT"`
+}
+
+type Parse struct {
+	// RecordFormat is a records.Format name, empty detects it per file.
+	RecordFormat string `yaml:"recordFormat" env:"RECORD_FORMAT"`
+	// DownloadPath is the local directory Parser.GetFiles searches.
+	DownloadPath string `yaml:"downloadPath" env:"DOWNLOAD_PATH"`
+	// Charset of crawl files that are not UTF-8 and carry no charset
+	// of their own, e.g. iso-8859-1. Empty reads them as windows-1252.
+	Charset string `yaml:"charset" env:"PARSE_CHARSET"`
+	// MaxRecordSize caps a single NDJSON record in bytes. Larger records
+	// are skipped and, with a DeadLetterPrefix, their first
+	// MaxRecordSize bytes are stored below it along with the range of
+	// the complete record in its source, see s3.Bucket.DeadLetter.
+	MaxRecordSize    int    `yaml:"maxRecordSize" env:"MAX_RECORD_SIZE"`
+	DeadLetterPrefix string `yaml:"deadLetterPrefix" env:"DEAD_LETTER_PREFIX"`
+}
+
+// Retry configures the retry.Policy of S3 requests and MongoDB writes.
+type Retry struct {
+	// MaxAttempts includes the first attempt, 1 disables retries.
+	MaxAttempts int           `yaml:"maxAttempts" env:"RETRY_MAX_ATTEMPTS"`
+	BaseDelay   time.Duration `yaml:"baseDelay" env:"RETRY_BASE_DELAY"`
+	MaxDelay    time.Duration `yaml:"maxDelay" env:"RETRY_MAX_DELAY"`
+}
+
+// Policy returns the retry.Policy of the settings.
+func (r Retry) Policy() retry.Policy {
+	return retry.Policy{
+		MaxAttempts: r.MaxAttempts,
+		BaseDelay:   r.BaseDelay,
+		MaxDelay:    r.MaxDelay,
+	}
+}
+
+// Tags configures the S3 object tags recording the parse status of
+// each object, see s3.Status.
+type Tags struct {
+	// Enabled writes the tags after each object is processed, which
+	// needs s3:GetObjectTagging and s3:PutObjectTagging.
+	Enabled bool `yaml:"enabled" env:"S3_TAGS_ENABLED"`
+	// RunID identifies the run in the tags, one is generated when empty.
+	RunID string `yaml:"runId" env:"RUN_ID"`
+	// Only and Skip are comma separated statuses selecting the objects
+	// of a batch run, e.g. skip parsed,quarantined.
+	Only string `yaml:"only" env:"S3_TAGS_ONLY"`
+	Skip string `yaml:"skip" env:"S3_TAGS_SKIP"`
+}
+
+// Quarantine configures the quarantine of unusable objects, see
+// s3.Bucket.Quarantine.
+type Quarantine struct {
+	Enabled bool   `yaml:"enabled" env:"QUARANTINE_ENABLED"`
+	Prefix  string `yaml:"prefix" env:"QUARANTINE_PREFIX"`
+	// Move deletes quarantined objects from their key, otherwise they
+	// are copied.
+	Move bool `yaml:"move" env:"QUARANTINE_MOVE"`
+	// Objects of at least MinRecords records of which more than
+	// FailurePercent failed are quarantined as well.
+	FailurePercent int `yaml:"failurePercent" env:"QUARANTINE_FAILURE_PERCENT"`
+	MinRecords     int `yaml:"minRecords" env:"QUARANTINE_MIN_RECORDS"`
+}
+
+// Schema configures the validation of records against the JSON Schema
+// files of their company, see schema.Validator.
+type Schema struct {
+	// Dir holds the <company>.schema.json files, empty disables the
+	// validation.
+	Dir string `yaml:"dir" env:"SCHEMA_DIR"`
+	// Mode is lenient, reporting violations, or strict, rejecting the
+	// records with violations.
+	Mode string `yaml:"mode" env:"SCHEMA_MODE"`
+}
+
+// Mapping configures the field mapping of records, see mapping.Mapper.
+type Mapping struct {
+	// File holds the mapping rules per company, empty disables the
+	// mapping.
+	File string `yaml:"file" env:"MAPPING_FILE"`
+}
+
+// URLs configures the canonicalisation of record urls, see
+// urls.Canonicalizer. Products are upserted on their canonicalUrl, so
+// the canonicalUrl of the products already stored has to be rewritten
+// before it is enabled, or they are saved again as new documents.
+type URLs struct {
+	Enabled bool `yaml:"enabled" env:"URLS_ENABLED"`
+	// StripParams is a comma separated list of the query parameters
+	// stripped from every url in addition to urls.DefaultParams. A
+	// trailing * matches every parameter with the prefix.
+	StripParams string `yaml:"stripParams" env:"URLS_STRIP_PARAMS"`
+	// Retailers maps companies to the query parameters stripped from
+	// their urls in addition to urls.DefaultRetailers. It is only read
+	// from the YAML file.
+	Retailers map[string][]string `yaml:"retailers"`
+}
+
+// Prices configures the normalisation of offer prices, see
+// price.Normalizer.
+type Prices struct {
+	Enabled bool `yaml:"enabled" env:"PRICES_ENABLED"`
+	// DefaultCurrency of prices whose currency is neither written with
+	// them nor given by the domain they were found on.
+	DefaultCurrency string `yaml:"defaultCurrency" env:"PRICES_DEFAULT_CURRENCY"`
+	// BaseCurrency, when set, prices are also converted to with the
+	// exchange rates of RatesFile.
+	BaseCurrency string `yaml:"baseCurrency" env:"PRICES_BASE_CURRENCY"`
+	RatesFile    string `yaml:"ratesFile" env:"PRICES_RATES_FILE"`
+	// Domains maps hosts to the currency of their prices, e.g.
+	// thomann.de: EUR. It is only read from the YAML file.
+	Domains map[string]string `yaml:"domains"`
+}
+
+// Brands configures the extraction of the brand, model, variant and
+// color of products, see brands.Extractor.
+type Brands struct {
+	// File holds the brands, colors and variants recognised in the names
+	// of products, empty disables the extraction.
+	File string `yaml:"file" env:"BRANDS_FILE"`
+}
+
+// Identifiers configures the extraction of GTINs and MPNs, see
+// identifiers.Extractor.
+type Identifiers struct {
+	Enabled bool `yaml:"enabled" env:"IDENTIFIERS_ENABLED"`
+}
+
+// Taxonomy configures the mapping of retailer categories onto the
+// canonical category tree, see taxonomy.Mapper.
+type Taxonomy struct {
+	// File holds the category tree and the mapping of the retailer
+	// categories onto it, empty disables the mapping.
+	File string `yaml:"file" env:"TAXONOMY_FILE"`
+	// Similarity is the percentage of similarity of the names of the
+	// categories matched fuzzily, 101 matches none fuzzily.
+	Similarity int `yaml:"similarity" env:"TAXONOMY_SIMILARITY"`
+	// Report is mongo, counting the categories not mapped in File
+	// across runs in Collection, or file, writing those of a run to
+	// ReportFile once it is done.
+	Report     string `yaml:"report" env:"TAXONOMY_REPORT"`
+	ReportFile string `yaml:"reportFile" env:"TAXONOMY_REPORT_FILE"`
+	Collection string `yaml:"collection" env:"TAXONOMY_COLLECTION"`
+}
+
+// Dedup configures the deduplication of records, see
+// dedup.Deduplicator.
+type Dedup struct {
+	Enabled bool `yaml:"enabled" env:"DEDUP_ENABLED"`
+	// Store is mongo, remembering the saved records across runs in
+	// Collection, or memory, remembering them within a run.
+	Store      string `yaml:"store" env:"DEDUP_STORE"`
+	Collection string `yaml:"collection" env:"DEDUP_COLLECTION"`
+	// IgnoreFields is a comma separated list of fields left out of the
+	// content hash, in addition to dedup.DefaultIgnore.
+	IgnoreFields string `yaml:"ignoreFields" env:"DEDUP_IGNORE_FIELDS"`
+}
+
+// Changes configures the tracking of product changes, see
+// changes.Tracker.
+type Changes struct {
+	Enabled bool `yaml:"enabled" env:"CHANGES_ENABLED"`
+	// Store is mongo, keeping the last versions and the history of
+	// the products in the Versions and History collections, or memory.
+	Store    string `yaml:"store" env:"CHANGES_STORE"`
+	Versions string `yaml:"versions" env:"CHANGES_VERSIONS_COLLECTION"`
+	History  string `yaml:"history" env:"CHANGES_HISTORY_COLLECTION"`
+	// Fields is a comma separated list of the tracked fields, empty
+	// tracks changes.DefaultFields.
+	Fields string `yaml:"fields" env:"CHANGES_FIELDS"`
+	// TopicARN is the Amazon SNS topic change events are published to,
+	// empty publishes none.
+	TopicARN string `yaml:"topicArn" env:"CHANGES_TOPIC_ARN"`
+}
+
+// SecretsNone disables the secrets layer.
+const SecretsNone = "none"
+
+// Default returns the Config every layer starts from.
+func Default() *Config {
+	return &Config{
+		AWS: AWS{
+			Region: "us-east-1",
+			AssumeRole: AssumeRole{
+				SessionName: "chux-parser",
+			},
+			S3PartSize:    16 << 20,
+			S3Concurrency: 4,
+			S3Verify:      true,
+		},
+		Log: Log{
+			Level: 1,
+			Dir:   "logs/chux-cprs/",
+		},
+		Secrets: Secrets{
+			Provider: secrets.ProviderSecretsManager,
+			ID:       "dev/secrets",
+		},
+		Queue: Queue{
+			VisibilityTimeout: 5 * time.Minute,
+		},
+		Parse: Parse{
+			MaxRecordSize: records.DefaultMaxRecordSize,
+		},
+		Quarantine: Quarantine{
+			Prefix:         "quarantine/",
+			FailurePercent: 50,
+			MinRecords:     10,
+		},
+		Schema: Schema{
+			Mode: "lenient",
+		},
+		Changes: Changes{
+			Store:    "mongo",
+			Versions: changes.DefaultVersions,
+			History:  changes.DefaultHistory,
+		},
+		Taxonomy: Taxonomy{
+			Similarity: 80,
+			Report:     "mongo",
+			ReportFile: "unmapped-categories.yaml",
+			Collection: taxonomy.DefaultCollection,
+		},
+		Dedup: Dedup{
+			Store:      "mongo",
+			Collection: dedup.DefaultCollection,
+		},
+		Retry: Retry{
+			MaxAttempts: retry.Default().MaxAttempts,
+			BaseDelay:   retry.Default().BaseDelay,
+			MaxDelay:    retry.Default().MaxDelay,
+		},
+	}
+}
+
+// Load builds the Config from defaults, the YAML file, the environment
+// and the flags in args. Secrets are not fetched, see ApplySecrets.
+// Arguments left after the flags are available from Args.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	// The flags are parsed first to find the file, and re-applied
+	// after the other layers to take precedence.
+	// Positional arguments may appear between flags.
+	parsed := Default()
+	fs := parsed.flagSet()
+	for rest := args; ; {
+		if err := fs.Parse(rest); err != nil {
+			return nil, errors.NewChuxParserError(fmt.Sprintf("config.Load() invalid flags: %v", err), err)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		cfg.args = append(cfg.args, fs.Arg(0))
+		rest = fs.Args()[1:]
+	}
+	cfg.flags = map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		cfg.flags[f.Name] = f.Value.String()
+	})
+
+	cfg.File = parsed.File
+	if cfg.File == "" {
+		cfg.File = os.Getenv("CHUX_CONFIG")
+	}
+	if err := cfg.readFile(); err != nil {
+		return nil, err
+	}
+	if err := cfg.applyOverrides(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ApplySecrets binds secret values into the Config. Secrets override
+// the YAML file, the environment and flags still override secrets.
+func (c *Config) ApplySecrets(values map[string]string) error {
+	if err := secrets.BindTag(values, "env", c); err != nil {
+		return err
+	}
+	return c.applyOverrides()
+}
+
+// Args returns the arguments left after the flags.
+func (c *Config) Args() []string {
+	return c.args
+}
+
+func (c *Config) readFile() error {
+	path := c.File
+	if path == "" {
+		if _, err := os.Stat(DefaultFile); err != nil {
+			return nil
+		}
+		path = DefaultFile
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.NewChuxParserError("config.Load() failed to read "+path, err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return errors.NewChuxParserError(fmt.Sprintf("config.Load() failed to parse %s: %v", path, err), err)
+	}
+	c.File = path
+	return nil
+}
+
+// applyOverrides applies the environment and then the flags that
+// were set.
+func (c *Config) applyOverrides() error {
+	env := map[string]string{}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		env[name] = value
+	}
+	if err := secrets.BindTag(env, "env", c); err != nil {
+		return err
+	}
+
+	fs := c.flagSet()
+	for name, value := range c.flags {
+		if err := fs.Set(name, value); err != nil {
+			return errors.NewChuxParserError("config: invalid value for -"+name, err)
+		}
+	}
+	return nil
+}
+
+// flagSet returns the command line flags bound to the fields of c.
+func (c *Config) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("chux-parser", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&c.File, "config", c.File, "YAML configuration file")
+	fs.StringVar(&c.AWS.Region, "region", c.AWS.Region, "AWS region")
+	fs.StringVar(&c.AWS.Profile, "profile", c.AWS.Profile, "AWS shared config profile")
+	fs.StringVar(&c.AWS.AssumeRole.RoleARN, "assume-role-arn", c.AWS.AssumeRole.RoleARN, "ARN of a role to assume, e.g. in the crawl bucket's account")
+	fs.StringVar(&c.AWS.AssumeRole.ExternalID, "assume-role-external-id", c.AWS.AssumeRole.ExternalID, "external id required by the role to assume")
+	fs.StringVar(&c.AWS.S3Endpoint, "s3-endpoint", c.AWS.S3Endpoint, "url of an S3 compatible server, e.g. MinIO")
+	fs.BoolVar(&c.AWS.S3ForcePathStyle, "s3-path-style", c.AWS.S3ForcePathStyle, "address the bucket in the path instead of the host name")
+	fs.BoolVar(&c.AWS.S3DisableSSL, "s3-disable-ssl", c.AWS.S3DisableSSL, "talk plain http to the S3 endpoint")
+	fs.Int64Var(&c.AWS.S3PartSize, "s3-part-size", c.AWS.S3PartSize, "size in bytes of the parts of large objects downloaded in parallel")
+	fs.IntVar(&c.AWS.S3Concurrency, "s3-concurrency", c.AWS.S3Concurrency, "parallel connections per large object, 1 disables parallel downloads")
+	fs.BoolVar(&c.AWS.S3Verify, "s3-verify", c.AWS.S3Verify, "verify the size and checksum of downloaded objects")
+	fs.StringVar(&c.AWS.SourceBucket, "source-bucket", c.AWS.SourceBucket, "S3 bucket holding the crawl files")
+	fs.StringVar(&c.AWS.DownloadPath, "aws-download-path", c.AWS.DownloadPath, "local directory for downloaded objects")
+	fs.StringVar(&c.Mongo.Database, "mongo-database", c.Mongo.Database, "MongoDB database")
+	fs.IntVar(&c.Log.Level, "log-level", c.Log.Level, "log level, 0 debug to 3 error")
+	fs.StringVar(&c.Log.Dir, "log-dir", c.Log.Dir, "directory of the log file")
+	fs.StringVar(&c.Secrets.Provider, "secrets-provider", c.Secrets.Provider, "secretsmanager, ssm, file, env or none")
+	fs.StringVar(&c.Secrets.ID, "secrets-id", c.Secrets.ID, "secret id, parameter path, file or variable prefix")
+	fs.StringVar(&c.Queue.URL, "queue-url", c.Queue.URL, "url of the SQS queue receiving S3 notifications")
+	fs.StringVar(&c.Queue.Dir, "queue-dir", c.Queue.Dir, "directory of S3 notification files, used instead of SQS")
+	fs.DurationVar(&c.Queue.VisibilityTimeout, "visibility-timeout", c.Queue.VisibilityTimeout, "visibility timeout kept on messages while parsing")
+	fs.StringVar(&c.Parse.RecordFormat, "record-format", c.Parse.RecordFormat, "record format, detected per file when empty")
+	fs.StringVar(&c.Parse.DownloadPath, "download-path", c.Parse.DownloadPath, "local directory of crawl files")
+	fs.IntVar(&c.Parse.MaxRecordSize, "max-record-size", c.Parse.MaxRecordSize, "maximum size of an NDJSON record in bytes, larger ones are skipped")
+	fs.StringVar(&c.Parse.DeadLetterPrefix, "dead-letter-prefix", c.Parse.DeadLetterPrefix, "key prefix storing skipped oversize records, disabled when empty")
+	fs.StringVar(&c.Parse.Charset, "charset", c.Parse.Charset, "charset of crawl files that are not UTF-8, e.g. iso-8859-1")
+	fs.BoolVar(&c.Tags.Enabled, "tags", c.Tags.Enabled, "record the parse status in the tags of each object")
+	fs.StringVar(&c.Tags.RunID, "run-id", c.Tags.RunID, "run id recorded in the tags, generated when empty")
+	fs.StringVar(&c.Tags.Only, "only-status", c.Tags.Only, "comma separated statuses of the objects to download, e.g. failed")
+	fs.StringVar(&c.Tags.Skip, "skip-status", c.Tags.Skip, "comma separated statuses of the objects not to download, e.g. parsed")
+	fs.StringVar(&c.Mapping.File, "mapping-file", c.Mapping.File, "YAML file of the field mapping rules per company")
+	fs.BoolVar(&c.URLs.Enabled, "canonical-urls", c.URLs.Enabled, "set the canonical url of records, without tracking parameters")
+	fs.StringVar(&c.URLs.StripParams, "strip-params", c.URLs.StripParams, "comma separated query parameters stripped from every url, e.g. ref,src_*")
+	fs.BoolVar(&c.Prices.Enabled, "prices", c.Prices.Enabled, "normalise the prices and currencies of offers")
+	fs.StringVar(&c.Prices.BaseCurrency, "base-currency", c.Prices.BaseCurrency, "ISO code of the currency prices are also converted to")
+	fs.StringVar(&c.Prices.RatesFile, "rates-file", c.Prices.RatesFile, "YAML or JSON file of the exchange rates to the base currency")
+	fs.StringVar(&c.Brands.File, "brands-file", c.Brands.File, "YAML file of the brands, colors and variants split from the names of products")
+	fs.BoolVar(&c.Identifiers.Enabled, "identifiers", c.Identifiers.Enabled, "extract and verify the GTINs and MPNs of products")
+	fs.StringVar(&c.Taxonomy.File, "taxonomy-file", c.Taxonomy.File, "YAML file of the category tree and the mapping of retailer categories onto it")
+	fs.StringVar(&c.Taxonomy.Report, "taxonomy-report", c.Taxonomy.Report, "mongo counts the unmapped categories across runs, file writes those of a run")
+	fs.StringVar(&c.Taxonomy.ReportFile, "taxonomy-report-file", c.Taxonomy.ReportFile, "file the unmapped categories of a run are written to")
+	fs.BoolVar(&c.Dedup.Enabled, "dedup", c.Dedup.Enabled, "skip unchanged and duplicate records and group products across retailers")
+	fs.StringVar(&c.Dedup.Store, "dedup-store", c.Dedup.Store, "mongo remembers saved records across runs, memory within a run")
+	fs.BoolVar(&c.Changes.Enabled, "changes", c.Changes.Enabled, "keep a history of the changes of products and publish change events")
+	fs.StringVar(&c.Changes.Store, "changes-store", c.Changes.Store, "mongo keeps product versions and history across runs, memory within a run")
+	fs.StringVar(&c.Changes.TopicARN, "changes-topic", c.Changes.TopicARN, "ARN of the SNS topic change events are published to")
+	fs.StringVar(&c.Schema.Dir, "schema-dir", c.Schema.Dir, "directory of the <company>.schema.json files records are validated with")
+	fs.StringVar(&c.Schema.Mode, "schema-mode", c.Schema.Mode, "lenient reports schema violations, strict also rejects the records")
+	fs.BoolVar(&c.Quarantine.Enabled, "quarantine", c.Quarantine.Enabled, "quarantine malformed objects and objects with too many failed records")
+	fs.StringVar(&c.Quarantine.Prefix, "quarantine-prefix", c.Quarantine.Prefix, "key prefix of quarantined objects")
+	fs.IntVar(&c.Retry.MaxAttempts, "retry-max-attempts", c.Retry.MaxAttempts, "attempts of S3 requests and MongoDB writes, 1 disables retries")
+	return fs
+}
+
+// PrintDefaults writes the usage of the flags to w.
+func PrintDefaults(w io.Writer) {
+	fs := Default().flagSet()
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+// Format returns the parsed Parse.RecordFormat.
+func (c *Config) Format() records.Format {
+	format, _ := records.ParseFormat(c.Parse.RecordFormat)
+	return format
+}
+
+// ConnectionURI returns the MongoDB URI with the credentials and the
+// database filled in.
+func (m Mongo) ConnectionURI() string {
+	return fmt.Sprintf(m.URI, m.User, m.Password) + m.Database + "?retryWrites=true&w=majority"
+}
+
+// MaskedURI returns the MongoDB URI with masked credentials, for logs.
+func (m Mongo) MaskedURI() string {
+	return fmt.Sprintf(m.URI, "******", "******") + m.Database
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/chuxorg/chux-parser/charset"
+	"github.com/chuxorg/chux-parser/price"
+	"github.com/chuxorg/chux-parser/records"
+	"github.com/chuxorg/chux-parser/secrets"
+)
+
+// Problem is a missing or invalid setting found by Validate.
+type Problem struct {
+	// Setting names the YAML key and environment variable.
+	Setting string
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Setting, p.Message)
+}
+
+// Validate returns every missing or invalid setting. A Config without
+// problems can run the batch and consumer modes, a consumer
+// additionally needs Queue.URL or Queue.Dir which ValidateQueue checks.
+func (c *Config) Validate() []Problem {
+	var problems []Problem
+	add := func(setting, format string, args ...interface{}) {
+		problems = append(problems, Problem{Setting: setting, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.AWS.Region == "" {
+		add("aws.region (AWS_REGION)", "is required")
+	}
+	if c.AWS.SourceBucket == "" {
+		add("aws.sourceBucket (AWS_SOURCE_BUCKET)", "is required")
+	}
+	if (c.AWS.AccessKeyID == "") != (c.AWS.SecretAccessKey == "") {
+		add("aws.accessKeyId (AWS_ACCESS_KEY_ID)", "static credentials need both the access key id and the secret access key")
+	}
+	if c.AWS.WebIdentityTokenFile != "" && c.AWS.WebIdentityRoleARN == "" {
+		add("aws.webIdentityRoleArn (AWS_ROLE_ARN)", "is required with a web identity token file")
+	}
+	if c.AWS.AssumeRole.RoleARN == "" && c.AWS.AssumeRole.ExternalID != "" {
+		add("aws.assumeRole.roleArn (AWS_ASSUME_ROLE_ARN)", "is required with an external id")
+	}
+	if c.AWS.AssumeRole.RoleARN != "" && !strings.HasPrefix(c.AWS.AssumeRole.RoleARN, "arn:") {
+		add("aws.assumeRole.roleArn (AWS_ASSUME_ROLE_ARN)", "must be a role ARN")
+	}
+	if c.AWS.S3Endpoint != "" {
+		if u, err := url.Parse(c.AWS.S3Endpoint); err != nil || u.Host == "" {
+			add("aws.s3Endpoint (AWS_S3_ENDPOINT)", "must be a url like http://localhost:9000")
+		}
+	}
+	if c.AWS.S3PartSize < 1<<20 {
+		add("aws.s3PartSize (AWS_S3_PART_SIZE)", "must be at least 1 MiB, is %d", c.AWS.S3PartSize)
+	}
+	if c.AWS.S3Concurrency < 1 {
+		add("aws.s3Concurrency (AWS_S3_CONCURRENCY)", "must be at least 1, is %d", c.AWS.S3Concurrency)
+	}
+
+	if c.Mongo.URI == "" {
+		add("mongo.uri (MONGO_URI)", "is required")
+	} else {
+		if !strings.HasPrefix(c.Mongo.URI, "mongodb://") && !strings.HasPrefix(c.Mongo.URI, "mongodb+srv://") {
+			add("mongo.uri (MONGO_URI)", "must start with mongodb:// or mongodb+srv://")
+		}
+		if strings.Count(c.Mongo.URI, "%s") != 2 {
+			add("mongo.uri (MONGO_URI)", "must hold two %%s verbs for user name and password")
+		}
+	}
+	if c.Mongo.User == "" {
+		add("mongo.user (MONGO_USER_NAME)", "is required")
+	}
+	if c.Mongo.Password == "" {
+		add("mongo.password (MONGO_PASSWORD)", "is required")
+	}
+	if c.Mongo.Database == "" {
+		add("mongo.database (MONGO_DATABASE)", "is required")
+	}
+
+	if c.Log.Level < 0 || c.Log.Level > 3 {
+		add("log.level (LOG_LEVEL)", "must be between 0 (debug) and 3 (error), is %d", c.Log.Level)
+	}
+
+	switch strings.ToLower(c.Secrets.Provider) {
+	case secrets.ProviderSecretsManager, secrets.ProviderSSM, secrets.ProviderFile:
+		if c.Secrets.ID == "" {
+			add("secrets.id (SECRETS_ID)", "is required for provider %s", c.Secrets.Provider)
+		}
+	case secrets.ProviderEnv, SecretsNone:
+	default:
+		add("secrets.provider (SECRETS_PROVIDER)", "unknown provider %q", c.Secrets.Provider)
+	}
+
+	if c.Queue.VisibilityTimeout <= 0 {
+		add("queue.visibilityTimeout (QUEUE_VISIBILITY_TIMEOUT)", "must be positive")
+	}
+	if _, err := records.ParseFormat(c.Parse.RecordFormat); err != nil {
+		add("parse.recordFormat (RECORD_FORMAT)", "%v", err)
+	}
+	if c.Parse.MaxRecordSize < 1024 {
+		add("parse.maxRecordSize (MAX_RECORD_SIZE)", "must be at least 1024 bytes, is %d", c.Parse.MaxRecordSize)
+	}
+	if c.Parse.DeadLetterPrefix != "" && !strings.HasSuffix(c.Parse.DeadLetterPrefix, "/") {
+		add("parse.deadLetterPrefix (DEAD_LETTER_PREFIX)", "must end with /, is %q", c.Parse.DeadLetterPrefix)
+	}
+	if c.Parse.Charset != "" && charset.Lookup(c.Parse.Charset) == "" {
+		add("parse.charset (PARSE_CHARSET)", "unknown charset %q", c.Parse.Charset)
+	}
+	if c.Retry.MaxAttempts < 1 {
+		add("retry.maxAttempts (RETRY_MAX_ATTEMPTS)", "must be at least 1, is %d", c.Retry.MaxAttempts)
+	}
+	if c.Retry.BaseDelay < 0 || c.Retry.MaxDelay < c.Retry.BaseDelay {
+		add("retry.maxDelay (RETRY_MAX_DELAY)", "must not be below retry.baseDelay (RETRY_BASE_DELAY)")
+	}
+	if !strings.HasSuffix(c.Quarantine.Prefix, "/") {
+		add("quarantine.prefix (QUARANTINE_PREFIX)", "must end with /, is %q", c.Quarantine.Prefix)
+	}
+	if c.Quarantine.FailurePercent < 0 || c.Quarantine.FailurePercent > 100 {
+		add("quarantine.failurePercent (QUARANTINE_FAILURE_PERCENT)", "must be between 0 and 100, is %d", c.Quarantine.FailurePercent)
+	}
+	if c.Mapping.File != "" {
+		if _, err := os.Stat(c.Mapping.File); err != nil {
+			add("mapping.file (MAPPING_FILE)", "%v", err)
+		}
+	}
+	if c.Prices.DefaultCurrency != "" && !price.IsCurrency(c.Prices.DefaultCurrency) {
+		add("prices.defaultCurrency (PRICES_DEFAULT_CURRENCY)", "unknown ISO currency code %q", c.Prices.DefaultCurrency)
+	}
+	if c.Prices.BaseCurrency != "" {
+		if !price.IsCurrency(c.Prices.BaseCurrency) {
+			add("prices.baseCurrency (PRICES_BASE_CURRENCY)", "unknown ISO currency code %q", c.Prices.BaseCurrency)
+		}
+		if c.Prices.RatesFile == "" {
+			add("prices.ratesFile (PRICES_RATES_FILE)", "is required with a base currency")
+		}
+	}
+	if c.Prices.RatesFile != "" {
+		if _, err := os.Stat(c.Prices.RatesFile); err != nil {
+			add("prices.ratesFile (PRICES_RATES_FILE)", "%v", err)
+		}
+	}
+	for host, code := range c.Prices.Domains {
+		if !price.IsCurrency(code) {
+			add("prices.domains", "unknown ISO currency code %q of %s", code, host)
+		}
+	}
+	if c.Brands.File != "" {
+		if _, err := os.Stat(c.Brands.File); err != nil {
+			add("brands.file (BRANDS_FILE)", "%v", err)
+		}
+	}
+	if c.Taxonomy.File != "" {
+		if _, err := os.Stat(c.Taxonomy.File); err != nil {
+			add("taxonomy.file (TAXONOMY_FILE)", "%v", err)
+		}
+	}
+	if c.Taxonomy.Similarity < 0 || c.Taxonomy.Similarity > 101 {
+		add("taxonomy.similarity (TAXONOMY_SIMILARITY)", "must be between 0 and 101, is %d", c.Taxonomy.Similarity)
+	}
+	if c.Taxonomy.Report != "mongo" && c.Taxonomy.Report != "file" {
+		add("taxonomy.report (TAXONOMY_REPORT)", "must be mongo or file, is %q", c.Taxonomy.Report)
+	}
+	if c.Taxonomy.Report == "file" && c.Taxonomy.ReportFile == "" {
+		add("taxonomy.reportFile (TAXONOMY_REPORT_FILE)", "is required with the file report")
+	}
+	if c.Taxonomy.Report == "mongo" && c.Taxonomy.Collection == "" {
+		add("taxonomy.collection (TAXONOMY_COLLECTION)", "is required with the mongo report")
+	}
+	if c.Dedup.Store != "mongo" && c.Dedup.Store != "memory" {
+		add("dedup.store (DEDUP_STORE)", "must be mongo or memory, is %q", c.Dedup.Store)
+	}
+	if c.Dedup.Store == "mongo" && c.Dedup.Collection == "" {
+		add("dedup.collection (DEDUP_COLLECTION)", "is required with the mongo store")
+	}
+	if c.Changes.Store != "mongo" && c.Changes.Store != "memory" {
+		add("changes.store (CHANGES_STORE)", "must be mongo or memory, is %q", c.Changes.Store)
+	}
+	if c.Changes.Store == "mongo" && (c.Changes.Versions == "" || c.Changes.History == "") {
+		add("changes.versions (CHANGES_VERSIONS_COLLECTION)", "the versions and history collections are required with the mongo store")
+	}
+	if c.Changes.TopicARN != "" && !strings.HasPrefix(c.Changes.TopicARN, "arn:") {
+		add("changes.topicArn (CHANGES_TOPIC_ARN)", "must be a topic ARN")
+	}
+	if c.Schema.Mode != "lenient" && c.Schema.Mode != "strict" {
+		add("schema.mode (SCHEMA_MODE)", "must be lenient or strict, is %q", c.Schema.Mode)
+	}
+	if c.Schema.Dir != "" {
+		if info, err := os.Stat(c.Schema.Dir); err != nil || !info.IsDir() {
+			add("schema.dir (SCHEMA_DIR)", "must be a directory, is %q", c.Schema.Dir)
+		}
+	}
+	for _, filter := range []struct{ setting, states string }{
+		{"tags.only (S3_TAGS_ONLY)", c.Tags.Only},
+		{"tags.skip (S3_TAGS_SKIP)", c.Tags.Skip},
+	} {
+		for _, state := range strings.Split(filter.states, ",") {
+			switch strings.TrimSpace(state) {
+			case "", "parsed", "failed", "quarantined":
+			default:
+				add(filter.setting, "unknown status %q, use parsed, failed or quarantined", state)
+			}
+		}
+	}
+	return problems
+}
+
+// ValidateQueue returns the problems of the settings of the consumer.
+func (c *Config) ValidateQueue() []Problem {
+	if c.Queue.URL == "" && c.Queue.Dir == "" {
+		return []Problem{{
+			Setting: "queue.url (AWS_QUEUE_URL) or queue.dir (QUEUE_DIR)",
+			Message: "one is required to consume notifications",
+		}}
+	}
+	return nil
+}
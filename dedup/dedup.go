@@ -0,0 +1,274 @@
+// Package dedup keeps re-crawled products and articles that did not
+// change from being saved again, and groups the offers of the same
+// product at different retailers under a canonical product ID.
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/chuxorg/chux-parser/identifiers"
+	"github.com/chuxorg/chux-parser/logging"
+	"github.com/chuxorg/chux-parser/pipeline"
+	"github.com/chuxorg/chux-parser/urls"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PropertyProductID is the additional property holding the canonical
+// product ID of a product.
+const PropertyProductID = "canonicalProductId"
+
+// DefaultIgnore are the fields left out of the content hash, as they
+// change between crawls of the same content or identify it already.
+var DefaultIgnore = []string{"url", "canonicalUrl", "dateCreated", "dateModified", "crawledAt", "_id", "probability"}
+
+// Deduplicator is a pipeline.Stage skipping records that are
+// duplicates of ones saved before at the same retailer: a record at
+// the canonical url of one whose content hash was the same, or one
+// with the content of a record at another url. Products get the
+// canonical product ID of the products sharing a GTIN, or the MPN and
+// the brand, with them, whatever their retailer. Records are only
+// recorded in the Store once their model was saved, see Commit.
+type Deduplicator struct {
+	Store Store
+	// Ignore lists the fields left out of the content hash.
+	Ignore []string
+	Logger *logging.Logger
+}
+
+// New returns a new Deduplicator
+func New(options ...func(*Deduplicator)) *Deduplicator {
+
+	d := &Deduplicator{
+		Store:  NewMemoryStore(),
+		Ignore: DefaultIgnore,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+func WithStore(store Store) func(*Deduplicator) {
+	return func(d *Deduplicator) {
+		d.Store = store
+	}
+}
+
+// WithIgnore adds fields to the ones left out of the content hash.
+func WithIgnore(fields ...string) func(*Deduplicator) {
+	return func(d *Deduplicator) {
+		d.Ignore = append(append([]string{}, d.Ignore...), fields...)
+	}
+}
+
+func WithLogger(logger *logging.Logger) func(*Deduplicator) {
+	return func(d *Deduplicator) {
+		d.Logger = logger
+	}
+}
+
+func (d *Deduplicator) Name() string {
+	return "dedup"
+}
+
+// seen is what Process keeps of a record for Commit.
+type seen struct {
+	url        string
+	hash       string
+	identities []string
+	productID  string
+}
+
+// Process skips a duplicate record, and sets the canonical product ID
+// of a product. The Store failing is reported and the record kept.
+func (d *Deduplicator) Process(record *pipeline.Record) error {
+	ctx := context.Background()
+	s := &seen{
+		url:  CanonicalURL(record.Fields),
+		hash: Hash(record.Fields, d.Ignore),
+	}
+
+	if s.url != "" {
+		entry, err := d.Store.Get(ctx, d.urlKey(record, s.url))
+		if err != nil {
+			record.Report("url", "%v", err)
+			return nil
+		}
+		if entry != nil && entry.Hash == s.hash {
+			return fmt.Errorf("%w: unchanged since %s", pipeline.ErrSkip, entry.Source)
+		}
+		if entry != nil {
+			s.productID = entry.ProductID
+		}
+	}
+	entry, err := d.Store.Get(ctx, d.hashKey(record, s.hash))
+	if err != nil {
+		record.Report("$", "%v", err)
+		return nil
+	}
+	if entry != nil && (s.url == "" || entry.URL != s.url) {
+		return fmt.Errorf("%w: duplicate of %s in %s", pipeline.ErrSkip, entry.URL, entry.Source)
+	}
+
+	if record.IsProduct {
+		s.identities = Identities(record.Fields)
+		for _, key := range s.identities {
+			entry, err := d.Store.Get(ctx, key)
+			if err != nil {
+				record.Report("gtin", "%v", err)
+				break
+			}
+			if entry != nil && entry.ProductID != "" {
+				if s.productID != "" && s.productID != entry.ProductID {
+					d.Logger.Info("dedup.Process() Regrouping %s from %s to %s", s.url, s.productID, entry.ProductID)
+				}
+				s.productID = entry.ProductID
+				break
+			}
+		}
+		if s.productID == "" {
+			s.productID = primitive.NewObjectID().Hex()
+		}
+		record.SetProperty(PropertyProductID, s.productID)
+	}
+
+	if record.State == nil {
+		record.State = map[string]interface{}{}
+	}
+	record.State[d.Name()] = s
+	return nil
+}
+
+// Commit records the saved record in the Store.
+func (d *Deduplicator) Commit(record *pipeline.Record) error {
+	s, ok := record.State[d.Name()].(*seen)
+	if !ok {
+		return nil
+	}
+	ctx := context.Background()
+	now := time.Now().UTC()
+	entries := []Entry{{Key: d.hashKey(record, s.hash), ProductID: s.productID, Hash: s.hash, URL: s.url}}
+	if s.url != "" {
+		entries = append(entries, Entry{Key: d.urlKey(record, s.url), ProductID: s.productID, Hash: s.hash, URL: s.url})
+	}
+	for _, key := range s.identities {
+		entries = append(entries, Entry{Key: key, ProductID: s.productID, URL: s.url})
+	}
+	for _, entry := range entries {
+		entry.Source = record.Key
+		entry.Updated = now
+		if err := d.Store.Put(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kind returns the kind of record, keeping the keys of products and
+// articles apart.
+func kind(record *pipeline.Record) string {
+	if record.IsProduct {
+		return "product"
+	}
+	return "article"
+}
+
+func (d *Deduplicator) urlKey(record *pipeline.Record, url string) string {
+	return "url:" + kind(record) + ":" + record.Company + ":" + url
+}
+
+func (d *Deduplicator) hashKey(record *pipeline.Record, hash string) string {
+	return "hash:" + kind(record) + ":" + record.Company + ":" + hash
+}
+
+// CanonicalURL returns the canonicalUrl of fields, or else their url,
+// in the form of urls.Default. The urls stage sets the canonicalUrl
+// with the parameters of each retailer.
+func CanonicalURL(fields map[string]interface{}) string {
+	raw, _ := fields["canonicalUrl"].(string)
+	if raw == "" {
+		raw, _ = fields["url"].(string)
+	}
+	canonical, err := urls.Default.Canonical(raw, "")
+	if err != nil {
+		return raw
+	}
+	return canonical
+}
+
+// Hash returns the SHA-256 of the JSON of fields without the ignored
+// ones. Objects are encoded with sorted keys, so equal content has an
+// equal hash.
+func Hash(fields map[string]interface{}, ignore []string) string {
+	content := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		content[name] = value
+	}
+	for _, name := range ignore {
+		delete(content, name)
+	}
+	data, _ := json.Marshal(content)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Identities returns the keys identifying a product across retailers:
+// its valid GTINs as GTIN-14, and its MPN along with its brand.
+func Identities(fields map[string]interface{}) []string {
+	var keys []string
+	for _, gtin := range gtins(fields["gtin"]) {
+		if normalized, err := identifiers.GTIN14(gtin); err == nil {
+			keys = append(keys, "gtin:"+normalized)
+		}
+	}
+	mpn, _ := fields["mpn"].(string)
+	brand, _ := fields["brand"].(string)
+	if object, ok := fields["brand"].(map[string]interface{}); ok {
+		brand, _ = object["name"].(string)
+	}
+	if mpn, brand = normalizeName(mpn), normalizeName(brand); mpn != "" && brand != "" {
+		keys = append(keys, "mpn:"+brand+":"+mpn)
+	}
+	return keys
+}
+
+// gtins returns the GTIN values of a gtin field, which is a list of
+// objects with a value, as in the model, a list of strings or a
+// string.
+func gtins(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case json.Number:
+		return []string{v.String()}
+	case []interface{}:
+		var result []string
+		for _, item := range v {
+			if object, ok := item.(map[string]interface{}); ok {
+				item = object["value"]
+			}
+			result = append(result, gtins(item)...)
+		}
+		return result
+	}
+	return nil
+}
+
+// normalizeName returns name in lower case without anything but
+// letters and digits, so "Fender" and "FENDER " match.
+func normalizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package dedup
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+func product(company, key string, fields map[string]interface{}) *pipeline.Record {
+	return &pipeline.Record{Key: key, Company: company, IsProduct: true, Fields: fields}
+}
+
+// save processes record like the Parser: a record that is not skipped
+// is saved and committed. It returns the error of Process.
+func save(t *testing.T, d *Deduplicator, record *pipeline.Record) error {
+	t.Helper()
+	err := d.Process(record)
+	if err != nil {
+		if !errors.Is(err, pipeline.ErrSkip) {
+			t.Fatalf("Process() error = %v, want nil or ErrSkip", err)
+		}
+		return err
+	}
+	if err := d.Commit(record); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	return nil
+}
+
+func strat(url, price string) map[string]interface{} {
+	return map[string]interface{}{
+		"url":   url,
+		"name":  "Fender Player Stratocaster",
+		"price": price,
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+	d := New()
+	url := "https://www.sweetwater.com/store/detail/StratPlayer"
+	if err := save(t, d, product("sweetwater", "day1.jl", strat(url, "849.99"))); err != nil {
+		t.Fatalf("first crawl skipped: %v", err)
+	}
+
+	// Tracking parameters and the crawl time do not make it a change
+	recrawl := strat(url+"?utm_source=feed", "849.99")
+	recrawl["crawledAt"] = "2023-05-21T10:00:00Z"
+	if err := save(t, d, product("sweetwater", "day2.jl", recrawl)); !errors.Is(err, pipeline.ErrSkip) {
+		t.Errorf("unchanged record not skipped: %v", err)
+	}
+
+	first := product("sweetwater", "day3.jl", strat(url, "799.99"))
+	if err := save(t, d, first); err != nil {
+		t.Errorf("changed record skipped: %v", err)
+	}
+	again := product("sweetwater", "day4.jl", strat(url, "799.99"))
+	if err := save(t, d, again); !errors.Is(err, pipeline.ErrSkip) {
+		t.Errorf("record unchanged since its change not skipped: %v", err)
+	}
+}
+
+// A record is only recorded once it was committed, so a record whose
+// model failed to save is processed again.
+func TestNotCommitted(t *testing.T) {
+	d := New()
+	url := "https://www.sweetwater.com/store/detail/StratPlayer"
+	if err := d.Process(product("sweetwater", "day1.jl", strat(url, "849.99"))); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if err := d.Process(product("sweetwater", "day1.jl", strat(url, "849.99"))); err != nil {
+		t.Errorf("uncommitted record skipped: %v", err)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	d := New()
+	if err := save(t, d, product("sweetwater", "day1.jl", strat("https://www.sweetwater.com/store/detail/StratPlayer", "849.99"))); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same content under another url of the same retailer
+	err := save(t, d, product("sweetwater", "day1.jl", strat("https://www.sweetwater.com/store/detail/StratPlayer--fender", "849.99")))
+	if !errors.Is(err, pipeline.ErrSkip) {
+		t.Errorf("duplicate not skipped: %v", err)
+	}
+
+	// and at another retailer
+	if err := save(t, d, product("guitarcenter", "day1.jl", strat("https://www.guitarcenter.com/Fender/Player-Stratocaster", "849.99"))); err != nil {
+		t.Errorf("product of another retailer skipped: %v", err)
+	}
+
+	// An article with the content of a product is no duplicate
+	article := &pipeline.Record{Key: "day1.jl", Company: "sweetwater", Fields: strat("https://www.sweetwater.com/insync/strat", "849.99")}
+	if err := save(t, d, article); err != nil {
+		t.Errorf("article skipped as a duplicate of a product: %v", err)
+	}
+}
+
+func TestGrouping(t *testing.T) {
+	d := New()
+	sweetwater := product("sweetwater", "day1.jl", map[string]interface{}{
+		"url":   "https://www.sweetwater.com/store/detail/StratPlayer",
+		"name":  "Fender Player Stratocaster",
+		"gtin":  []interface{}{map[string]interface{}{"type": "upc", "value": "036000291452"}},
+		"brand": "Fender",
+		"mpn":   "0144502500",
+	})
+	if err := save(t, d, sweetwater); err != nil {
+		t.Fatal(err)
+	}
+	productID := sweetwater.Property(PropertyProductID)
+	if productID == "" {
+		t.Fatal("no canonical product ID set")
+	}
+
+	tests := []struct {
+		name   string
+		record *pipeline.Record
+		same   bool
+	}{
+		{"same GTIN as EAN-13", product("thomann", "day1.jl", map[string]interface{}{
+			"url":  "https://www.thomann.de/fender_player_strat.htm",
+			"name": "Fender Player Strat PF",
+			"gtin": "0036000291452",
+		}), true},
+		{"same MPN and brand", product("guitarcenter", "day1.jl", map[string]interface{}{
+			"url":   "https://www.guitarcenter.com/Fender/Player-Stratocaster",
+			"name":  "Player Stratocaster Electric Guitar",
+			"brand": map[string]interface{}{"@type": "Brand", "name": "FENDER "},
+			"mpn":   "0144502500",
+		}), true},
+		{"same MPN of another brand", product("reverb", "day1.jl", map[string]interface{}{
+			"url":   "https://reverb.com/item/1-squier-strat",
+			"name":  "Squier Stratocaster",
+			"brand": "Squier",
+			"mpn":   "0144502500",
+		}), false},
+		{"invalid GTIN", product("musiciansfriend", "day1.jl", map[string]interface{}{
+			"url":  "https://www.musiciansfriend.com/fender-player-stratocaster",
+			"name": "Fender Player Stratocaster Guitar",
+			"gtin": "036000291453",
+		}), false},
+	}
+	for _, tt := range tests {
+		if err := save(t, d, tt.record); err != nil {
+			t.Fatalf("%s: skipped: %v", tt.name, err)
+		}
+		got := tt.record.Property(PropertyProductID)
+		if got == "" || (got == productID) != tt.same {
+			t.Errorf("%s: canonical product ID %q, want same as %q: %v", tt.name, got, productID, tt.same)
+		}
+	}
+
+	// A re-crawl with a new price keeps its product ID
+	recrawl := product("sweetwater", "day2.jl", map[string]interface{}{
+		"url":   "https://www.sweetwater.com/store/detail/StratPlayer",
+		"name":  "Fender Player Stratocaster",
+		"price": "799.99",
+	})
+	if err := save(t, d, recrawl); err != nil {
+		t.Fatal(err)
+	}
+	if got := recrawl.Property(PropertyProductID); got != productID {
+		t.Errorf("re-crawl has canonical product ID %q, want %q", got, productID)
+	}
+}
@@ -0,0 +1,62 @@
+package dedup
+
+import (
+	"context"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultCollection is the MongoDB collection of the Entries.
+const DefaultCollection = "dedup"
+
+// MongoStore is a Store keeping its Entries in a MongoDB collection,
+// one document per key.
+type MongoStore struct {
+	Client     *mongodb.Client
+	Collection string
+}
+
+// NewMongoStore returns a MongoStore of the collection in the database
+// of client.
+func NewMongoStore(client *mongodb.Client, collection string) *MongoStore {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	return &MongoStore{Client: client, Collection: collection}
+}
+
+func (s *MongoStore) Get(ctx context.Context, key string) (*Entry, error) {
+	ctx, cancel := s.Client.Context(ctx)
+	defer cancel()
+	collection, err := s.Client.Collection(ctx, s.Collection)
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	err = collection.FindOne(ctx, bson.M{"_id": key}).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.NewChuxParserError("dedup.MongoStore.Get() Error finding "+key, err)
+	}
+	return &entry, nil
+}
+
+func (s *MongoStore) Put(ctx context.Context, entry Entry) error {
+	ctx, cancel := s.Client.Context(ctx)
+	defer cancel()
+	collection, err := s.Client.Collection(ctx, s.Collection)
+	if err != nil {
+		return err
+	}
+	_, err = collection.ReplaceOne(ctx, bson.M{"_id": entry.Key}, entry, options.Replace().SetUpsert(true))
+	if err != nil {
+		return errors.NewChuxParserError("dedup.MongoStore.Put() Error saving "+entry.Key, err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package dedup
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Entry is what a Store keeps under a key: the canonical product ID,
+// and for the url and hash keys of a retailer the content hash and
+// url of the product last saved.
+type Entry struct {
+	Key       string `bson:"_id"`
+	ProductID string `bson:"productId,omitempty"`
+	Hash      string `bson:"hash,omitempty"`
+	URL       string `bson:"url,omitempty"`
+	// Source is the object key of the record last saved.
+	Source  string    `bson:"source,omitempty"`
+	Updated time.Time `bson:"updated"`
+}
+
+// Store keeps the Entries of the products Deduplicator has seen.
+// MongoStore keeps them across runs, MemoryStore within a run.
+type Store interface {
+	// Get returns the Entry of key, nil when there is none.
+	Get(ctx context.Context, key string) (*Entry, error)
+	// Put stores entry under its Key.
+	Put(ctx context.Context, entry Entry) error
+}
+
+// MemoryStore is an in-process Store.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]Entry{}}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (s *MemoryStore) Put(ctx context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[entry.Key] = entry
+	return nil
+}
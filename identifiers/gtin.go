@@ -0,0 +1,96 @@
+package identifiers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// digitsOf returns s without spaces and dashes, or an empty string when
+// anything else but digits remains.
+func digitsOf(s string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '.' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	return digits
+}
+
+// ValidCheckDigit reports whether the last of the digits is the GS1
+// check digit of the others, as in every GTIN and ISBN-13.
+func ValidCheckDigit(digits string) bool {
+	if len(digits) < 2 {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 2; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		// Weighted 3 and 1 alternately from the right of the payload.
+		if (len(digits)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return int(digits[len(digits)-1]-'0') == (10-sum%10)%10
+}
+
+// GTIN14 returns a GTIN-8, GTIN-12 (UPC), GTIN-13 (EAN) or GTIN-14 as a
+// GTIN-14, padded with zeros. Spaces and dashes are ignored. Zeros
+// only, which shops fill in for a missing GTIN, are no GTIN although
+// their check digit is valid.
+func GTIN14(s string) (string, error) {
+	digits := digitsOf(s)
+	switch len(digits) {
+	case 8, 12, 13, 14:
+	default:
+		return "", fmt.Errorf("%q is not a GTIN", s)
+	}
+	if strings.Trim(digits, "0") == "" {
+		return "", fmt.Errorf("%q is not a GTIN", s)
+	}
+	if !ValidCheckDigit(digits) {
+		return "", fmt.Errorf("invalid check digit in %q", s)
+	}
+	return strings.Repeat("0", 14-len(digits)) + digits, nil
+}
+
+// ISBN returns an ISBN-10 or ISBN-13 as a GTIN-14. ISBN-10s become
+// ISBN-13s with the 978 prefix.
+func ISBN(s string) (string, error) {
+	compact := strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(s)))
+	if len(compact) == 13 {
+		return GTIN14(compact)
+	}
+	if len(compact) != 10 {
+		return "", fmt.Errorf("%q is not an ISBN", s)
+	}
+	sum := 0
+	for i, r := range compact {
+		var d int
+		switch {
+		case r >= '0' && r <= '9':
+			d = int(r - '0')
+		case r == 'X' && i == 9:
+			d = 10
+		default:
+			return "", fmt.Errorf("%q is not an ISBN", s)
+		}
+		sum += d * (10 - i)
+	}
+	if sum%11 != 0 {
+		return "", fmt.Errorf("invalid check digit in %q", s)
+	}
+	isbn13 := "978" + compact[:9]
+	for check := '0'; check <= '9'; check++ {
+		if ValidCheckDigit(isbn13 + string(check)) {
+			return "0" + isbn13 + string(check), nil
+		}
+	}
+	return "", fmt.Errorf("%q is not an ISBN", s)
+}
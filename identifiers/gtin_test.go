@@ -0,0 +1,112 @@
+package identifiers
+
+import "testing"
+
+func TestValidCheckDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   bool
+	}{
+		{"96385074", true},
+		{"96385075", false},
+		{"036000291452", true},
+		{"036000291453", false},
+		{"4006381333931", true},
+		{"4006381333932", false},
+		{"9780306406157", true},
+		{"10614141000415", true},
+		{"10614141000416", false},
+		{"00", true},
+		{"1", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidCheckDigit(tt.digits); got != tt.want {
+			t.Errorf("ValidCheckDigit(%q) = %v, want %v", tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestGTIN14(t *testing.T) {
+	tests := []struct {
+		gtin string
+		want string
+	}{
+		{"96385074", "00000096385074"},
+		{"036000291452", "00036000291452"},
+		{"0 36000 29145 2", "00036000291452"},
+		{"4006381333931", "04006381333931"},
+		{"400-6381-333931", "04006381333931"},
+		{"10614141000415", "10614141000415"},
+		{"0036000291452", "00036000291452"},
+	}
+	for _, tt := range tests {
+		got, err := GTIN14(tt.gtin)
+		if err != nil || got != tt.want {
+			t.Errorf("GTIN14(%q) = %q, %v, want %q", tt.gtin, got, err, tt.want)
+		}
+	}
+}
+
+func TestGTIN14Rejects(t *testing.T) {
+	tests := []string{
+		"",
+		"4006381333932",
+		"400638133393",
+		"123456789",
+		"40063813339311",
+		"400638133393A",
+		"0000000000000",
+		"000000000000",
+		"00000000",
+		"00000000000000",
+	}
+	for _, gtin := range tests {
+		if got, err := GTIN14(gtin); err == nil {
+			t.Errorf("GTIN14(%q) = %q, want an error", gtin, got)
+		}
+	}
+}
+
+func TestISBN(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want string
+	}{
+		{"0306406152", "09780306406157"},
+		{"0-306-40615-2", "09780306406157"},
+		{"0 306 40615 2", "09780306406157"},
+		{"080442957X", "09780804429573"},
+		{"080442957x", "09780804429573"},
+		{"0-8044-2957-X", "09780804429573"},
+		{"3-16-148410-X", "09783161484100"},
+		{"9780306406157", "09780306406157"},
+		{"978-3-16-148410-0", "09783161484100"},
+		{"979-10-90636-07-1", "09791090636071"},
+	}
+	for _, tt := range tests {
+		got, err := ISBN(tt.isbn)
+		if err != nil || got != tt.want {
+			t.Errorf("ISBN(%q) = %q, %v, want %q", tt.isbn, got, err, tt.want)
+		}
+	}
+}
+
+func TestISBNRejects(t *testing.T) {
+	tests := []string{
+		"",
+		"0306406153",
+		"3-16-148410-0",
+		"030640615",
+		"03064061522",
+		"0X06406152",
+		"X306406152",
+		"9780306406158",
+		"0000000000000",
+	}
+	for _, isbn := range tests {
+		if got, err := ISBN(isbn); err == nil {
+			t.Errorf("ISBN(%q) = %q, want an error", isbn, got)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Package identifiers extracts the GTINs, ISBNs and MPNs of products
+// from their fields and free text, verifies the check digits and
+// normalises GTINs to GTIN-14, so products can be matched across
+// retailers.
+package identifiers
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+// The kinds of Candidates.
+const (
+	KindGTIN = "gtin"
+	KindISBN = "isbn"
+	KindMPN  = "mpn"
+)
+
+// PropertyConflict is the additional property flagging a product with
+// conflicting identifiers, holding the kinds that conflict, e.g.
+// gtin,mpn.
+const PropertyConflict = "identifierConflict"
+
+// Candidate is an identifier found in a product.
+type Candidate struct {
+	Kind string
+	// Raw is the identifier as found, Value the normalised one: a
+	// GTIN-14 for GTINs and ISBNs, the trimmed MPN.
+	Raw   string
+	Value string
+	// Field the identifier was found in.
+	Field string
+	// Labelled is set for identifiers from a field or a label naming
+	// their kind, as opposed to digits found in free text.
+	Labelled bool
+	// Err is why a GTIN or an ISBN is not valid.
+	Err error
+}
+
+// gtinFields name the fields holding GTINs in the crawl records.
+var gtinFields = []string{"gtin", "gtin8", "gtin12", "gtin13", "gtin14", "ean", "upc"}
+
+// textFields are the free text searched for identifiers.
+var textFields = []string{"name", "description"}
+
+// labels are the names of identifiers in free text and additional
+// properties.
+const labels = `EAN(?:-?13)?|UPC(?:-?A)?|GTIN(?:-?(?:8|12|13|14))?|ISBN(?:-?1[03])?|MPN|Part\s*(?:No\.?|Number|#)|Mfr\.?\s*(?:Part\s*)?(?:No\.?|Number|#)|Model\s*(?:No\.?|Number|#)|Herstellernummer|Hersteller-?Nr\.?`
+
+// label matches an identifier after the label of its kind in free
+// text, e.g. "EAN: 4006381333931" or "Part Number 0144522500".
+var label = regexp.MustCompile(`(?i)\b(` + labels + `)\s*[:#]?\s*([0-9A-Z][0-9A-Z\-./]{2,30}[0-9A-Z])`)
+
+// labelName matches the names of additional properties holding an
+// identifier.
+var labelName = regexp.MustCompile(`(?i)^\s*(` + labels + `)\s*:?\s*$`)
+
+// digitRun matches numbers in free text that may be unlabelled GTINs.
+var digitRun = regexp.MustCompile(`\b\d{12,14}\b`)
+
+// kindOfLabel returns the kind of identifier a label names.
+func kindOfLabel(label string) string {
+	label = strings.ToLower(label)
+	switch {
+	case strings.HasPrefix(label, "ean"), strings.HasPrefix(label, "upc"), strings.HasPrefix(label, "gtin"):
+		return KindGTIN
+	case strings.HasPrefix(label, "isbn"):
+		return KindISBN
+	}
+	return KindMPN
+}
+
+// Extract returns the identifier Candidates of the fields of a product:
+// those of the GTIN, isbn and mpn fields, of the additional properties
+// named after an identifier, and those found in the name and the
+// description.
+func Extract(fields map[string]interface{}) []Candidate {
+	var candidates []Candidate
+	for _, field := range gtinFields {
+		for _, raw := range values(fields[field]) {
+			candidates = append(candidates, candidate(KindGTIN, raw, field, true))
+		}
+	}
+	for _, raw := range values(fields["isbn"]) {
+		candidates = append(candidates, candidate(KindISBN, raw, "isbn", true))
+	}
+	for _, raw := range values(fields["mpn"]) {
+		candidates = append(candidates, candidate(KindMPN, raw, "mpn", true))
+	}
+
+	properties, _ := fields["additionalProperty"].([]interface{})
+	for _, item := range properties {
+		property, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := property["name"].(string)
+		value, _ := property["value"].(string)
+		match := labelName.FindStringSubmatch(name)
+		if match == nil || strings.TrimSpace(value) == "" {
+			continue
+		}
+		candidates = append(candidates, candidate(kindOfLabel(match[1]), value, "additionalProperty", true))
+	}
+
+	for _, field := range textFields {
+		text, _ := fields[field].(string)
+		if text == "" {
+			continue
+		}
+		for _, match := range label.FindAllStringSubmatch(text, -1) {
+			candidates = append(candidates, candidate(kindOfLabel(match[1]), match[2], field, true))
+		}
+		for _, digits := range digitRun.FindAllString(text, -1) {
+			if c := candidate(KindGTIN, digits, field, false); c.Err == nil {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	return candidates
+}
+
+// candidate returns the Candidate of kind raw found in field.
+func candidate(kind, raw, field string, labelled bool) Candidate {
+	c := Candidate{Kind: kind, Raw: raw, Field: field, Labelled: labelled}
+	switch kind {
+	case KindGTIN:
+		c.Value, c.Err = GTIN14(raw)
+	case KindISBN:
+		c.Value, c.Err = ISBN(raw)
+	default:
+		c.Value = strings.TrimSpace(raw)
+	}
+	return c
+}
+
+// values returns the strings of a field holding a string, a number, a
+// list of them or objects with a value, as the gtin field of the
+// model.
+func values(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil
+		}
+		return []string{v}
+	case json.Number:
+		return []string{v.String()}
+	case map[string]interface{}:
+		return values(v["value"])
+	case []interface{}:
+		var result []string
+		for _, item := range v {
+			result = append(result, values(item)...)
+		}
+		return result
+	}
+	return nil
+}
+
+// Extractor is a pipeline.Stage setting the gtin field of each product
+// to its valid GTINs as GTIN-14 and its mpn field to the MPN found,
+// when it has none. Invalid GTINs and ISBNs given as such are
+// reported. A product with several GTINs or MPNs is reported and
+// flagged with PropertyConflict. GTINs found as plain digits in free
+// text are only used when there is no other.
+type Extractor struct{}
+
+// New returns a new Extractor
+func New() *Extractor {
+	return &Extractor{}
+}
+
+func (e *Extractor) Name() string {
+	return "identifiers"
+}
+
+func (e *Extractor) Process(record *pipeline.Record) error {
+	if !record.IsProduct {
+		return nil
+	}
+	var gtins, unlabelled, mpns []string
+	seen := map[string]bool{}
+	for _, c := range Extract(record.Fields) {
+		if c.Err != nil {
+			record.Report(c.Field, "%v", c.Err)
+			continue
+		}
+		// GTINs and ISBNs are both GTIN-14s, the same number given as
+		// an EAN and an ISBN is one identifier.
+		key := KindGTIN + ":" + c.Value
+		if c.Kind == KindMPN {
+			key = KindMPN + ":" + compact(c.Value)
+		}
+		if seen[key] || c.Value == "" {
+			continue
+		}
+		seen[key] = true
+		switch {
+		case c.Kind == KindMPN:
+			mpns = append(mpns, c.Value)
+		case c.Labelled:
+			gtins = append(gtins, c.Value)
+		default:
+			unlabelled = append(unlabelled, c.Value)
+		}
+	}
+	if len(gtins) == 0 {
+		gtins = unlabelled
+	}
+
+	var conflicts []string
+	if len(gtins) > 1 {
+		record.Report("gtin", "conflicting GTINs %s", strings.Join(gtins, ", "))
+		conflicts = append(conflicts, KindGTIN)
+	}
+	if len(mpns) > 1 {
+		record.Report("mpn", "conflicting MPNs %s", strings.Join(mpns, ", "))
+		conflicts = append(conflicts, KindMPN)
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		record.SetProperty(PropertyConflict, strings.Join(conflicts, ","))
+	}
+
+	if len(gtins) > 0 {
+		list := make([]interface{}, len(gtins))
+		for i, gtin := range gtins {
+			list[i] = map[string]interface{}{"type": "gtin14", "value": gtin}
+		}
+		record.Fields["gtin"] = list
+	}
+	if mpn, _ := record.Fields["mpn"].(string); strings.TrimSpace(mpn) == "" && len(mpns) > 0 {
+		record.Fields["mpn"] = mpns[0]
+	}
+	return nil
+}
+
+// compact returns an MPN in upper case without separators, so 014-4522
+// and 0144522 are the same.
+func compact(mpn string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "", ".", "", "/", "").Replace(mpn))
+}
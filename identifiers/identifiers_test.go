@@ -0,0 +1,128 @@
+package identifiers
+
+import (
+	"testing"
+
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+func process(t *testing.T, fields map[string]interface{}) *pipeline.Record {
+	t.Helper()
+	record := &pipeline.Record{IsProduct: true, Fields: fields}
+	if err := New().Process(record); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	return record
+}
+
+func gtinValues(record *pipeline.Record) []string {
+	list, _ := record.Fields["gtin"].([]interface{})
+	var result []string
+	for _, item := range list {
+		result = append(result, item.(map[string]interface{})["value"].(string))
+	}
+	return result
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   map[string]interface{}
+		gtins    []string
+		mpn      string
+		conflict string
+		issues   int
+	}{
+		{
+			name:   "ean and upc of the same GTIN",
+			fields: map[string]interface{}{"ean": "0036000291452", "upc": "036000291452"},
+			gtins:  []string{"00036000291452"},
+		},
+		{
+			name:   "ean and isbn of the same number",
+			fields: map[string]interface{}{"ean": "9780306406157", "isbn": "0-306-40615-2"},
+			gtins:  []string{"09780306406157"},
+		},
+		{
+			name:     "two GTINs",
+			fields:   map[string]interface{}{"ean": "4006381333931", "upc": "036000291452"},
+			gtins:    []string{"04006381333931", "00036000291452"},
+			conflict: "gtin",
+			issues:   1,
+		},
+		{
+			name:   "invalid and zero GTINs",
+			fields: map[string]interface{}{"gtin": "4006381333932", "ean": "0000000000000", "upc": "036000291452"},
+			gtins:  []string{"00036000291452"},
+			issues: 2,
+		},
+		{
+			name:   "labelled in the description",
+			fields: map[string]interface{}{"description": "Alder body. EAN: 4006381333931. Mfr. Part No. 014-4522-500"},
+			gtins:  []string{"04006381333931"},
+			mpn:    "014-4522-500",
+		},
+		{
+			name:   "unlabelled digits only without another GTIN",
+			fields: map[string]interface{}{"upc": "036000291452", "description": "Ships as 4006381333931"},
+			gtins:  []string{"00036000291452"},
+		},
+		{
+			name:   "unlabelled digits",
+			fields: map[string]interface{}{"description": "Ships as 4006381333931"},
+			gtins:  []string{"04006381333931"},
+		},
+		{
+			name: "additional property",
+			fields: map[string]interface{}{"additionalProperty": []interface{}{
+				map[string]interface{}{"name": "Herstellernummer", "value": "0144522500"},
+			}},
+			mpn: "0144522500",
+		},
+		{
+			name:   "same MPN written differently",
+			fields: map[string]interface{}{"mpn": "014-4522-500", "description": "Part Number 0144522500"},
+			mpn:    "014-4522-500",
+		},
+		{
+			name:     "two MPNs",
+			fields:   map[string]interface{}{"mpn": "0144522500", "description": "Model No. 0144502500"},
+			mpn:      "0144522500",
+			conflict: "mpn",
+			issues:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := process(t, tt.fields)
+			gtins := gtinValues(record)
+			if len(gtins) != len(tt.gtins) {
+				t.Fatalf("gtin = %v, want %v", gtins, tt.gtins)
+			}
+			for i := range gtins {
+				if gtins[i] != tt.gtins[i] {
+					t.Errorf("gtin = %v, want %v", gtins, tt.gtins)
+				}
+			}
+			if mpn, _ := record.Fields["mpn"].(string); mpn != tt.mpn {
+				t.Errorf("mpn = %q, want %q", mpn, tt.mpn)
+			}
+			if conflict := record.Property(PropertyConflict); conflict != tt.conflict {
+				t.Errorf("%s = %q, want %q", PropertyConflict, conflict, tt.conflict)
+			}
+			if len(record.Issues) != tt.issues {
+				t.Errorf("issues %v, want %d", record.Issues, tt.issues)
+			}
+		})
+	}
+}
+
+func TestProcessSkipsArticles(t *testing.T) {
+	record := &pipeline.Record{Fields: map[string]interface{}{"ean": "4006381333931"}}
+	if err := New().Process(record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Fields["ean"] != "4006381333931" || record.Fields["gtin"] != nil {
+		t.Errorf("article changed to %v", record.Fields)
+	}
+}
@@ -0,0 +1,136 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/logging"
+	"github.com/chuxorg/chux-parser/parsing"
+	"github.com/chuxorg/chux-parser/s3"
+)
+
+// ObjectOpener opens single objects for streaming. It is implemented
+// by s3.Bucket.
+type ObjectOpener interface {
+	OpenObject(key string) (*s3.File, io.ReadCloser, error)
+}
+
+// StreamParser parses a File from a stream. It is implemented by
+// parsing.Parser.
+type StreamParser interface {
+	ParseStream(file s3.File, r io.Reader) parsing.ParseResult
+}
+
+// StatusWriter records the Status of parsed objects. It is
+// implemented by s3.Bucket.
+type StatusWriter interface {
+	SetStatus(key string, status s3.Status) error
+}
+
+// Handler is the AWS Lambda entry point for per-object parsing. It is
+// invoked with the S3 event of a single new object and streams that
+// object through the same Bucket and Parser the batch run uses.
+type Handler struct {
+	Source ObjectOpener
+	Parser StreamParser
+	// BucketName, when set, rejects events of any other bucket.
+	BucketName string
+	// Status, when set, records the result of each object with RunID.
+	Status StatusWriter
+	RunID  string
+	Logger *logging.Logger
+}
+
+func New(options ...func(*Handler)) *Handler {
+
+	handler := &Handler{}
+	for _, option := range options {
+		option(handler)
+	}
+	handler.Logger.Debug("Creating new Handler struct")
+	return handler
+}
+
+func WithSource(source ObjectOpener) func(*Handler) {
+	return func(h *Handler) {
+		h.Source = source
+	}
+}
+
+func WithParser(parser StreamParser) func(*Handler) {
+	return func(h *Handler) {
+		h.Parser = parser
+	}
+}
+
+func WithBucketName(name string) func(*Handler) {
+	return func(h *Handler) {
+		h.BucketName = name
+	}
+}
+
+func WithStatus(status StatusWriter, runID string) func(*Handler) {
+	return func(h *Handler) {
+		h.Status = status
+		h.RunID = runID
+	}
+}
+
+func WithLogger(l *logging.Logger) func(*Handler) {
+	return func(h *Handler) {
+		h.Logger = l
+	}
+}
+
+// Handle parses the object named by an S3 ObjectCreated event. S3
+// delivers one record per invocation, events with any other number
+// of ObjectCreated records are rejected. An object of a company that
+// is not parsed yields an empty ParseResult.
+func (h *Handler) Handle(ctx context.Context, event s3.Event) (parsing.ParseResult, error) {
+	h.Logger.Debug("Handler.Handle() called")
+
+	var records []s3.EventRecord
+	for _, record := range event.Records {
+		if record.IsObjectCreated() {
+			records = append(records, record)
+		}
+	}
+	if len(records) != 1 {
+		msg := fmt.Sprintf("Handler.Handle() expected one ObjectCreated record, got %d", len(records))
+		return parsing.ParseResult{}, errors.NewChuxParserError(msg, nil)
+	}
+	record := records[0]
+	if h.BucketName != "" && record.S3.Bucket.Name != h.BucketName {
+		msg := fmt.Sprintf("Handler.Handle() event for bucket %s, expected %s", record.S3.Bucket.Name, h.BucketName)
+		return parsing.ParseResult{}, errors.NewChuxParserError(msg, nil)
+	}
+
+	key, err := record.ObjectKey()
+	if err != nil {
+		return parsing.ParseResult{}, errors.NewChuxParserError("Handler.Handle() Invalid object key "+record.S3.Object.Key, err)
+	}
+	file, body, err := h.Source.OpenObject(key)
+	if err != nil {
+		return parsing.ParseResult{}, err
+	}
+	if file == nil {
+		h.Logger.Info("Handler.Handle() Skipped %s", key)
+		return parsing.ParseResult{Path: key}, nil
+	}
+	defer body.Close()
+
+	result := h.Parser.ParseStream(*file, body)
+	if h.Status != nil && !result.Quarantined {
+		// Failing to tag the object does not fail the invocation.
+		if err := h.Status.SetStatus(key, result.Status(h.RunID)); err != nil {
+			h.Logger.Warning("Handler.Handle() %v", err)
+		}
+	}
+	if result.Err != nil {
+		return result, result.Err
+	}
+	h.Logger.Info("Handler.Handle() Parsed %s: %d Products, %d Articles, %d failed, %d skipped, %d retries", key, result.Products, result.Articles, result.Failed, result.Skipped, result.Retries)
+	return result, nil
+}
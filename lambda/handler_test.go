@@ -0,0 +1,196 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/chuxorg/chux-parser/parsing"
+	"github.com/chuxorg/chux-parser/s3"
+)
+
+// fakeSource serves a single object, or skips every key when file is
+// nil.
+type fakeSource struct {
+	file   *s3.File
+	body   string
+	opened []string
+	closed bool
+}
+
+func (s *fakeSource) OpenObject(key string) (*s3.File, io.ReadCloser, error) {
+	s.opened = append(s.opened, key)
+	if s.file == nil {
+		return nil, nil, nil
+	}
+	return s.file, &closer{Reader: strings.NewReader(s.body), closed: &s.closed}, nil
+}
+
+type closer struct {
+	io.Reader
+	closed *bool
+}
+
+func (c *closer) Close() error {
+	*c.closed = true
+	return nil
+}
+
+// fakeParser reads the whole stream and returns result.
+type fakeParser struct {
+	result parsing.ParseResult
+	files  []s3.File
+	read   string
+}
+
+func (p *fakeParser) ParseStream(file s3.File, r io.Reader) parsing.ParseResult {
+	p.files = append(p.files, file)
+	data, _ := io.ReadAll(r)
+	p.read = string(data)
+	result := p.result
+	result.Path = file.Path
+	return result
+}
+
+type fakeStatus struct {
+	keys     []string
+	statuses []s3.Status
+}
+
+func (s *fakeStatus) SetStatus(key string, status s3.Status) error {
+	s.keys = append(s.keys, key)
+	s.statuses = append(s.statuses, status)
+	return nil
+}
+
+func loadEvent(t *testing.T) s3.Event {
+	t.Helper()
+	data, err := os.ReadFile("testdata/s3-put.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event s3.Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+const fixtureKey = "sweetwater/products-2023-05-20.jl"
+
+func TestHandle(t *testing.T) {
+	source := &fakeSource{
+		file: &s3.File{Path: fixtureKey, Company: "sweetwater", IsProduct: true},
+		body: `{"name":"a"}` + "\n" + `{"name":"b"}` + "\n",
+	}
+	parser := &fakeParser{result: parsing.ParseResult{Products: 2, Parsed: true}}
+	status := &fakeStatus{}
+	handler := New(
+		WithSource(source),
+		WithParser(parser),
+		WithBucketName("chux-crawler"),
+		WithStatus(status, "run-1"),
+	)
+
+	result, err := handler.Handle(context.Background(), loadEvent(t))
+	if err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if result.Path != fixtureKey || result.Products != 2 {
+		t.Errorf("Handle() = %+v, want 2 Products of %s", result, fixtureKey)
+	}
+	if len(source.opened) != 1 || source.opened[0] != fixtureKey {
+		t.Errorf("opened %v, want [%s]", source.opened, fixtureKey)
+	}
+	if !source.closed {
+		t.Error("object body was not closed")
+	}
+	if parser.read != source.body {
+		t.Errorf("parser read %q, want %q", parser.read, source.body)
+	}
+	if len(parser.files) != 1 || parser.files[0].Company != "sweetwater" {
+		t.Errorf("parsed files %+v, want the sweetwater File", parser.files)
+	}
+	want := s3.Status{State: s3.StatusParsed, RunID: "run-1", Products: 2}
+	if len(status.statuses) != 1 || status.keys[0] != fixtureKey || status.statuses[0] != want {
+		t.Errorf("status %v %+v, want %s %+v", status.keys, status.statuses, fixtureKey, want)
+	}
+}
+
+func TestHandleFailedParse(t *testing.T) {
+	source := &fakeSource{file: &s3.File{Path: fixtureKey, IsProduct: true}}
+	readErr := errors.New("connection reset")
+	parser := &fakeParser{result: parsing.ParseResult{Failed: 1, Err: readErr}}
+	status := &fakeStatus{}
+	handler := New(WithSource(source), WithParser(parser), WithStatus(status, "run-1"))
+
+	_, err := handler.Handle(context.Background(), loadEvent(t))
+	if err != readErr {
+		t.Errorf("Handle() error = %v, want %v", err, readErr)
+	}
+	if len(status.statuses) != 1 || status.statuses[0].State != s3.StatusFailed {
+		t.Errorf("status %+v, want %s", status.statuses, s3.StatusFailed)
+	}
+}
+
+func TestHandleQuarantined(t *testing.T) {
+	source := &fakeSource{file: &s3.File{Path: fixtureKey, IsProduct: true}}
+	parser := &fakeParser{result: parsing.ParseResult{Quarantined: true}}
+	status := &fakeStatus{}
+	handler := New(WithSource(source), WithParser(parser), WithStatus(status, "run-1"))
+
+	if _, err := handler.Handle(context.Background(), loadEvent(t)); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if len(status.statuses) != 0 {
+		t.Errorf("quarantined object was tagged %+v", status.statuses)
+	}
+}
+
+func TestHandleSkipped(t *testing.T) {
+	parser := &fakeParser{}
+	handler := New(WithSource(&fakeSource{}), WithParser(parser))
+
+	result, err := handler.Handle(context.Background(), loadEvent(t))
+	if err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if result.Path != fixtureKey || len(parser.files) != 0 {
+		t.Errorf("Handle() = %+v and parsed %d files, want an empty result", result, len(parser.files))
+	}
+}
+
+func TestHandleRejectsEvents(t *testing.T) {
+	otherBucket := loadEvent(t)
+	removed := loadEvent(t)
+	removed.Records[0].EventName = "ObjectRemoved:Delete"
+	twice := loadEvent(t)
+	twice.Records = append(twice.Records, twice.Records[0])
+
+	tests := []struct {
+		name   string
+		bucket string
+		event  s3.Event
+	}{
+		{"other bucket", "other-bucket", otherBucket},
+		{"no ObjectCreated record", "", removed},
+		{"two records", "", twice},
+		{"no records", "", s3.Event{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeSource{file: &s3.File{Path: fixtureKey}}
+			handler := New(WithSource(source), WithParser(&fakeParser{}), WithBucketName(tt.bucket))
+			if _, err := handler.Handle(context.Background(), tt.event); err == nil {
+				t.Error("Handle() error = nil, want an error")
+			}
+			if len(source.opened) != 0 {
+				t.Errorf("opened %v of a rejected event", source.opened)
+			}
+		})
+	}
+}
@@ -1,123 +1,634 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
+	awslambda "github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/chuxorg/chux-parser/awsauth"
+	"github.com/chuxorg/chux-parser/brands"
+	"github.com/chuxorg/chux-parser/changes"
+	"github.com/chuxorg/chux-parser/config"
+	"github.com/chuxorg/chux-parser/dedup"
+	"github.com/chuxorg/chux-parser/identifiers"
+	"github.com/chuxorg/chux-parser/lambda"
 	"github.com/chuxorg/chux-parser/logging"
+	"github.com/chuxorg/chux-parser/mapping"
+	"github.com/chuxorg/chux-parser/mongodb"
 	"github.com/chuxorg/chux-parser/parsing"
+	"github.com/chuxorg/chux-parser/pipeline"
+	"github.com/chuxorg/chux-parser/price"
+	"github.com/chuxorg/chux-parser/queue"
 	"github.com/chuxorg/chux-parser/s3"
+	"github.com/chuxorg/chux-parser/schema"
+	"github.com/chuxorg/chux-parser/secrets"
+	"github.com/chuxorg/chux-parser/taxonomy"
+	"github.com/chuxorg/chux-parser/urls"
 )
 
 var logFileMutex sync.Mutex
 var logFile *os.File
 var logger *logging.Logger
 
+// unmapped collects the unmapped categories of a run with the file
+// taxonomy report, see writeUnmapped.
+var unmapped *taxonomy.MemoryReport
+
+const usage = `usage: chux-parser [command] [flags]
+
+commands:
+  run            download and parse every object of the source bucket (default)
+  consume        parse objects as their S3 notifications arrive on a queue
+  lambda [event] serve the Lambda handler, or invoke it once with an S3 event file
+  config check   report missing or invalid settings
+  quarantine list
+                 list the quarantined objects and why they were quarantined
+  quarantine release key...
+                 return quarantined objects to be parsed by the next run
+
+flags:
+`
+
 func main() {
 
-	os.Setenv("AWS_REGION", "us-east-1")
-	os.Setenv("LOG_LEVEL", "0")
-	logger = logging.NewLogger(logging.LogLevelDebug)
-	err := fetchAndSetSecrets("dev/secrets")
+	command, args := parseCommand(os.Args[1:])
+	cfg, err := config.Load(args)
 	if err != nil {
-		log.Fatalf("failed to fetch and set secrets: %v", err)
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usage)
+		config.PrintDefaults(os.Stderr)
+		os.Exit(2)
+	}
+
+	logger = logging.NewLogger(logging.LogLevel(cfg.Log.Level))
+	sess, err := awsauth.New(cfg.AWS)
+	if err != nil {
+		log.Fatalf("failed to create AWS session: %v", err)
+	}
+
+	if command == "config" {
+		os.Exit(runConfig(cfg, sess))
+	}
+
+	if err := loadSecrets(cfg, sess); err != nil {
+		log.Fatalf("failed to load secrets: %v", err)
+	}
+	if problems := cfg.Validate(); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		log.Fatalf("invalid configuration, run chux-parser config check")
+	}
+	exportModelSettings(cfg.Mongo)
+	if cfg.Tags.RunID == "" {
+		cfg.Tags.RunID = newRunID()
+	}
+	if command == "quarantine" {
+		os.Exit(runQuarantine(cfg, sess))
+	}
+	// The Mongo stores of the pipeline stages share one client, which
+	// connects on first use.
+	client := mongodb.New(cfg.Mongo.ConnectionURI(), cfg.Mongo.Database)
+	defer disconnect(client)
+
+	// Lambda logs to stdout, which ends up in CloudWatch, so the
+	// log file is only set up for the other modes.
+	if command == "lambda" {
+		runLambda(cfg, sess, client)
+		return
 	}
 	fmt.Print("Setting up logging...")
-	setUpLogging()
+	setUpLogging(cfg)
 	defer closeLogFile()
 	logger.Debug("Logging set up")
 	logger.Info("Logging set up")
-	bucket := s3.New(
-		s3.WithLogger(logger),
-	)
+
+	switch command {
+	case "consume":
+		runConsumer(cfg, sess, client)
+	default:
+		runBatch(cfg, sess, client)
+	}
+}
+
+// parseCommand splits the command from its flags. Inside Lambda the
+// command is always lambda.
+func parseCommand(args []string) (string, []string) {
+	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
+		return "lambda", nil
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "run", args
+	}
+	switch args[0] {
+	case "run", "consume", "lambda", "config", "quarantine":
+		return args[0], args[1:]
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+	config.PrintDefaults(os.Stderr)
+	os.Exit(2)
+	return "", nil
+}
+
+// runBatch downloads and parses every object of the source bucket.
+func runBatch(cfg *config.Config, sess *session.Session, client *mongodb.Client) {
+	bucket := newBucket(cfg, sess)
 
 	files, err := bucket.Download()
 	if err != nil {
-		logger.Error("Failed to download files from S3", err)
+		logger.Error("Failed to download files from S3: %v", err)
 		panic(err)
 	}
 
-	parser := parsing.New(
-		parsing.WithLogger(logger),
-	)
+	parser := newParser(cfg, sess, bucket, client)
 	logger.Info("Parsing %d Products and Articles", len(files))
 	startTime := time.Now()
+	retries := 0
 	for _, f := range files {
-		parser.Parse(f)
+		result := parser.Parse(f)
+		retries += result.Retries
+		if cfg.Tags.Enabled && !result.Quarantined {
+			if err := bucket.SetStatus(f.Path, result.Status(cfg.Tags.RunID)); err != nil {
+				logger.Warning("Failed to tag %s: %v", f.Path, err)
+			}
+		}
 	}
 	elapsedTime := time.Since(startTime).Seconds()
-	logger.Info("Parsed %d Articles and Products in %d seconds", len(files), elapsedTime)
+	logger.Info("Parsed %d Articles and Products in %.2f seconds with %d retries", len(files), elapsedTime, retries)
+	writeUnmapped(cfg)
 }
 
-func setUpLogging() {
+// runConsumer parses objects as their S3 ObjectCreated notifications
+// arrive on a queue, until the process is interrupted. Without an
+// SQS queue url, notifications are read from the files of a local
+// directory instead.
+func runConsumer(cfg *config.Config, sess *session.Session, client *mongodb.Client) {
+	if problems := cfg.ValidateQueue(); len(problems) > 0 {
+		log.Fatalf("consume: %v", problems[0])
+	}
 
-	var err error
+	var q queue.Queue
+	if cfg.Queue.Dir != "" {
+		q = queue.NewDir(cfg.Queue.Dir, cfg.Queue.VisibilityTimeout)
+	} else {
+		q = queue.NewSQS(
+			queue.SQSWithURL(cfg.Queue.URL),
+			queue.SQSWithSession(sess),
+			queue.SQSWithLogger(logger),
+		)
+	}
 
-	logDir := "logs/chux-cprs/"
-	err = os.MkdirAll(logDir, 0755) // Set permissions to 0755
-	if err != nil {
-		log.Fatalf("Error creating log directory: %v", err)
+	bucket := newBucket(cfg, sess)
+	options := []func(*queue.Consumer){
+		queue.ConsumerWithQueue(q),
+		queue.ConsumerWithSource(bucket),
+		queue.ConsumerWithParser(newParser(cfg, sess, bucket, client)),
+		queue.ConsumerWithBucketName(bucket.Name),
+		queue.ConsumerWithVisibilityTimeout(cfg.Queue.VisibilityTimeout),
+		queue.ConsumerWithLogger(logger),
 	}
+	if cfg.Tags.Enabled {
+		options = append(options, queue.ConsumerWithStatus(bucket, cfg.Tags.RunID))
+	}
+	consumer := queue.NewConsumer(options...)
 
-	// Open the log file
-	logFilePath := filepath.Join(logDir, "chux-parser.log")
-	logFile, err = os.OpenFile(logFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Fatalf("Error opening log file: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := consumer.Run(ctx); err != nil {
+		logger.Error("Consumer stopped: %v", err)
+	}
+	writeUnmapped(cfg)
+}
+
+// runLambda serves the per-object Lambda handler. With an event file
+// argument the handler is invoked once with the S3 event of the file
+// and the ParseResult is printed, which runs it locally without Lambda.
+func runLambda(cfg *config.Config, sess *session.Session, client *mongodb.Client) {
+	bucket := newBucket(cfg, sess)
+	options := []func(*lambda.Handler){
+		lambda.WithSource(bucket),
+		lambda.WithParser(newParser(cfg, sess, bucket, client)),
+		lambda.WithBucketName(bucket.Name),
+		lambda.WithLogger(logger),
+	}
+	if cfg.Tags.Enabled {
+		options = append(options, lambda.WithStatus(bucket, cfg.Tags.RunID))
+	}
+	handler := lambda.New(options...)
+
+	if len(cfg.Args()) == 0 {
+		awslambda.Start(handler.Handle)
+		return
 	}
 
-	logLevel, err := strconv.Atoi(os.Getenv("LOG_LEVEL"))
+	data, err := os.ReadFile(cfg.Args()[0])
 	if err != nil {
-		logLevel = int(logging.LogLevelInfo)
+		log.Fatalf("failed to read event: %v", err)
 	}
+	var event s3.Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		log.Fatalf("failed to decode event: %v", err)
+	}
+	result, err := handler.Handle(context.Background(), event)
+	if err != nil {
+		log.Fatalf("handler failed: %v", err)
+	}
+	out, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(out))
+}
 
-	logger = logging.NewLogger(logging.LogLevel(logLevel))
-	logger.SetOutput(logFile)
+// runConfig runs the config subcommands and returns the exit code.
+// config check loads every layer including secrets and reports each
+// missing or invalid setting.
+func runConfig(cfg *config.Config, sess *session.Session) int {
+	if len(cfg.Args()) != 1 || cfg.Args()[0] != "check" {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	if cfg.File != "" {
+		fmt.Printf("configuration file: %s\n", cfg.File)
+	}
+	// Every check runs, so all problems are reported at once.
+	problems := 0
+	if err := loadSecrets(cfg, sess); err != nil {
+		fmt.Printf("secrets: %v\n", err)
+		problems++
+	}
+	for _, problem := range cfg.Validate() {
+		fmt.Println(problem)
+		problems++
+	}
+	if cfg.Mapping.File != "" {
+		if _, err := mapping.Load(cfg.Mapping.File); err != nil {
+			fmt.Printf("mapping: %v\n", err)
+			problems++
+		}
+	}
+	if cfg.Brands.File != "" {
+		if _, err := brands.Load(cfg.Brands.File); err != nil {
+			fmt.Printf("brands: %v\n", err)
+			problems++
+		}
+	}
+	if cfg.Taxonomy.File != "" {
+		if _, err := taxonomy.Load(cfg.Taxonomy.File); err != nil {
+			fmt.Printf("taxonomy: %v\n", err)
+			problems++
+		}
+	}
+	if cfg.Prices.BaseCurrency != "" {
+		rates, err := price.LoadRates(cfg.Prices.RatesFile)
+		if err == nil && !rates.Has(cfg.Prices.BaseCurrency) {
+			err = fmt.Errorf("%s has no rate of %s", cfg.Prices.RatesFile, cfg.Prices.BaseCurrency)
+		}
+		if err != nil {
+			fmt.Printf("prices: %v\n", err)
+			problems++
+		}
+	}
+	if cfg.Schema.Dir != "" {
+		if err := newValidator(cfg).Check(); err != nil {
+			fmt.Printf("schema: %v\n", err)
+			problems++
+		}
+	}
+	if problems > 0 {
+		fmt.Printf("%d problem(s) found\n", problems)
+		return 1
+	}
+	fmt.Printf("configuration OK: bucket %s in %s, database %s\n", cfg.AWS.SourceBucket, cfg.AWS.Region, cfg.Mongo.MaskedURI())
+	fmt.Printf("AWS credentials: %s\n", awsauth.Describe(cfg.AWS))
+	if cfg.AWS.S3Endpoint != "" {
+		fmt.Printf("S3 endpoint: %s\n", cfg.AWS.S3Endpoint)
+	}
+	return 0
+}
+
+// runQuarantine runs the quarantine subcommands and returns the exit
+// code.
+func runQuarantine(cfg *config.Config, sess *session.Session) int {
+	args := cfg.Args()
+	bucket := newBucket(cfg, sess)
+	bucket.QuarantinePrefix = cfg.Quarantine.Prefix
+
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		reports, err := bucket.Quarantined()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, report := range reports {
+			fmt.Printf("%s\t%s\t%s\t%s\n", report.Key, report.Reason, report.Time.Format(time.RFC3339), report.Error)
+		}
+		fmt.Printf("%d quarantined object(s)\n", len(reports))
+		return 0
+	case len(args) > 1 && args[0] == "release":
+		status := 0
+		for _, key := range args[1:] {
+			if err := bucket.Release(key); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+				continue
+			}
+			fmt.Printf("released %s\n", key)
+		}
+		return status
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return 2
+}
+
+func newBucket(cfg *config.Config, sess *session.Session) *s3.Bucket {
+	options := []func(*s3.Bucket){
+		s3.WithName(cfg.AWS.SourceBucket),
+		s3.WithRegion(cfg.AWS.Region),
+		s3.WithProfile(cfg.AWS.Profile),
+		s3.WithEndpoint(cfg.AWS.S3Endpoint),
+		s3.WithPathStyle(cfg.AWS.S3ForcePathStyle),
+		s3.WithDisableSSL(cfg.AWS.S3DisableSSL),
+		s3.WithPartSize(cfg.AWS.S3PartSize),
+		s3.WithConcurrency(cfg.AWS.S3Concurrency),
+		s3.WithVerify(cfg.AWS.S3Verify),
+		s3.WithFilter(s3.ParseStatusFilter(cfg.Tags.Only, cfg.Tags.Skip)),
+		s3.WithDownloadPath(cfg.AWS.DownloadPath),
+		s3.WithSession(awsauth.WithRole(sess, cfg.AWS.AssumeRole)),
+		s3.WithFormat(cfg.Format()),
+		s3.WithCharset(cfg.Parse.Charset),
+		s3.WithMaxRecordSize(cfg.Parse.MaxRecordSize),
+		s3.WithDeadLetter(cfg.Parse.DeadLetterPrefix),
+		s3.WithRetry(cfg.Retry.Policy()),
+		s3.WithRunID(cfg.Tags.RunID),
+		s3.WithLogger(logger),
+	}
+	if cfg.Quarantine.Enabled {
+		options = append(options, s3.WithQuarantine(cfg.Quarantine.Prefix, cfg.Quarantine.Move))
+	}
+	return s3.New(options...)
+}
+
+func newParser(cfg *config.Config, sess *session.Session, bucket *s3.Bucket, client *mongodb.Client) *parsing.Parser {
+	options := []func(*parsing.Parser){
+		parsing.WithFormat(cfg.Format()),
+		parsing.WithDownloadPath(cfg.Parse.DownloadPath),
+		parsing.WithCharset(cfg.Parse.Charset),
+		parsing.WithMaxRecordSize(cfg.Parse.MaxRecordSize),
+		parsing.WithRetry(cfg.Retry.Policy()),
+		parsing.WithLogger(logger),
+	}
+	if cfg.Parse.DeadLetterPrefix != "" {
+		options = append(options, parsing.WithDeadLetter(bucket))
+	}
+	if stages := newPipeline(cfg, sess, client); len(stages.Stages) > 0 {
+		options = append(options, parsing.WithPipeline(stages))
+	}
+	if cfg.Quarantine.Enabled {
+		options = append(options, parsing.WithQuarantine(bucket, cfg.Quarantine.FailurePercent, cfg.Quarantine.MinRecords))
+	}
+	return parsing.New(options...)
+}
+
+// newPipeline returns the pipeline.Pipeline of the enabled record
+// stages, whose Mongo stores use client.
+func newPipeline(cfg *config.Config, sess *session.Session, client *mongodb.Client) *pipeline.Pipeline {
+	var options []func(*pipeline.Pipeline)
+	// Records are mapped first and validated right after, so the
+	// schemas describe the mapped fields as the spiders emit them,
+	// before the other stages normalise them.
+	if cfg.Mapping.File != "" {
+		mappings, err := mapping.Load(cfg.Mapping.File)
+		if err != nil {
+			log.Fatalf("failed to load field mappings: %v", err)
+		}
+		options = append(options, pipeline.WithStage(mapping.New(mapping.WithMappings(mappings))))
+	}
+	if cfg.Schema.Dir != "" {
+		options = append(options, pipeline.WithStage(newValidator(cfg)))
+	}
+	if cfg.URLs.Enabled {
+		options = append(options, pipeline.WithStage(newCanonicalizer(cfg)))
+	}
+	if cfg.Prices.Enabled {
+		options = append(options, pipeline.WithStage(newNormalizer(cfg)))
+	}
+	// Brands are extracted before the deduplication, which groups
+	// products by brand and MPN.
+	if cfg.Brands.File != "" {
+		dictionary, err := brands.Load(cfg.Brands.File)
+		if err != nil {
+			log.Fatalf("failed to load brands: %v", err)
+		}
+		options = append(options, pipeline.WithStage(brands.New(dictionary)))
+	}
+	if cfg.Identifiers.Enabled {
+		options = append(options, pipeline.WithStage(identifiers.New()))
+	}
+	if cfg.Taxonomy.File != "" {
+		options = append(options, pipeline.WithStage(newTaxonomyMapper(cfg, client)))
+	}
+	if cfg.Changes.Enabled {
+		options = append(options, pipeline.WithStage(newTracker(cfg, sess, client)))
+	}
+	// Deduplication comes last, so it hashes the records as they are
+	// saved.
+	if cfg.Dedup.Enabled {
+		options = append(options, pipeline.WithStage(newDeduplicator(cfg, client)))
+	}
+	return pipeline.New(options...)
+}
+
+func newCanonicalizer(cfg *config.Config) *urls.Canonicalizer {
+	var options []func(*urls.Canonicalizer)
+	for _, param := range strings.Split(cfg.URLs.StripParams, ",") {
+		if param = strings.TrimSpace(param); param != "" {
+			options = append(options, urls.WithParams(param))
+		}
+	}
+	for company, params := range cfg.URLs.Retailers {
+		options = append(options, urls.WithRetailer(company, params...))
+	}
+	return urls.New(options...)
+}
+
+func newNormalizer(cfg *config.Config) *price.Normalizer {
+	options := []func(*price.Normalizer){
+		price.WithDefaultCurrency(cfg.Prices.DefaultCurrency),
+		price.WithDomains(cfg.Prices.Domains),
+	}
+	if cfg.Prices.BaseCurrency != "" {
+		rates, err := price.LoadRates(cfg.Prices.RatesFile)
+		if err != nil {
+			log.Fatalf("failed to load exchange rates: %v", err)
+		}
+		options = append(options, price.WithBaseCurrency(cfg.Prices.BaseCurrency, rates))
+	}
+	return price.New(options...)
+}
+
+func newTracker(cfg *config.Config, sess *session.Session, client *mongodb.Client) *changes.Tracker {
+	options := []func(*changes.Tracker){
+		changes.WithLogger(logger),
+	}
+	if cfg.Changes.Store == "mongo" {
+		store := changes.NewMongoStore(client, cfg.Changes.Versions, cfg.Changes.History)
+		options = append(options, changes.WithStore(store))
+	}
+	if cfg.Changes.TopicARN != "" {
+		options = append(options, changes.WithPublisher(changes.NewSNSPublisher(sess, cfg.Changes.TopicARN)))
+	}
+	var fields []string
+	for _, field := range strings.Split(cfg.Changes.Fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) > 0 {
+		options = append(options, changes.WithFields(fields...))
+	}
+	return changes.New(options...)
 }
 
-func fetchAndSetSecrets(secretID string) error {
+func newDeduplicator(cfg *config.Config, client *mongodb.Client) *dedup.Deduplicator {
+	options := []func(*dedup.Deduplicator){
+		dedup.WithLogger(logger),
+	}
+	if cfg.Dedup.Store == "mongo" {
+		store := dedup.NewMongoStore(client, cfg.Dedup.Collection)
+		options = append(options, dedup.WithStore(store))
+	}
+	for _, field := range strings.Split(cfg.Dedup.IgnoreFields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			options = append(options, dedup.WithIgnore(field))
+		}
+	}
+	return dedup.New(options...)
+}
 
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String("us-east-1"),
-	})
+func newTaxonomyMapper(cfg *config.Config, client *mongodb.Client) *taxonomy.Mapper {
+	tree, err := taxonomy.Load(cfg.Taxonomy.File)
 	if err != nil {
-		return fmt.Errorf("failed to create AWS session: %v", err)
+		log.Fatalf("failed to load taxonomy: %v", err)
+	}
+	options := []func(*taxonomy.Mapper){
+		taxonomy.WithTaxonomy(tree),
+		taxonomy.WithThreshold(float64(cfg.Taxonomy.Similarity) / 100),
+	}
+	if cfg.Taxonomy.Report == "mongo" {
+		report := taxonomy.NewMongoReport(client, cfg.Taxonomy.Collection)
+		options = append(options, taxonomy.WithReport(report))
+	} else {
+		unmapped = taxonomy.NewMemoryReport()
+		options = append(options, taxonomy.WithReport(unmapped))
 	}
+	return taxonomy.New(options...)
+}
 
-	svc := secretsmanager.New(sess)
-	input := &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(secretID),
+// disconnect closes the connections of the client of the Mongo stores.
+func disconnect(client *mongodb.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Disconnect(ctx); err != nil {
+		logger.Warning("Failed to disconnect from MongoDB: %v", err)
 	}
+}
 
-	result, err := svc.GetSecretValue(input)
+// writeUnmapped writes the unmapped categories of the run to the
+// taxonomy report file.
+func writeUnmapped(cfg *config.Config) {
+	if unmapped == nil {
+		return
+	}
+	if err := unmapped.WriteFile(cfg.Taxonomy.ReportFile); err != nil {
+		logger.Warning("Failed to write the unmapped categories: %v", err)
+		return
+	}
+	if n := len(unmapped.Unmapped()); n > 0 {
+		logger.Info("Wrote %d unmapped categories to %s", n, cfg.Taxonomy.ReportFile)
+	}
+}
+
+func newValidator(cfg *config.Config) *schema.Validator {
+	return schema.New(
+		schema.WithDir(cfg.Schema.Dir),
+		schema.WithMode(cfg.Schema.Mode),
+		schema.WithLogger(logger),
+	)
+}
+
+// loadSecrets fetches the values of the configured secrets provider
+// into the configuration.
+func loadSecrets(cfg *config.Config, sess *session.Session) error {
+	if cfg.Secrets.Provider == config.SecretsNone {
+		return nil
+	}
+	provider, err := secrets.Open(cfg.Secrets.Provider, cfg.Secrets.ID, sess)
 	if err != nil {
-		return fmt.Errorf("failed to get secret value: %v", err)
+		return err
 	}
 
-	var secrets map[string]string
-	err = json.Unmarshal([]byte(*result.SecretString), &secrets)
+	values, err := provider.Fetch(context.Background())
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal secrets: %v", err)
+		return err
 	}
+	logger.Info("Loaded %d secrets from %s", len(values), provider.Name())
+	return cfg.ApplySecrets(values)
+}
 
-	for key, value := range secrets {
-		err = os.Setenv(key, value)
-		fmt.Printf("Setting env variable: %s\n", key)
-		if err != nil {
-			return fmt.Errorf("failed to set environment variable: %v", err)
-		}
+// newRunID returns an id for the tags of the objects processed by this
+// run, made of the start time and a random suffix.
+func newRunID() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+// exportModelSettings makes the Mongo settings available to
+// chux-models. Its models take no settings, GetURI and GetDatabaseName
+// read them from the environment, so this is the one place settings
+// are put into the environment, and only these four. Once chux-models
+// accepts them explicitly they are passed instead.
+func exportModelSettings(mongo config.Mongo) {
+	exports := map[string]string{
+		"MONGO_URI":       mongo.URI,
+		"MONGO_USER_NAME": mongo.User,
+		"MONGO_PASSWORD":  mongo.Password,
+		"MONGO_DATABASE":  mongo.Database,
+	}
+	for name, value := range exports {
+		os.Setenv(name, value)
+	}
+}
+
+func setUpLogging(cfg *config.Config) {
+
+	var err error
+
+	err = os.MkdirAll(cfg.Log.Dir, 0755) // Set permissions to 0755
+	if err != nil {
+		log.Fatalf("Error creating log directory: %v", err)
+	}
+
+	// Open the log file
+	logFilePath := filepath.Join(cfg.Log.Dir, "chux-parser.log")
+	logFile, err = os.OpenFile(logFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalf("Error opening log file: %v", err)
 	}
 
-	return nil
+	logger = logging.NewLogger(logging.LogLevel(cfg.Log.Level))
+	logger.SetOutput(logFile)
 }
 
 func closeLogFile() {
@@ -0,0 +1,204 @@
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// segments splits a dot-separated path.
+func segments(path string) []string {
+	return strings.Split(path, ".")
+}
+
+func lastSegment(path string) string {
+	parts := segments(path)
+	return parts[len(parts)-1]
+}
+
+// get returns the value at path.
+func get(fields map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = fields
+	for _, segment := range segments(path) {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			current = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// set stores value at path, creating the objects and arrays on the
+// way. A numeric segment indexes an array.
+func set(fields map[string]interface{}, path string, value interface{}) error {
+	// fields itself is updated in place
+	if _, err := setIn(fields, segments(path), value); err != nil {
+		return fmt.Errorf("cannot set %s: %v", path, err)
+	}
+	return nil
+}
+
+// setIn returns node with value stored at parts.
+func setIn(node interface{}, parts []string, value interface{}) (interface{}, error) {
+	if len(parts) == 0 {
+		return value, nil
+	}
+	segment := parts[0]
+	if i, err := strconv.Atoi(segment); err == nil && i >= 0 {
+		array, ok := node.([]interface{})
+		if node != nil && !ok {
+			return nil, fmt.Errorf("%s indexes a value that is not an array", segment)
+		}
+		for len(array) <= i {
+			array = append(array, nil)
+		}
+		child, err := setIn(array[i], parts[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		array[i] = child
+		return array, nil
+	}
+	object, ok := node.(map[string]interface{})
+	if node != nil && !ok {
+		return nil, fmt.Errorf("%s names a field of a value that is not an object", segment)
+	}
+	if object == nil {
+		object = map[string]interface{}{}
+	}
+	child, err := setIn(object[segment], parts[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	object[segment] = child
+	return object, nil
+}
+
+// remove deletes the field at path. Array elements are left in place.
+func remove(fields map[string]interface{}, path string) {
+	parts := segments(path)
+	var parent interface{} = fields
+	if len(parts) > 1 {
+		parent, _ = get(fields, strings.Join(parts[:len(parts)-1], "."))
+	}
+	if object, ok := parent.(map[string]interface{}); ok {
+		delete(object, parts[len(parts)-1])
+	}
+}
+
+// copyValue returns a deep copy of the objects and arrays of value.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			object[key] = copyValue(child)
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, child := range v {
+			array[i] = copyValue(child)
+		}
+		return array
+	}
+	return value
+}
+
+// coercions convert a value to the types of OpCoerce.
+var coercions = map[string]func(interface{}) (interface{}, error){
+	"string": func(value interface{}) (interface{}, error) {
+		return toString(value)
+	},
+	"number": func(value interface{}) (interface{}, error) {
+		f, err := toFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(strconv.FormatFloat(f, 'f', -1, 64)), nil
+	},
+	"integer": func(value interface{}) (interface{}, error) {
+		f, err := toFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		if f != math.Trunc(f) {
+			return nil, fmt.Errorf("%v is not an integer", value)
+		}
+		return json.Number(strconv.FormatInt(int64(f), 10)), nil
+	},
+	"boolean": func(value interface{}) (interface{}, error) {
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case json.Number:
+			return v.String() != "0", nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true", "yes", "y", "1", "on":
+				return true, nil
+			case "false", "no", "n", "0", "off", "":
+				return false, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot convert %v to a boolean", value)
+	},
+	"array": func(value interface{}) (interface{}, error) {
+		if array, ok := value.([]interface{}); ok {
+			return array, nil
+		}
+		return []interface{}{value}, nil
+	},
+}
+
+// toString returns the text of a scalar value.
+func toString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, int64, float64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("cannot convert %T to a string", value)
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert %q to a number", v)
+		}
+		return f, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("cannot convert %T to a number", value)
+}
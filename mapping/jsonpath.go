@@ -0,0 +1,165 @@
+package mapping
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a compiled JSONPath expression. The subset supported is
+// the root $, child names .name and ['name'], indices [0] and [-1],
+// wildcards .* and [*] and recursive descent ..name.
+type jsonPath []step
+
+type step struct {
+	name      string
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool
+}
+
+func parseJSONPath(expression string) (jsonPath, error) {
+	if !strings.HasPrefix(expression, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", expression)
+	}
+	var path jsonPath
+	rest := expression[1:]
+	for rest != "" {
+		var s step
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			s.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				break
+			}
+			name, remaining := readName(rest)
+			if name == "" {
+				return nil, fmt.Errorf("JSONPath %q: name expected after ..", expression)
+			}
+			s.name, s.wildcard = name, name == "*"
+			rest = remaining
+			path = append(path, s)
+			continue
+		case strings.HasPrefix(rest, "."):
+			name, remaining := readName(rest[1:])
+			if name == "" {
+				return nil, fmt.Errorf("JSONPath %q: name expected after .", expression)
+			}
+			s.name, s.wildcard = name, name == "*"
+			rest = remaining
+			path = append(path, s)
+			continue
+		}
+		if !strings.HasPrefix(rest, "[") {
+			return nil, fmt.Errorf("JSONPath %q: unexpected %q", expression, rest)
+		}
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("JSONPath %q: missing ]", expression)
+		}
+		selector := strings.TrimSpace(rest[1:end])
+		rest = rest[end+1:]
+		switch {
+		case selector == "*":
+			s.wildcard = true
+		case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+			s.name = selector[1 : len(selector)-1]
+		default:
+			i, err := strconv.Atoi(selector)
+			if err != nil {
+				return nil, fmt.Errorf("JSONPath %q: unsupported selector [%s]", expression, selector)
+			}
+			s.index, s.isIndex = i, true
+		}
+		path = append(path, s)
+	}
+	return path, nil
+}
+
+// readName returns the name at the start of rest, up to the next . or [.
+func readName(rest string) (string, string) {
+	end := strings.IndexAny(rest, ".[")
+	if end < 0 {
+		return rest, ""
+	}
+	return rest[:end], rest[end:]
+}
+
+// evaluate returns the values the path selects in root.
+func (p jsonPath) evaluate(root interface{}) []interface{} {
+	nodes := []interface{}{root}
+	for _, s := range p {
+		var next []interface{}
+		for _, node := range nodes {
+			if s.recursive {
+				for _, descendant := range descendants(node) {
+					next = append(next, s.selectFrom(descendant)...)
+				}
+				continue
+			}
+			next = append(next, s.selectFrom(node)...)
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// selectFrom returns the children of node the step selects.
+func (s step) selectFrom(node interface{}) []interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if s.wildcard {
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			var values []interface{}
+			for _, key := range keys {
+				values = append(values, v[key])
+			}
+			return values
+		}
+		if value, ok := v[s.name]; ok && !s.isIndex {
+			return []interface{}{value}
+		}
+	case []interface{}:
+		if s.wildcard {
+			return v
+		}
+		if s.isIndex {
+			i := s.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				return []interface{}{v[i]}
+			}
+		}
+	}
+	return nil
+}
+
+// descendants returns node and every value nested in it.
+func descendants(node interface{}) []interface{} {
+	result := []interface{}{node}
+	switch v := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result = append(result, descendants(v[key])...)
+		}
+	case []interface{}:
+		for _, child := range v {
+			result = append(result, descendants(child)...)
+		}
+	}
+	return result
+}
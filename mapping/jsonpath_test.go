@@ -0,0 +1,82 @@
+package mapping
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+const listing = `{
+	"listing": {
+		"make": "Fender",
+		"model": "Stratocaster",
+		"photos": [{"url": "a.jpg"}, {"url": "b.jpg"}, {"url": "c.jpg"}],
+		"shop": {"name": "Guitar Center", "url": "https://www.guitarcenter.com"}
+	},
+	"odd key": 1
+}`
+
+func TestJSONPath(t *testing.T) {
+	root, err := pipeline.Decode([]byte(listing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"$", ``},
+		{"$.listing.make", `["Fender"]`},
+		{"$['listing']['model']", `["Stratocaster"]`},
+		{`$["odd key"]`, `[1]`},
+		{"$.listing.photos[0].url", `["a.jpg"]`},
+		{"$.listing.photos[-1].url", `["c.jpg"]`},
+		{"$.listing.photos[3].url", `null`},
+		{"$.listing.photos[*].url", `["a.jpg","b.jpg","c.jpg"]`},
+		{"$.listing.shop.*", `["Guitar Center","https://www.guitarcenter.com"]`},
+		{"$..url", `["a.jpg","b.jpg","c.jpg","https://www.guitarcenter.com"]`},
+		{"$..photos[1]", `[{"url":"b.jpg"}]`},
+		{"$.listing.make.name", `null`},
+		{"$.listing[0]", `null`},
+		{"$.missing", `null`},
+	}
+	for _, tt := range tests {
+		path, err := parseJSONPath(tt.path)
+		if err != nil {
+			t.Errorf("parseJSONPath(%q) error = %v", tt.path, err)
+			continue
+		}
+		values := path.evaluate(root)
+		if tt.path == "$" {
+			if len(values) != 1 {
+				t.Errorf("%s selected %d values, want the root", tt.path, len(values))
+			}
+			continue
+		}
+		got, err := json.Marshal(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s selected %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseJSONPathRejects(t *testing.T) {
+	paths := []string{
+		"listing.make",
+		"$.",
+		"$..",
+		"$.listing[",
+		"$.listing[?(@.make)]",
+		"$.listing[1:2]",
+		"$listing",
+	}
+	for _, path := range paths {
+		if _, err := parseJSONPath(path); err == nil {
+			t.Errorf("parseJSONPath(%q) error = nil, want an error", path)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Package mapping adapts the records of each retailer's spider to the
+// fields the models expect, with rules read from a YAML file, so a
+// spider emitting sale_price instead of price is handled by a config
+// edit.
+package mapping
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/pipeline"
+	"gopkg.in/yaml.v3"
+)
+
+// Default names the Mapping of companies without their own.
+const Default = "default"
+
+// The operations of a Rule.
+const (
+	// OpRename moves the value at From to To. Either may be a nested
+	// path, so OpRename also nests and flattens single fields.
+	OpRename = "rename"
+	// OpNest moves Fields into an object at To, wrapped in an array
+	// when Array is set, e.g. price and currency into offers.
+	OpNest = "nest"
+	// OpFlatten moves the fields of the object at From to the top level,
+	// their names prefixed with Prefix.
+	OpFlatten = "flatten"
+	// OpDefault sets To to Value when it is missing, null or empty.
+	OpDefault = "default"
+	// OpCoerce converts the value at To to Type: string, number,
+	// integer, boolean or array.
+	OpCoerce = "coerce"
+	// OpConcat joins the values of Fields that are set with Separator
+	// into To.
+	OpConcat = "concat"
+	// OpExtract sets To to what the JSONPath Path selects, an array when
+	// it selects several values.
+	OpExtract = "extract"
+)
+
+// Rule is a single step of a Mapping. Paths other than Path are
+// dot-separated field names and array indices, e.g. offers.0.price.
+type Rule struct {
+	Op        string      `yaml:"op"`
+	From      string      `yaml:"from"`
+	To        string      `yaml:"to"`
+	Fields    []string    `yaml:"fields"`
+	Value     interface{} `yaml:"value"`
+	Type      string      `yaml:"type"`
+	Separator string      `yaml:"separator"`
+	Path      string      `yaml:"path"`
+	Prefix    string      `yaml:"prefix"`
+	Array     bool        `yaml:"array"`
+
+	path jsonPath
+}
+
+// Mapping holds the Rules applied to the products and the articles of
+// a company, in order.
+type Mapping struct {
+	Products []Rule `yaml:"products"`
+	Articles []Rule `yaml:"articles"`
+}
+
+// Mappings holds the Mapping of each company by the name s3.Bucket
+// extracts, and of Default.
+type Mappings map[string]Mapping
+
+// Load reads and checks the Mappings of a YAML file.
+func Load(path string) (Mappings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("mapping.Load() Error reading %s: %v", path, err), err)
+	}
+	var mappings Mappings
+	if err := yaml.Unmarshal(data, &mappings); err != nil {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("mapping.Load() Error parsing %s: %v", path, err), err)
+	}
+	for company, mapping := range mappings {
+		for kind, rules := range map[string][]Rule{"products": mapping.Products, "articles": mapping.Articles} {
+			for i := range rules {
+				if err := rules[i].check(); err != nil {
+					msg := fmt.Sprintf("mapping.Load() %s: %s.%s rule %d: %v", path, company, kind, i+1, err)
+					return nil, errors.NewChuxParserError(msg, err)
+				}
+			}
+		}
+	}
+	return mappings, nil
+}
+
+// check reports a Rule missing the settings of its operation, and
+// compiles its Path.
+func (r *Rule) check() error {
+	switch r.Op {
+	case OpRename:
+		if r.From == "" || r.To == "" {
+			return fmt.Errorf("rename needs from and to")
+		}
+	case OpNest:
+		if len(r.Fields) == 0 || r.To == "" {
+			return fmt.Errorf("nest needs fields and to")
+		}
+	case OpFlatten:
+		if r.From == "" {
+			return fmt.Errorf("flatten needs from")
+		}
+	case OpDefault:
+		if r.To == "" || r.Value == nil {
+			return fmt.Errorf("default needs to and value")
+		}
+	case OpCoerce:
+		if r.To == "" {
+			return fmt.Errorf("coerce needs to")
+		}
+		if _, ok := coercions[r.Type]; !ok {
+			return fmt.Errorf("coerce to unknown type %q", r.Type)
+		}
+	case OpConcat:
+		if len(r.Fields) == 0 || r.To == "" {
+			return fmt.Errorf("concat needs fields and to")
+		}
+	case OpExtract:
+		if r.Path == "" || r.To == "" {
+			return fmt.Errorf("extract needs path and to")
+		}
+		path, err := parseJSONPath(r.Path)
+		if err != nil {
+			return err
+		}
+		r.path = path
+	default:
+		return fmt.Errorf("unknown op %q", r.Op)
+	}
+	return nil
+}
+
+// Mapper is a pipeline.Stage applying the Mapping of the company of
+// each record, or the Default one.
+type Mapper struct {
+	Mappings Mappings
+}
+
+// New returns a new Mapper
+func New(options ...func(*Mapper)) *Mapper {
+
+	m := &Mapper{Mappings: Mappings{}}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+func WithMappings(mappings Mappings) func(*Mapper) {
+	return func(m *Mapper) {
+		m.Mappings = mappings
+	}
+}
+
+func (m *Mapper) Name() string {
+	return "mapping"
+}
+
+// Process applies the Rules to the Fields of record. A rule that
+// cannot be applied, e.g. a value that cannot be coerced, is reported
+// and the record is kept.
+func (m *Mapper) Process(record *pipeline.Record) error {
+	mapping, ok := m.Mappings[record.Company]
+	if !ok {
+		mapping = m.Mappings[Default]
+	}
+	rules := mapping.Products
+	if !record.IsProduct {
+		rules = mapping.Articles
+	}
+	for _, rule := range rules {
+		if err := rule.apply(record.Fields); err != nil {
+			field := rule.To
+			if field == "" {
+				field = rule.From
+			}
+			record.Report(field, "%s: %v", rule.Op, err)
+		}
+	}
+	return nil
+}
+
+// apply applies the Rule to fields. Rules whose source is missing
+// leave fields as they are.
+func (r *Rule) apply(fields map[string]interface{}) error {
+	switch r.Op {
+	case OpRename:
+		value, ok := get(fields, r.From)
+		if !ok {
+			return nil
+		}
+		remove(fields, r.From)
+		return set(fields, r.To, value)
+	case OpNest:
+		nested := map[string]interface{}{}
+		for _, field := range r.Fields {
+			if value, ok := get(fields, field); ok {
+				nested[lastSegment(field)] = value
+				remove(fields, field)
+			}
+		}
+		if len(nested) == 0 {
+			return nil
+		}
+		if r.Array {
+			return set(fields, r.To, []interface{}{nested})
+		}
+		return set(fields, r.To, nested)
+	case OpFlatten:
+		value, ok := get(fields, r.From)
+		if !ok {
+			return nil
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s is not an object", r.From)
+		}
+		remove(fields, r.From)
+		for key, value := range object {
+			fields[r.Prefix+key] = value
+		}
+	case OpDefault:
+		if value, ok := get(fields, r.To); ok && !isEmpty(value) {
+			return nil
+		}
+		// Each record gets its own copy of a Value that is an object or
+		// an array, which later rules and stages may change.
+		return set(fields, r.To, copyValue(r.Value))
+	case OpCoerce:
+		value, ok := get(fields, r.To)
+		if !ok || value == nil {
+			return nil
+		}
+		coerced, err := coercions[r.Type](value)
+		if err != nil {
+			return err
+		}
+		return set(fields, r.To, coerced)
+	case OpConcat:
+		var joined string
+		for _, field := range r.Fields {
+			value, ok := get(fields, field)
+			if !ok || isEmpty(value) {
+				continue
+			}
+			text, err := toString(value)
+			if err != nil {
+				return err
+			}
+			if joined != "" {
+				joined += r.Separator
+			}
+			joined += text
+		}
+		if joined == "" {
+			return nil
+		}
+		return set(fields, r.To, joined)
+	case OpExtract:
+		values := r.path.evaluate(fields)
+		switch len(values) {
+		case 0:
+			return nil
+		case 1:
+			return set(fields, r.To, values[0])
+		default:
+			return set(fields, r.To, values)
+		}
+	}
+	return nil
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
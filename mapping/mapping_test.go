@@ -0,0 +1,176 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+// loadMappings returns the Mappings of the YAML document content.
+func loadMappings(t *testing.T, content string) Mappings {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mappings.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mappings, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mappings
+}
+
+// process maps the product record raw of company and returns it
+// encoded along with the fields of its Issues.
+func process(t *testing.T, mapper *Mapper, company, raw string) (string, []string) {
+	t.Helper()
+	fields, err := pipeline.Decode([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := &pipeline.Record{Company: company, IsProduct: true, Fields: fields}
+	if err := mapper.Process(record); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	encoded, err := pipeline.Encode(record.Fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issues []string
+	for _, issue := range record.Issues {
+		issues = append(issues, issue.Field)
+	}
+	return string(encoded), issues
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		record string
+		want   string
+		issue  string
+	}{
+		{"rename", `{op: rename, from: title, to: name}`, `{"title":"Strat"}`, `{"name":"Strat"}`, ""},
+		{"rename nested", `{op: rename, from: offers.0.sale_price, to: offers.0.price}`, `{"offers":[{"sale_price":849.99}]}`, `{"offers":[{"price":849.99}]}`, ""},
+		{"rename flattens", `{op: rename, from: listing.make, to: brand}`, `{"listing":{"make":"Fender","model":"Strat"}}`, `{"brand":"Fender","listing":{"model":"Strat"}}`, ""},
+		{"rename missing", `{op: rename, from: title, to: name}`, `{"name":"Strat"}`, `{"name":"Strat"}`, ""},
+		{"rename into scalar", `{op: rename, from: title, to: name.en}`, `{"title":"Strat","name":"Strat"}`, `{"name":"Strat"}`, "name.en"},
+		{"nest", `{op: nest, fields: [sale_price, currency], to: offer}`, `{"sale_price":849.99,"currency":"USD"}`, `{"offer":{"currency":"USD","sale_price":849.99}}`, ""},
+		{"nest array", `{op: nest, fields: [sale_price, currency], to: offers, array: true}`, `{"sale_price":849.99,"currency":"USD","name":"Strat"}`, `{"name":"Strat","offers":[{"currency":"USD","sale_price":849.99}]}`, ""},
+		{"nest nothing", `{op: nest, fields: [sale_price], to: offers, array: true}`, `{"name":"Strat"}`, `{"name":"Strat"}`, ""},
+		{"flatten", `{op: flatten, from: listing, prefix: listing_}`, `{"listing":{"make":"Fender","model":"Strat"}}`, `{"listing_make":"Fender","listing_model":"Strat"}`, ""},
+		{"flatten scalar", `{op: flatten, from: listing}`, `{"listing":"Fender"}`, `{"listing":"Fender"}`, "listing"},
+		{"default missing", `{op: default, to: offers.0.currency, value: EUR}`, `{"offers":[{"price":849}]}`, `{"offers":[{"currency":"EUR","price":849}]}`, ""},
+		{"default empty", `{op: default, to: currency, value: EUR}`, `{"currency":""}`, `{"currency":"EUR"}`, ""},
+		{"default null", `{op: default, to: currency, value: EUR}`, `{"currency":null}`, `{"currency":"EUR"}`, ""},
+		{"default set", `{op: default, to: currency, value: EUR}`, `{"currency":"USD"}`, `{"currency":"USD"}`, ""},
+		{"default object", `{op: default, to: seller, value: {name: Thomann, country: DE}}`, `{}`, `{"seller":{"country":"DE","name":"Thomann"}}`, ""},
+		{"coerce string", `{op: coerce, to: sku, type: string}`, `{"sku":12345}`, `{"sku":"12345"}`, ""},
+		{"coerce number", `{op: coerce, to: price, type: number}`, `{"price":" 849.90"}`, `{"price":849.9}`, ""},
+		{"coerce integer", `{op: coerce, to: stock, type: integer}`, `{"stock":"12"}`, `{"stock":12}`, ""},
+		{"coerce fraction to integer", `{op: coerce, to: stock, type: integer}`, `{"stock":"1.5"}`, `{"stock":"1.5"}`, "stock"},
+		{"coerce boolean", `{op: coerce, to: inStock, type: boolean}`, `{"inStock":"Yes"}`, `{"inStock":true}`, ""},
+		{"coerce array", `{op: coerce, to: images, type: array}`, `{"images":"a.jpg"}`, `{"images":["a.jpg"]}`, ""},
+		{"coerce missing", `{op: coerce, to: price, type: number}`, `{"price":null}`, `{"price":null}`, ""},
+		{"coerce invalid", `{op: coerce, to: price, type: number}`, `{"price":"call us"}`, `{"price":"call us"}`, "price"},
+		{"concat", `{op: concat, fields: [make, model, year], to: name, separator: " "}`, `{"make":"Fender","model":"Stratocaster","year":1962}`, `{"make":"Fender","model":"Stratocaster","name":"Fender Stratocaster 1962","year":1962}`, ""},
+		{"concat skips empty", `{op: concat, fields: [make, finish, model], to: name, separator: " "}`, `{"make":"Fender","finish":"","model":"Strat"}`, `{"finish":"","make":"Fender","model":"Strat","name":"Fender Strat"}`, ""},
+		{"concat object", `{op: concat, fields: [make, model], to: name}`, `{"make":{"name":"Fender"}}`, `{"make":{"name":"Fender"}}`, "name"},
+		{"extract", `{op: extract, path: "$.listing.make", to: brand}`, `{"listing":{"make":"Fender"}}`, `{"brand":"Fender","listing":{"make":"Fender"}}`, ""},
+		{"extract several", `{op: extract, path: "$.photos[*].url", to: images}`, `{"photos":[{"url":"a.jpg"},{"url":"b.jpg"}]}`, `{"images":["a.jpg","b.jpg"],"photos":[{"url":"a.jpg"},{"url":"b.jpg"}]}`, ""},
+		{"extract nothing", `{op: extract, path: "$.listing.make", to: brand}`, `{"name":"Strat"}`, `{"name":"Strat"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := New(WithMappings(loadMappings(t, "default:\n  products:\n    - "+tt.rule+"\n")))
+			got, issues := process(t, mapper, "sweetwater", tt.record)
+			if got != tt.want {
+				t.Errorf("mapped %s to %s, want %s", tt.record, got, tt.want)
+			}
+			if tt.issue == "" && len(issues) != 0 || tt.issue != "" && (len(issues) != 1 || issues[0] != tt.issue) {
+				t.Errorf("issues %v, want %q", issues, tt.issue)
+			}
+		})
+	}
+}
+
+// A default object or array is copied into each record, so changing
+// it in one record leaves the others alone.
+func TestDefaultIsCopied(t *testing.T) {
+	mapper := New(WithMappings(loadMappings(t, `
+default:
+  products:
+    - {op: default, to: seller, value: {name: Thomann, tags: [music]}}
+`)))
+	var records []*pipeline.Record
+	for i := 0; i < 2; i++ {
+		record := &pipeline.Record{IsProduct: true, Fields: map[string]interface{}{}}
+		if err := mapper.Process(record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	first := records[0].Fields["seller"].(map[string]interface{})
+	first["name"] = "Sweetwater"
+	first["tags"].([]interface{})[0] = "changed"
+
+	second := records[1].Fields["seller"].(map[string]interface{})
+	if second["name"] != "Thomann" || second["tags"].([]interface{})[0] != "music" {
+		t.Errorf("second record has seller %v, want the default", second)
+	}
+	if value := mapper.Mappings[Default].Products[0].Value.(map[string]interface{}); value["name"] != "Thomann" {
+		t.Errorf("rule value changed to %v", value)
+	}
+}
+
+func TestCompanyMapping(t *testing.T) {
+	mapper := New(WithMappings(loadMappings(t, `
+thomann:
+  products:
+    - {op: rename, from: title, to: name}
+  articles:
+    - {op: rename, from: headline, to: title}
+default:
+  products:
+    - {op: rename, from: product_name, to: name}
+`)))
+	if got, _ := process(t, mapper, "thomann", `{"title":"Strat","product_name":"x"}`); got != `{"name":"Strat","product_name":"x"}` {
+		t.Errorf("thomann product mapped to %s", got)
+	}
+	if got, _ := process(t, mapper, "sweetwater", `{"title":"Strat","product_name":"x"}`); got != `{"name":"x","title":"Strat"}` {
+		t.Errorf("default product mapped to %s", got)
+	}
+
+	record := &pipeline.Record{Company: "thomann", Fields: map[string]interface{}{"headline": "New Strats"}}
+	if err := mapper.Process(record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Fields["title"] != "New Strats" {
+		t.Errorf("article mapped to %v", record.Fields)
+	}
+}
+
+func TestLoadRejectsRules(t *testing.T) {
+	rules := []string{
+		`{op: rename, from: title}`,
+		`{op: nest, to: offers}`,
+		`{op: flatten}`,
+		`{op: default, to: currency}`,
+		`{op: coerce, to: price, type: decimal}`,
+		`{op: concat, to: name}`,
+		`{op: extract, path: "listing.make", to: brand}`,
+		`{op: drop, from: title}`,
+	}
+	for _, rule := range rules {
+		path := filepath.Join(t.TempDir(), "mappings.yaml")
+		if err := os.WriteFile(path, []byte("default:\n  products:\n    - "+rule+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("Load() accepted %s", rule)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Package mongodb shares one MongoDB client between the stores of the
+// record pipeline stages, which keep their collections in the same
+// database.
+package mongodb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultTimeout is the Timeout of each request.
+const DefaultTimeout = 30 * time.Second
+
+// Client is a client of a MongoDB database. It connects on first use,
+// so a run whose stages keep their stores in memory never connects.
+// The caller must Disconnect it.
+type Client struct {
+	URI      string
+	Database string
+	// Timeout of each request, see Context.
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	client *mongo.Client
+}
+
+// New returns a Client of database at uri, a connection URI with the
+// credentials filled in.
+func New(uri, database string, options ...func(*Client)) *Client {
+
+	c := &Client{URI: uri, Database: database, Timeout: DefaultTimeout}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+func WithTimeout(timeout time.Duration) func(*Client) {
+	return func(c *Client) {
+		c.Timeout = timeout
+	}
+}
+
+// Context returns ctx limited to the Timeout of a request.
+func (c *Client) Context(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, c.Timeout)
+}
+
+// Collection returns the collection name of the Database, connecting
+// the Client first if needed.
+func (c *Client) Collection(ctx context.Context, name string) (*mongo.Collection, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil {
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(c.URI))
+		if err != nil {
+			return nil, errors.NewChuxParserError("mongodb.Client Error connecting to MongoDB", err)
+		}
+		c.client = client
+	}
+	return c.client.Database(c.Database).Collection(name), nil
+}
+
+// Disconnect closes the connections of the Client, if it connected.
+func (c *Client) Disconnect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Disconnect(ctx)
+	c.client = nil
+	if err != nil {
+		return errors.NewChuxParserError("mongodb.Client.Disconnect() Error disconnecting from MongoDB", err)
+	}
+	return nil
+}
@@ -1,9 +1,11 @@
 package parsing
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,7 +13,13 @@ import (
 
 	ml "github.com/chuxorg/chux-models/logging"
 	"github.com/chuxorg/chux-models/models"
+	"github.com/chuxorg/chux-parser/charset"
+	"github.com/chuxorg/chux-parser/compression"
+	"github.com/chuxorg/chux-parser/errors"
 	"github.com/chuxorg/chux-parser/logging"
+	"github.com/chuxorg/chux-parser/pipeline"
+	"github.com/chuxorg/chux-parser/records"
+	"github.com/chuxorg/chux-parser/retry"
 	"github.com/chuxorg/chux-parser/s3"
 )
 
@@ -19,13 +27,43 @@ import (
 type Parser struct {
 	products []models.Product
 	articles []models.Article
-	Logger   *logging.Logger
+	// Format of the records of Files that do not carry their own,
+	// records.Auto detects it per File.
+	Format records.Format
+	// DownloadPath is the local directory GetFiles searches.
+	DownloadPath string
+	// Charset of the files opened with OpenFile that are not UTF-8,
+	// see charset.Detect.
+	Charset string
+	// MaxRecordSize caps the size of a single NDJSON record, larger
+	// records are skipped and sent to the DeadLetter when it is set.
+	MaxRecordSize int
+	DeadLetter    records.DeadLetter
+	// Pipeline, when set, processes each record before it is
+	// deserialized into its model.
+	Pipeline *pipeline.Pipeline
+	// Retry is applied to saving Products and Articles.
+	Retry retry.Policy
+	// Quarantine, when set, receives Files of at least MinRecords
+	// records of which more than FailurePercent failed.
+	Quarantine     Quarantiner
+	FailurePercent int
+	MinRecords     int
+	Logger         *logging.Logger
+}
+
+// Quarantiner quarantines the object of a File. It is implemented by
+// s3.Bucket.
+type Quarantiner interface {
+	Quarantine(key string, report s3.QuarantineReport) error
 }
 
 // New returns a new Parser struct
 func New(options ...func(*Parser)) *Parser {
 
-	parser := &Parser{}
+	parser := &Parser{
+		Retry: retry.Default(),
+	}
 	for _, option := range options {
 		option(parser)
 	}
@@ -33,34 +71,174 @@ func New(options ...func(*Parser)) *Parser {
 	return parser
 }
 
+func WithFormat(format records.Format) func(*Parser) {
+	return func(parser *Parser) {
+		parser.Format = format
+	}
+}
+
+func WithDownloadPath(path string) func(*Parser) {
+	return func(parser *Parser) {
+		parser.DownloadPath = path
+	}
+}
+
+func WithCharset(charset string) func(*Parser) {
+	return func(parser *Parser) {
+		parser.Charset = charset
+	}
+}
+
+func WithMaxRecordSize(size int) func(*Parser) {
+	return func(parser *Parser) {
+		parser.MaxRecordSize = size
+	}
+}
+
+func WithDeadLetter(deadLetter records.DeadLetter) func(*Parser) {
+	return func(parser *Parser) {
+		parser.DeadLetter = deadLetter
+	}
+}
+
+func WithPipeline(pipeline *pipeline.Pipeline) func(*Parser) {
+	return func(parser *Parser) {
+		parser.Pipeline = pipeline
+	}
+}
+
+func WithRetry(policy retry.Policy) func(*Parser) {
+	return func(parser *Parser) {
+		parser.Retry = policy
+	}
+}
+
+func WithQuarantine(quarantine Quarantiner, failurePercent, minRecords int) func(*Parser) {
+	return func(parser *Parser) {
+		parser.Quarantine = quarantine
+		parser.FailurePercent = failurePercent
+		parser.MinRecords = minRecords
+	}
+}
+
 func WithLogger(logger *logging.Logger) func(*Parser) {
 	return func(parser *Parser) {
 		parser.Logger = logger
 	}
 }
 
-func (p *Parser) Parse(file s3.File) {
+// ParseResult summarises the outcome of parsing a single File.
+type ParseResult struct {
+	Path     string `json:"path"`
+	Company  string `json:"company"`
+	Products int    `json:"products"`
+	Articles int    `json:"articles"`
+	// Failed counts the records that could not be decoded or saved,
+	// including those Rejected by a pipeline.Stage.
+	Failed   int `json:"failed"`
+	Rejected int `json:"rejected"`
+	// Skipped counts the records a pipeline.Stage dropped as needing no
+	// saving, see pipeline.ErrSkip.
+	Skipped int `json:"skipped,omitempty"`
+	// Issues counts the pipeline.Issues of the records by stage and
+	// field, e.g. schema:offers/*/price.
+	Issues map[string]int `json:"issues,omitempty"`
+	// Retries counts the repeated requests made to download the File
+	// and to save its records.
+	Retries int `json:"retries"`
+	// Checksum of the File's object, see s3.Checksum.
+	Checksum string `json:"checksum,omitempty"`
+	// Charset the File was transcoded from and the number of invalid
+	// sequences that were replaced in it.
+	Charset string `json:"charset,omitempty"`
+	Invalid int    `json:"invalid,omitempty"`
+	// Parsed is set when the File was read to the end and passed
+	// verification. Records may have been saved even if it is not.
+	Parsed bool `json:"parsed"`
+	// Quarantined is set when too many records failed and the File's
+	// object was quarantined.
+	Quarantined bool `json:"quarantined,omitempty"`
+	// Err is set when the File could not be read to the end or failed
+	// verification. Records after a read failure were not parsed.
+	Err error `json:"-"`
+}
+
+// Status returns the s3.Status recording the result in the tags of
+// the File's object.
+func (r ParseResult) Status(runID string) s3.Status {
+	state := s3.StatusParsed
+	if r.Quarantined {
+		state = s3.StatusQuarantined
+	} else if !r.Parsed {
+		state = s3.StatusFailed
+	}
+	return s3.Status{
+		State:    state,
+		RunID:    runID,
+		Products: r.Products,
+		Articles: r.Articles,
+		Failed:   r.Failed,
+	}
+}
+
+// Parse parses every JSON record of the File's Content into a Product
+// or an Article and saves it.
+func (p *Parser) Parse(file s3.File) ParseResult {
+	return p.ParseStream(file, strings.NewReader(file.Content))
+}
+
+// ParseStream works like Parse but reads the records from r instead
+// of the File's Content, so large objects need not be held in memory.
+// A reader that verifies the object, see s3.Bucket.OpenObject, can
+// only do so once it has been read to the end, after its records were
+// saved: a failed verification makes the ParseResult not Parsed, so
+// the object is tagged failed, but does not undo the saves. Parse
+// does not have this problem as Bucket.Download verifies the objects
+// before returning them.
+func (p *Parser) ParseStream(file s3.File, r io.Reader) ParseResult {
 
 	productCount := 0
 	articleCount := 0
+	result := ParseResult{Path: file.Path, Company: file.Company, Checksum: file.Checksum, Charset: file.Charset, Invalid: file.Invalid}
 	modelsLogger := ml.NewLogger(ml.LogLevelDebug)
 	p.Logger.Debug("Parser.Parse() called")
 	// Create the out and errOut channels
-	out := make(chan string)
+	out := make(chan json.RawMessage)
 	errOut := make(chan error)
 
+	// A Format detected when the File was downloaded takes precedence
+	format, err := records.ParseFormat(file.Format)
+	if err != nil || format == records.Auto {
+		format = p.Format
+	}
+
 	// Call the readJSONObjects function in a separate goroutine
-	go p.readJSONObjects(file.Content, out, errOut)
+	go p.readJSONObjects(r, format, file.Path, out, errOut)
 
 	// Loop until both channels are closed and set to nil
+	index := 0
 	for {
 		select {
-		case jsonStr, ok := <-out:
+		case raw, ok := <-out:
 			if !ok {
 				out = nil // Set the channel to nil to stop checking it
 			} else {
-				// Process the JSON string (e.g., pass it to Product.SetState())
-				p.Logger.Info("Parser.Parse() Parsing JSON Object:", jsonStr)
+				// The raw record is decoded by the model itself, there is
+				// no intermediate map or string copy.
+				p.Logger.Info("Parser.Parse() Parsing JSON Object: %s", raw)
+				index++
+				raw, record, err := p.process(raw, file, index, &result)
+				if stderrors.Is(err, pipeline.ErrSkip) {
+					p.Logger.Info("Parser.Parse() Skipping record %d of %s: %v", index, file.Path, err)
+					result.Skipped++
+					continue
+				}
+				if err != nil {
+					p.Logger.Warning("Parser.Parse() Skipping record %d of %s: %v", index, file.Path, err)
+					result.Failed++
+					result.Rejected++
+					continue
+				}
 
 				if file.IsProduct {
 					p.Logger.Info("Parser.Parse() Parsing Product...")
@@ -69,15 +247,17 @@ func (p *Parser) Parse(file s3.File) {
 						models.NewProductWithLogger(*modelsLogger),
 					)
 					var err error
-					err = product.Parse(jsonStr)
+					err = product.Deserialize(raw)
 					if err != nil {
-						p.Logger.Warning("Parser.Parse() Failed to parse product while calling product.Parse", err)
+						p.Logger.Warning("Parser.Parse() Failed to parse product while calling product.Deserialize: %v", err)
 					}
-					err = product.Save()
+					err = p.save(product, &result)
 					if err != nil {
-						p.Logger.Error("Failed to save product", err)
+						p.Logger.Error("Failed to save product: %v", err)
+						result.Failed++
 					} else {
 						productCount++ // Increment product count on successful save
+						p.commit(record)
 					}
 					file.IsProduct = true
 					file.OwnerID = product.ID
@@ -89,15 +269,17 @@ func (p *Parser) Parse(file s3.File) {
 					article := models.NewArticle(
 						models.NewArticleWithLogger(*modelsLogger),
 					)
-					err := article.Parse(jsonStr)
+					err := article.Deserialize(raw)
 					if err != nil {
-						p.Logger.Error("Parser.Parse() Failed to parse article", err)
+						p.Logger.Error("Parser.Parse() Failed to parse article: %v", err)
 					}
-					err = article.Save()
+					err = p.save(article, &result)
 					if err != nil {
-						p.Logger.Error("Parser.Parse() Failed to save Article", err)
+						p.Logger.Error("Parser.Parse() Failed to save Article: %v", err)
+						result.Failed++
 					} else {
 						articleCount++ // Increment article count on successful save
+						p.commit(record)
 					}
 					file.IsProduct = false
 					file.OwnerID = article.ID
@@ -110,9 +292,15 @@ func (p *Parser) Parse(file s3.File) {
 			if !ok {
 				errOut = nil // Set the channel to nil to stop checking it
 			} else {
-				// Handle the error (e.g., log it, exit the program, or take other appropriate action)
-				p.Logger.Error("Parser.Parse() Error while parsing JSON Object", err)
-				fmt.Println("Error:", err)
+				// A ChuxParserError means the file could not be read any
+				// further, anything else is a single bad record.
+				var readErr *errors.ChuxParserError
+				if stderrors.As(err, &readErr) {
+					result.Err = err
+				} else {
+					result.Failed++
+				}
+				p.Logger.Error("Parser.Parse() Error while parsing JSON Object: %v", err)
 			}
 		}
 
@@ -123,69 +311,185 @@ func (p *Parser) Parse(file s3.File) {
 		}
 	}
 	p.Logger.Info(fmt.Sprintf("Parsed a total of %d Articles and %d Products", articleCount, productCount))
+	result.Products = productCount
+	result.Articles = articleCount
+	result.Retries += file.Retries
+	if counter, ok := r.(retryCounter); ok {
+		// The reader counts the retries of the download, which include
+		// those made before the File was returned.
+		result.Retries += counter.Retries() - file.Retries
+	}
+	if counter, ok := r.(invalidCounter); ok {
+		result.Invalid = counter.Invalid()
+	}
+	if result.Invalid > 0 {
+		p.Logger.Warning("Parser.Parse() Replaced %d invalid %s sequences in %s", result.Invalid, result.Charset, file.Path)
+	}
+	if v, ok := r.(verifier); ok {
+		checksum, err := v.Verify()
+		if err != nil && result.Err == nil {
+			p.Logger.Error("Parser.Parse() %v", err)
+			result.Err = err
+		}
+		result.Checksum = checksum.String()
+	}
+	result.Parsed = result.Err == nil
+	if result.Parsed && p.Quarantine != nil {
+		p.checkFailureRate(&result)
+	}
+	return result
 }
 
-func (p *Parser) readJSONObjects(content string, out chan<- string, errOut chan<- error) {
-	p.Logger.Debug("readJSONObjects() go routine called")
-	defer close(out)
-	defer close(errOut)
+// checkFailureRate quarantines the File of result when too many of its
+// records failed.
+func (p *Parser) checkFailureRate(result *ParseResult) {
+	total := result.Products + result.Articles + result.Failed
+	if total == 0 || total < p.MinRecords || result.Failed*100 <= p.FailurePercent*total {
+		return
+	}
+	report := s3.QuarantineReport{
+		Reason:  s3.ReasonFailureRate,
+		Error:   fmt.Sprintf("%d of %d records failed", result.Failed, total),
+		Records: total,
+		Failed:  result.Failed,
+	}
+	if err := p.Quarantine.Quarantine(result.Path, report); err != nil {
+		p.Logger.Error("Parser.checkFailureRate() %v", err)
+		return
+	}
+	result.Quarantined = true
+}
 
-	// Declare a variable to store each JSON object
-	var jsonObj map[string]interface{}
+// process runs the Pipeline on the raw record at index of file and
+// returns the record its model is deserialized from, along with the
+// pipeline.Record to commit once the model was saved.
+func (p *Parser) process(raw json.RawMessage, file s3.File, index int, result *ParseResult) (json.RawMessage, *pipeline.Record, error) {
+	if p.Pipeline == nil || len(p.Pipeline.Stages) == 0 {
+		return raw, nil, nil
+	}
+	fields, err := pipeline.Decode(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	record := &pipeline.Record{
+		Key:       file.Path,
+		Index:     index,
+		Company:   file.Company,
+		IsProduct: file.IsProduct,
+		Fields:    fields,
+	}
+	err = p.Pipeline.Process(record)
+	for _, issue := range record.Issues {
+		p.Logger.Warning("Parser.process() Record %d of %s: %s", index, file.Path, issue)
+		if result.Issues == nil {
+			result.Issues = map[string]int{}
+		}
+		result.Issues[issue.Stage+":"+issue.Field]++
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err = pipeline.Encode(record.Fields)
+	return raw, record, err
+}
 
-	// Use strings.NewReader to read the content string
-	reader := strings.NewReader(content)
-	scanner := bufio.NewScanner(reader)
+// commit commits the record of a saved model to the Pipeline. A
+// failure is logged, the model is saved regardless.
+func (p *Parser) commit(record *pipeline.Record) {
+	if record == nil {
+		return
+	}
+	if err := p.Pipeline.Commit(record); err != nil {
+		p.Logger.Warning("Parser.commit() Record %d of %s: %v", record.Index, record.Key, err)
+	}
+}
 
-	// Set the buffer size to 50MB
-	const bufferSize = 50 * 1024 * 1024 // 50MB buffer size
-	buffer := make([]byte, bufferSize)
-	scanner.Buffer(buffer, bufferSize)
+// retryCounter is implemented by readers of S3 objects that resume
+// failed downloads.
+type retryCounter interface {
+	Retries() int
+}
 
-	// Skip the first line
-	if scanner.Scan() {
-	} // Do nothing, just skip the first line
+// invalidCounter is implemented by readers that transcode a File to
+// UTF-8.
+type invalidCounter interface {
+	Invalid() int
+}
 
-	// Iterate over each line in the file
-	p.Logger.Info("readJSONObjects() Iterating over each line in the file")
-	for scanner.Scan() {
-		// Get the current line as a string
-		line := scanner.Text()
+// verifier is implemented by readers of S3 objects that verify the
+// object once it has been read.
+type verifier interface {
+	Verify() (s3.Checksum, error)
+}
 
-		// Unmarshal the JSON line into the jsonObj variable
-		err := json.Unmarshal([]byte(line), &jsonObj)
-		if err != nil {
-			// If an error occurs, send the error to the error output channel
-			errOut <- fmt.Errorf("failed to unmarshal JSON object: %w", err)
-			continue
+// save saves model with the Parser's Retry policy and adds the
+// retries made to result.
+func (p *Parser) save(model interface{ Save() error }, result *ParseResult) error {
+	retries, err := p.Retry.Do(context.Background(), func(attempt int) error {
+		if attempt > 0 {
+			p.Logger.Warning("Parser.save() Retrying to save record, attempt %d", attempt+1)
 		}
+		return model.Save()
+	})
+	result.Retries += retries
+	return err
+}
 
-		// Marshal the JSON object back to a JSON string
-		jsonStr, err := json.Marshal(jsonObj)
+// readJSONObjects sends the records read from reader to out. The
+// decoder has already validated each record, so the raw bytes are
+// sent on as they are.
+func (p *Parser) readJSONObjects(reader io.Reader, format records.Format, name string, out chan<- json.RawMessage, errOut chan<- error) {
+	p.Logger.Debug("readJSONObjects() go routine called")
+	defer close(out)
+	defer close(errOut)
+
+	var options []func(*records.Options)
+	if p.MaxRecordSize > 0 {
+		options = append(options, records.WithMaxRecordSize(p.MaxRecordSize))
+	}
+	if p.DeadLetter != nil {
+		options = append(options, records.WithDeadLetter(p.DeadLetter))
+	}
+	decoder, err := records.NewDecoder(reader, format, name, options...)
+	if err != nil {
+		errOut <- err
+		return
+	}
+	defer decoder.Release()
+
+	// Iterate over each record in the file
+	p.Logger.Info("readJSONObjects() Iterating over each %s record in the file", decoder.Format())
+	for {
+		raw, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		var recordErr *records.RecordError
+		if stderrors.As(err, &recordErr) {
+			// A single malformed record, the rest of the file is still read
+			errOut <- fmt.Errorf("failed to decode JSON object: %w", err)
+			continue
+		}
 		if err != nil {
 			// If an error occurs, send the error to the error output channel
-			errOut <- fmt.Errorf("failed to marshal JSON object: %w", err)
-			continue
+			errOut <- errors.NewChuxParserError(fmt.Sprintf("error reading file: %v", err), err)
+			return
 		}
 
-		// Send the JSON string to the output channel
-		out <- string(jsonStr)
-	}
-
-	// Check for any errors that occurred during the scanning process
-	if err := scanner.Err(); err != nil {
-		// If an error occurs, send the error to the error output channel
-		errOut <- fmt.Errorf("error scanning file: %w", err)
+		// Send the raw JSON object to the output channel
+		out <- raw
 	}
 }
 
+// GetFiles returns the crawl files below the DownloadPath. Compressed
+// crawl files such as .jl.gz are included, use OpenFile to read them.
 func (p *Parser) GetFiles() []string {
 	retVal := []string{}
-	dir := os.Getenv("DOWNLOAD_PATH")
+	dir := p.DownloadPath
 	// Walk the directory recursively and search for files with .jl extension
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		// Check if file extension is .jl
-		if filepath.Ext(path) == ".jl" {
+		// Check if file extension is .jl, ignoring a compression suffix
+		if filepath.Ext(compression.TrimExt(path)) == ".jl" {
 			retVal = append(retVal, path)
 		}
 		return nil
@@ -197,3 +501,25 @@ func (p *Parser) GetFiles() []string {
 
 	return retVal
 }
+
+// OpenFile opens a crawl file returned by GetFiles for ParseStream,
+// decompressing it and transcoding it to UTF-8 when needed.
+func (p *Parser) OpenFile(path string) (io.ReadCloser, error) {
+	reader, format, err := compression.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	text, err := charset.NewReader(reader, path, p.Charset)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	p.Logger.Debug("Parser.OpenFile() Opened %s, compression: %s, charset: %s", path, format, text.Encoding())
+	return textFile{Reader: text, Closer: reader}, nil
+}
+
+// textFile closes the file a charset.Reader reads.
+type textFile struct {
+	*charset.Reader
+	io.Closer
+}
@@ -0,0 +1,81 @@
+package parsing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chuxorg/chux-parser/records"
+)
+
+// crawlFixture returns n product records in NDJSON, shaped like the
+// records of the product spiders.
+func crawlFixture(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, `{"url":"https://www.sweetwater.com/store/detail/StratHSS%[1]d","name":"Fender Player Stratocaster HSS %[1]d","sku":"StratHSS%[1]d","brand":"Fender","offers":[{"price":"849.99","currency":"USD","availability":"InStock"}],"breadcrumbs":[{"name":"Guitars","link":"https://www.sweetwater.com/c/guitars"},{"name":"Electric Guitars","link":"https://www.sweetwater.com/c/electric-guitars"}],"description":"%[2]s","images":["https://media.sweetwater.com/%[1]d-1.jpg","https://media.sweetwater.com/%[1]d-2.jpg"]}`+"\n", i, strings.Repeat("Alder body, maple neck. ", 20))
+	}
+	return sb.String()
+}
+
+func drain(out <-chan json.RawMessage, errOut <-chan error) {
+	for out != nil || errOut != nil {
+		select {
+		case _, ok := <-out:
+			if !ok {
+				out = nil
+			}
+		case _, ok := <-errOut:
+			if !ok {
+				errOut = nil
+			}
+		}
+	}
+}
+
+func BenchmarkReadJSONObjects(b *testing.B) {
+	content := crawlFixture(1000)
+	parser := &Parser{}
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		out := make(chan json.RawMessage)
+		errOut := make(chan error)
+		go parser.readJSONObjects(strings.NewReader(content), records.NDJSON, "bench.jl", out, errOut)
+		drain(out, errOut)
+	}
+}
+
+// BenchmarkReadJSONObjectsRoundTrip measures the previous approach of
+// decoding each line into a map and encoding it back to a string, for
+// comparison with BenchmarkReadJSONObjects.
+func BenchmarkReadJSONObjectsRoundTrip(b *testing.B) {
+	content := crawlFixture(1000)
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		out := make(chan string)
+		go func() {
+			defer close(out)
+			var jsonObj map[string]interface{}
+			scanner := bufio.NewScanner(strings.NewReader(content))
+			scanner.Buffer(make([]byte, 64*1024), 50*1024*1024)
+			for scanner.Scan() {
+				if err := json.Unmarshal(scanner.Bytes(), &jsonObj); err != nil {
+					continue
+				}
+				jsonStr, err := json.Marshal(jsonObj)
+				if err != nil {
+					continue
+				}
+				out <- string(jsonStr)
+			}
+		}()
+		for range out {
+		}
+	}
+}
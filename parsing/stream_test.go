@@ -0,0 +1,153 @@
+package parsing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/chuxorg/chux-parser/pipeline"
+	"github.com/chuxorg/chux-parser/retry"
+	"github.com/chuxorg/chux-parser/s3"
+	"github.com/chuxorg/chux-parser/s3/s3test"
+)
+
+const testBucket = "chux-crawler"
+
+// collector is a pipeline.Stage keeping the sku of each record and
+// skipping it, so nothing is saved.
+type collector struct {
+	skus []string
+}
+
+func (c *collector) Name() string { return "collector" }
+
+func (c *collector) Process(record *pipeline.Record) error {
+	sku, _ := record.Fields["sku"].(string)
+	c.skus = append(c.skus, sku)
+	return fmt.Errorf("collected: %w", pipeline.ErrSkip)
+}
+
+func newTestBucket(t *testing.T, server *s3test.Server, options ...func(*s3.Bucket)) *s3.Bucket {
+	t.Helper()
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("test", "test", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := []func(*s3.Bucket){
+		s3.WithName(testBucket),
+		s3.WithSession(sess),
+		s3.WithEndpoint(server.URL),
+		s3.WithPathStyle(true),
+		s3.WithDisableSSL(true),
+		s3.WithVerify(true),
+		s3.WithRetry(retry.Policy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+	}
+	return s3.New(append(defaults, options...)...)
+}
+
+func gzipped(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseStream(t *testing.T) {
+	const count = 200
+	content := crawlFixture(count)
+	multipart := []func(*s3.Bucket){s3.WithConcurrency(4), s3.WithPartSize(16 << 10)}
+	tests := []struct {
+		name    string
+		object  s3test.Object
+		options []func(*s3.Bucket)
+		fail    int
+		cut     int
+		retries int
+	}{
+		{name: "plain", object: s3test.Object{Key: "sweetwater/products.jl", Content: []byte(content)}},
+		{name: "gz", object: s3test.Object{Key: "sweetwater/products.jl.gz", Content: gzipped(t, content)}},
+		{name: "content encoding gzip", object: s3test.Object{Key: "sweetwater/products.jl", Content: gzipped(t, content), ContentEncoding: "gzip"}},
+		{name: "multipart", object: s3test.Object{Key: "sweetwater/products.jl", Content: []byte(content), ChecksumAlgorithm: "SHA256"}, options: multipart},
+		{name: "cut read", object: s3test.Object{Key: "sweetwater/products.jl", Content: []byte(content)}, cut: 2, retries: 2},
+		{name: "cut multipart", object: s3test.Object{Key: "sweetwater/products.jl", Content: []byte(content)}, options: multipart, cut: 3, retries: 3},
+		{name: "503", object: s3test.Object{Key: "sweetwater/products.jl", Content: []byte(content)}, fail: 2, retries: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := s3test.NewServer()
+			defer server.Close()
+			server.Put(testBucket, tt.object)
+			bucket := newTestBucket(t, server, tt.options...)
+			server.FailRequests(tt.fail)
+			server.CutReads(tt.cut, 5000)
+
+			file, body, err := bucket.OpenObject(tt.object.Key)
+			if err != nil {
+				t.Fatalf("OpenObject() error = %v", err)
+			}
+			defer body.Close()
+			stage := &collector{}
+			parser := New(WithPipeline(pipeline.New(pipeline.WithStage(stage))))
+			result := parser.ParseStream(*file, body)
+
+			if !result.Parsed || result.Err != nil {
+				t.Fatalf("ParseStream() = %+v, want it parsed", result)
+			}
+			if result.Skipped != count || result.Failed != 0 || len(stage.skus) != count {
+				t.Errorf("ParseStream() skipped %d and failed %d, collected %d, want %d records", result.Skipped, result.Failed, len(stage.skus), count)
+			}
+			for i, sku := range stage.skus {
+				if want := fmt.Sprintf("StratHSS%d", i); sku != want {
+					t.Errorf("record %d has sku %q, want %q", i+1, sku, want)
+					break
+				}
+			}
+			if result.Company != "sweetwater" || result.Checksum == "" {
+				t.Errorf("ParseStream() = %s with checksum %q, want sweetwater with a checksum", result.Company, result.Checksum)
+			}
+			if result.Retries < tt.retries {
+				t.Errorf("Retries = %d, want at least %d", result.Retries, tt.retries)
+			}
+		})
+	}
+}
+
+func TestParseDownloaded(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	content := crawlFixture(20)
+	server.Put(testBucket, s3test.Object{Key: "sweetwater/a.jl", Content: []byte(content)})
+	server.Put(testBucket, s3test.Object{Key: "sweetwater/b.jl.gz", Content: gzipped(t, content)})
+	server.Put(testBucket, s3test.Object{Key: "sweetwater/c.jl", Content: gzipped(t, content), ContentEncoding: "gzip"})
+	files, err := newTestBucket(t, server).Download()
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Download() returned %d files, want 3", len(files))
+	}
+
+	stage := &collector{}
+	parser := New(WithPipeline(pipeline.New(pipeline.WithStage(stage))))
+	for _, file := range files {
+		if result := parser.Parse(file); !result.Parsed || result.Skipped != 20 {
+			t.Errorf("Parse(%s) = %+v, want 20 records skipped", file.Path, result)
+		}
+	}
+	if len(stage.skus) != 60 {
+		t.Errorf("collected %d records, want 60", len(stage.skus))
+	}
+}
@@ -0,0 +1,189 @@
+// Package pipeline runs the Stages a crawl record passes between the
+// records.Decoder and the Product or Article model, such as schema
+// validation and field mapping.
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/chuxorg/chux-parser/errors"
+)
+
+// Record is a crawl record on its way to its model.
+type Record struct {
+	// Key of the object the record was read from and its position.
+	Key   string
+	Index int
+	// Company the object belongs to, see s3.File.
+	Company   string
+	IsProduct bool
+	// Fields is the decoded JSON object. Stages change it in place.
+	// Numbers are json.Number, so they keep their precision.
+	Fields map[string]interface{}
+	// Issues are the problems the Stages reported without rejecting
+	// the record.
+	Issues []Issue
+	// State holds what Stages keep between Process and Commit, by the
+	// Name of the Stage.
+	State map[string]interface{}
+
+	stage string
+}
+
+// Issue is a problem a Stage found with a field of a Record.
+type Issue struct {
+	Stage   string
+	Field   string
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Stage, i.Field, i.Message)
+}
+
+// Report adds an Issue with field to the Record on behalf of the Stage
+// processing it.
+func (r *Record) Report(field, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, Issue{Stage: r.stage, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// SetProperty sets the additional property name of the Record to
+// value, replacing one of the same name. Additional properties keep
+// what Stages derive that the models have no field for.
+func (r *Record) SetProperty(name, value string) {
+	properties, _ := r.Fields["additionalProperty"].([]interface{})
+	for _, item := range properties {
+		if property, ok := item.(map[string]interface{}); ok && property["name"] == name {
+			property["value"] = value
+			return
+		}
+	}
+	r.Fields["additionalProperty"] = append(properties, map[string]interface{}{"name": name, "value": value})
+}
+
+// Property returns the value of the additional property name of the
+// Record, an empty string when it has none.
+func (r *Record) Property(name string) string {
+	properties, _ := r.Fields["additionalProperty"].([]interface{})
+	for _, item := range properties {
+		if property, ok := item.(map[string]interface{}); ok && property["name"] == name {
+			value, _ := property["value"].(string)
+			return value
+		}
+	}
+	return ""
+}
+
+// Stage processes each Record before it is deserialized into its
+// model. An error rejects the Record, which is then not saved.
+type Stage interface {
+	// Name identifies the Stage in Issues and errors.
+	Name() string
+	Process(record *Record) error
+}
+
+// Committer is implemented by Stages that record what they have seen
+// once the model of a Record was saved, so a Record that failed to
+// save is processed again on the next run.
+type Committer interface {
+	Commit(record *Record) error
+}
+
+// ErrSkip is wrapped by the errors of Stages dropping a Record that
+// needs no saving, such as an unchanged duplicate. Skipped Records
+// are not counted as failed.
+var ErrSkip = stderrors.New("skipped")
+
+// RejectedError is returned by Process for a Record a Stage rejected.
+type RejectedError struct {
+	Stage string
+	Err   error
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("rejected by %s: %v", e.Stage, e.Err)
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// Pipeline runs its Stages in order.
+type Pipeline struct {
+	Stages []Stage
+}
+
+// New returns a new Pipeline
+func New(options ...func(*Pipeline)) *Pipeline {
+
+	p := &Pipeline{}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// WithStage appends stage to the Stages of the Pipeline.
+func WithStage(stage Stage) func(*Pipeline) {
+	return func(p *Pipeline) {
+		p.Stages = append(p.Stages, stage)
+	}
+}
+
+// Process runs the Stages on record and stops at the first one
+// rejecting or skipping it.
+func (p *Pipeline) Process(record *Record) error {
+	for _, stage := range p.Stages {
+		record.stage = stage.Name()
+		if err := stage.Process(record); err != nil {
+			if stderrors.Is(err, ErrSkip) {
+				return fmt.Errorf("%s: %w", stage.Name(), err)
+			}
+			return &RejectedError{Stage: stage.Name(), Err: err}
+		}
+	}
+	record.stage = ""
+	return nil
+}
+
+// Commit calls the Stages that are Committers with the saved record
+// and returns the first error.
+func (p *Pipeline) Commit(record *Record) error {
+	var first error
+	for _, stage := range p.Stages {
+		committer, ok := stage.(Committer)
+		if !ok {
+			continue
+		}
+		if err := committer.Commit(record); err != nil && first == nil {
+			first = fmt.Errorf("%s: %w", stage.Name(), err)
+		}
+	}
+	return first
+}
+
+// Decode returns the Fields of a raw JSON object.
+func Decode(raw json.RawMessage) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("pipeline.Decode() Error decoding record: %v", err), err)
+	}
+	if fields == nil {
+		return nil, errors.NewChuxParserError("pipeline.Decode() record is not a JSON object", nil)
+	}
+	return fields, nil
+}
+
+// Encode returns the raw JSON object of fields.
+func Encode(fields map[string]interface{}) (json.RawMessage, error) {
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("pipeline.Encode() Error encoding record: %v", err), err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,139 @@
+package price
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/currency"
+)
+
+// IsCurrency reports whether code is an ISO 4217 currency code.
+func IsCurrency(code string) bool {
+	_, err := currency.ParseISO(code)
+	return err == nil && code == strings.ToUpper(code)
+}
+
+// MinorDigits returns the number of decimal places of the minor unit
+// of the currency code, e.g. 2 for cents of USD and 0 for JPY.
+func MinorDigits(code string) int {
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return 2
+	}
+	scale, _ := currency.Standard.Rounding(unit)
+	return scale
+}
+
+// symbols maps the currency symbols found in prices to the ISO codes
+// of the currencies using them, the first being the one assumed when
+// the domain does not decide. Longer symbols come first, so US$ is
+// not taken for $.
+var symbols = []struct {
+	symbol string
+	codes  []string
+}{
+	{"US$", []string{"USD"}},
+	{"CA$", []string{"CAD"}},
+	{"AU$", []string{"AUD"}},
+	{"NZ$", []string{"NZD"}},
+	{"HK$", []string{"HKD"}},
+	{"C$", []string{"CAD"}},
+	{"A$", []string{"AUD"}},
+	{"R$", []string{"BRL"}},
+	{"Fr.", []string{"CHF"}},
+	{"zł", []string{"PLN"}},
+	{"Kč", []string{"CZK"}},
+	{"kr", []string{"SEK", "NOK", "DKK", "ISK"}},
+	{"€", []string{"EUR"}},
+	{"£", []string{"GBP"}},
+	{"₹", []string{"INR"}},
+	{"₩", []string{"KRW"}},
+	{"₽", []string{"RUB"}},
+	{"¥", []string{"JPY", "CNY"}},
+	{"$", []string{"USD", "CAD", "AUD", "NZD", "MXN", "SGD", "HKD"}},
+}
+
+// tlds maps country code top-level domains to the currency of the
+// shops using them.
+var tlds = map[string]string{
+	"de": "EUR", "at": "EUR", "fr": "EUR", "it": "EUR", "es": "EUR",
+	"nl": "EUR", "be": "EUR", "ie": "EUR", "fi": "EUR", "pt": "EUR",
+	"gr": "EUR", "lu": "EUR", "sk": "EUR", "si": "EUR", "eu": "EUR",
+	"uk": "GBP", "ch": "CHF", "se": "SEK", "no": "NOK", "dk": "DKK",
+	"is": "ISK", "pl": "PLN", "cz": "CZK", "ca": "CAD", "au": "AUD",
+	"nz": "NZD", "jp": "JPY", "cn": "CNY", "in": "INR", "br": "BRL",
+	"mx": "MXN", "sg": "SGD", "hk": "HKD", "kr": "KRW",
+}
+
+// code matches a currency code written next to an amount.
+var code = regexp.MustCompile(`\b[A-Z]{3}\b`)
+
+// Detect returns the ISO code of the currency of the price text, and
+// where it was found. A currency code in the text decides, then the
+// currency of the offer, which may be a code or a symbol, then a
+// symbol in the text and finally the currency of the domain of the
+// url the price was found on. Symbols shared by several currencies,
+// such as $, are resolved by the domain. domains maps hosts to their
+// currency and is looked up before the top-level domain.
+func Detect(text, offer, host string, domains map[string]string) (string, string) {
+	for _, match := range code.FindAllString(text, -1) {
+		if IsCurrency(match) {
+			return match, "code"
+		}
+	}
+	domain := domainCurrency(host, domains)
+	offer = strings.TrimSpace(offer)
+	if IsCurrency(strings.ToUpper(offer)) {
+		return strings.ToUpper(offer), "offer"
+	}
+	if symbol := fromSymbol(offer, domain); symbol != "" {
+		return symbol, "offer"
+	}
+	if symbol := fromSymbol(text, domain); symbol != "" {
+		return symbol, "symbol"
+	}
+	if domain != "" {
+		return domain, "domain"
+	}
+	return "", ""
+}
+
+// fromSymbol returns the currency of the first symbol in text, the
+// currency of the domain when it uses the symbol.
+func fromSymbol(text, domain string) string {
+	if text == "" {
+		return ""
+	}
+	for _, s := range symbols {
+		if !strings.Contains(text, s.symbol) {
+			continue
+		}
+		for _, code := range s.codes {
+			if code == domain {
+				return code
+			}
+		}
+		return s.codes[0]
+	}
+	return ""
+}
+
+// domainCurrency returns the currency of host from domains, matching
+// the host and its parent domains, or else from its top-level domain.
+func domainCurrency(host string, domains map[string]string) string {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for name := host; name != ""; {
+		if code, ok := domains[name]; ok {
+			return strings.ToUpper(code)
+		}
+		_, parent, found := strings.Cut(name, ".")
+		if !found {
+			break
+		}
+		name = parent
+	}
+	if i := strings.LastIndex(host, "."); i >= 0 {
+		return tlds[host[i+1:]]
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package price
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	domains := map[string]string{"thomann.de": "EUR", "shop.example.com": "gbp"}
+	tests := []struct {
+		text   string
+		offer  string
+		host   string
+		want   string
+		source string
+	}{
+		{"1299 USD", "EUR", "www.thomann.de", "USD", "code"},
+		{"€1.299,00", "usd", "", "USD", "offer"},
+		{"1.299,00", "€", "", "EUR", "offer"},
+		{"1.299,00", "$", "www.long-mcquade.ca", "CAD", "offer"},
+		{"€1.299,00", "", "", "EUR", "symbol"},
+		{"£849.99", "", "www.sweetwater.com", "GBP", "symbol"},
+		{"$849.99", "", "www.sweetwater.com", "USD", "symbol"},
+		{"$849.99", "", "www.long-mcquade.ca", "CAD", "symbol"},
+		{"US$849.99", "", "www.long-mcquade.ca", "USD", "symbol"},
+		{"C$849.99", "", "", "CAD", "symbol"},
+		{"1 299 kr", "", "www.4sound.no", "NOK", "symbol"},
+		{"1 299 kr", "", "", "SEK", "symbol"},
+		{"¥12,800", "", "www.ikebe-gakki.jp", "JPY", "symbol"},
+		{"1.299,00", "", "www.thomann.de", "EUR", "domain"},
+		{"1.299,00", "", "www.musicstore.at", "EUR", "domain"},
+		{"849.99", "", "www.gear4music.co.uk", "GBP", "domain"},
+		{"849.99", "", "cdn.shop.example.com", "GBP", "domain"},
+		{"849.99", "", "www.sweetwater.com", "", ""},
+		{"849.99 ABC", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		code, source := Detect(tt.text, tt.offer, tt.host, domains)
+		if code != tt.want || source != tt.source {
+			t.Errorf("Detect(%q, %q, %q) = %s, %s, want %s, %s", tt.text, tt.offer, tt.host, code, source, tt.want, tt.source)
+		}
+	}
+}
+
+func TestMinorDigits(t *testing.T) {
+	tests := map[string]int{"USD": 2, "EUR": 2, "JPY": 0, "KRW": 0, "BHD": 3, "XYZ": 2}
+	for code, want := range tests {
+		if got := MinorDigits(code); got != want {
+			t.Errorf("MinorDigits(%s) = %d, want %d", code, got, want)
+		}
+	}
+}
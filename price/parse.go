@@ -0,0 +1,191 @@
+package price
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseAmount returns the amount of the price text in minor units of a
+// currency with digits decimal places, e.g. 129900 for "€1.299,00" and
+// "$1,299.00" with 2. Currency symbols and codes around the amount are
+// ignored, of several amounts such as a range the first is parsed. A
+// minus sign before the amount or its currency keeps it negative, as
+// in "-5.00" and "-€5,00".
+//
+// The separators of the locale formats are told apart by position:
+// with both . and , present the last one separates the decimals, and
+// a single separator does unless it is followed by exactly three
+// digits in a currency without three decimal places, e.g. "1.299" and
+// "1,299" are both 1299 while "0.999" is below one. Spaces and
+// apostrophes group thousands, as in "1 299,00" and "1'299.00", and a
+// trailing ",-" stands for no cents.
+func ParseAmount(text string, digits int) (int64, error) {
+	number := extractNumber(text)
+	if number == "" {
+		return 0, fmt.Errorf("no amount in %q", text)
+	}
+	decimal, err := normalize(number, digits)
+	if err != nil {
+		return 0, fmt.Errorf("%v in %q", err, text)
+	}
+	return toMinor(decimal, digits)
+}
+
+// isGroup reports whether r groups thousands: spaces including the
+// no-break and thin spaces of French and Swiss formats, and the
+// apostrophes of Swiss formats.
+func isGroup(r rune) bool {
+	return r == '\'' || r == '’' || r == ' ' || r == ' ' || r == ' ' || r == ' '
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// isMinus reports whether r is a hyphen-minus or a minus sign.
+func isMinus(r rune) bool {
+	return r == '-' || r == '−'
+}
+
+// negative reports whether prefix, the text before the digits of an
+// amount, starts or ends with a minus sign.
+func negative(prefix string) bool {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(prefix)
+	last, _ := utf8.DecodeLastRuneInString(prefix)
+	return isMinus(first) || isMinus(last)
+}
+
+// extractNumber returns the first run of digits, separators and group
+// characters of text, without a trailing separator or a dash standing
+// for no cents. It starts with - for a negative amount.
+func extractNumber(text string) string {
+	start := strings.IndexFunc(text, isDigit)
+	if start < 0 {
+		return ""
+	}
+	var number []rune
+	if negative(text[:start]) {
+		number = append(number, '-')
+	}
+	for _, r := range text[start:] {
+		if isDigit(r) || r == '.' || r == ',' || isGroup(r) {
+			number = append(number, r)
+			continue
+		}
+		break
+	}
+	return strings.TrimRightFunc(string(number), func(r rune) bool {
+		return r == '.' || r == ',' || isGroup(r)
+	})
+}
+
+// normalize turns number into a plain decimal, e.g. 1299.00.
+func normalize(number string, digits int) (string, error) {
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+	number = strings.Map(func(r rune) rune {
+		if isGroup(r) {
+			return -1
+		}
+		return r
+	}, number)
+
+	dots, commas := strings.Count(number, "."), strings.Count(number, ",")
+	var decimalSep, groupSep string
+	switch {
+	case dots == 0 && commas == 0:
+		return sign + number, nil
+	case dots > 0 && commas > 0:
+		decimalSep, groupSep = ",", "."
+		if strings.LastIndex(number, ".") > strings.LastIndex(number, ",") {
+			decimalSep, groupSep = ".", ","
+		}
+		if strings.Count(number, decimalSep) > 1 {
+			return "", fmt.Errorf("ambiguous separators")
+		}
+	default:
+		sep := "."
+		if commas > 0 {
+			sep = ","
+		}
+		i := strings.LastIndex(number, sep)
+		// A leading 0 is no group of thousands, "0.999" is a decimal
+		first, last := number[:i], number[i+1:]
+		if strings.Count(number, sep) > 1 || (len(last) == 3 && digits != 3 && first != "0") {
+			groupSep = sep
+		} else {
+			decimalSep = sep
+		}
+	}
+
+	integer, fraction := number, ""
+	if decimalSep != "" {
+		i := strings.LastIndex(number, decimalSep)
+		integer, fraction = number[:i], number[i+1:]
+	}
+	if groupSep != "" {
+		groups := strings.Split(integer, groupSep)
+		for _, group := range groups[1:] {
+			if len(group) < 2 || len(group) > 3 {
+				return "", fmt.Errorf("misplaced separator")
+			}
+		}
+		if len(groups) > 1 && len(groups[len(groups)-1]) != 3 {
+			return "", fmt.Errorf("misplaced separator")
+		}
+		integer = strings.Join(groups, "")
+	}
+	if integer == "" {
+		integer = "0"
+	}
+	if fraction == "" {
+		return sign + integer, nil
+	}
+	return sign + integer + "." + fraction, nil
+}
+
+// toMinor converts the plain decimal to minor units of a currency with
+// digits decimal places, rounding half away from zero.
+func toMinor(decimal string, digits int) (int64, error) {
+	amount, ok := new(big.Rat).SetString(decimal)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", decimal)
+	}
+	return round(amount.Mul(amount, pow10(digits)))
+}
+
+// round rounds amount half away from zero to an int64.
+func round(amount *big.Rat) (int64, error) {
+	half := big.NewRat(1, 2)
+	if amount.Sign() < 0 {
+		half.Neg(half)
+	}
+	rounded := new(big.Rat).Add(amount, half)
+	quotient := new(big.Int).Quo(rounded.Num(), rounded.Denom())
+	if !quotient.IsInt64() {
+		return 0, fmt.Errorf("amount %s out of range", amount.FloatString(0))
+	}
+	return quotient.Int64(), nil
+}
+
+func pow10(digits int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil))
+}
+
+// Format returns the minor units as a plain decimal with digits
+// decimal places, e.g. 1299.00 for 129900 and 2.
+func Format(minor int64, digits int) string {
+	if digits == 0 {
+		return strconv.FormatInt(minor, 10)
+	}
+	return new(big.Rat).SetFrac(big.NewInt(minor), pow10(digits).Num()).FloatString(digits)
+}
@@ -0,0 +1,86 @@
+package price
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		text   string
+		digits int
+		want   int64
+	}{
+		{"€1.299,00", 2, 129900},
+		{"$1,299.00", 2, 129900},
+		{"1.299", 2, 129900},
+		{"1,299", 2, 129900},
+		{"1.299", 3, 1299},
+		{"1,299", 3, 1299},
+		{"1299 USD", 2, 129900},
+		{"849.99", 2, 84999},
+		{"849,99 €", 2, 84999},
+		{"1.234.567,89", 2, 123456789},
+		{"1,234,567", 2, 123456700},
+		{"1 299,00 €", 2, 129900},
+		{"1 299,00 €", 2, 129900},
+		{"CHF 1'299.00", 2, 129900},
+		{"Fr. 1’299.–", 2, 129900},
+		{"€ 49,-", 2, 4900},
+		{"£0.999", 2, 100},
+		{"0,999", 2, 100},
+		{"0.999", 3, 999},
+		{"£0.99", 2, 99},
+		{"¥12,800", 0, 12800},
+		{"12.345", 0, 12345},
+		{"$10.00 - $20.00", 2, 1000},
+		{"from $849.99", 2, 84999},
+		{"849.995", 3, 849995},
+		{"1.299,995", 2, 130000},
+		{"0.005", 2, 1},
+		{"-5.00", 2, -500},
+		{"−5,00 €", 2, -500},
+		{"-€5,00", 2, -500},
+		{"EUR -1.299,00", 2, -129900},
+		{"-£0.999", 2, -100},
+		{"5.00-", 2, 500},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.text, tt.digits)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAmount(%q, %d) = %d, %v, want %d", tt.text, tt.digits, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseAmountRejects(t *testing.T) {
+	tests := []string{
+		"",
+		"call for price",
+		"1,2,3",
+		"1.299.00,00.00",
+		"12,34.567",
+		"1.2345,00",
+	}
+	for _, text := range tests {
+		if got, err := ParseAmount(text, 2); err == nil {
+			t.Errorf("ParseAmount(%q) = %d, want an error", text, got)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		minor  int64
+		digits int
+		want   string
+	}{
+		{129900, 2, "1299.00"},
+		{5, 2, "0.05"},
+		{-500, 2, "-5.00"},
+		{12800, 0, "12800"},
+		{1299, 3, "1.299"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.minor, tt.digits); got != tt.want {
+			t.Errorf("Format(%d, %d) = %s, want %s", tt.minor, tt.digits, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package price normalises the prices of product offers, which the
+// spiders scrape as text such as "€1.299,00", "$1,299.99" or
+// "1299 USD", into an amount in minor units and an ISO 4217 currency
+// code, optionally converted to a base currency.
+package price
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+// The fields Normalizer adds to each offer. The model's Offer has no
+// place for them, so those of the first offer are also kept as
+// additional properties of the same names, along with its currency as
+// PropertyCurrency.
+const (
+	FieldMinor        = "priceMinor"
+	FieldBaseMinor    = "basePriceMinor"
+	FieldBaseCurrency = "baseCurrency"
+	PropertyCurrency  = "priceCurrency"
+)
+
+// Normalizer is a pipeline.Stage normalising the price and currency
+// of each offer of a product. The price becomes a plain decimal such
+// as 1299.00 and the currency its ISO code, see ParseAmount and
+// Detect. Prices that cannot be normalised are reported and left as
+// they are.
+type Normalizer struct {
+	// DefaultCurrency of prices whose currency cannot be detected.
+	DefaultCurrency string
+	// BaseCurrency, when set, prices are also converted to with Rates.
+	BaseCurrency string
+	Rates        *Rates
+	// Domains maps hosts to the currency of their prices, see Detect.
+	Domains map[string]string
+}
+
+// New returns a new Normalizer
+func New(options ...func(*Normalizer)) *Normalizer {
+
+	n := &Normalizer{}
+	for _, option := range options {
+		option(n)
+	}
+	return n
+}
+
+func WithDefaultCurrency(code string) func(*Normalizer) {
+	return func(n *Normalizer) {
+		n.DefaultCurrency = code
+	}
+}
+
+func WithBaseCurrency(code string, rates *Rates) func(*Normalizer) {
+	return func(n *Normalizer) {
+		n.BaseCurrency = code
+		n.Rates = rates
+	}
+}
+
+func WithDomains(domains map[string]string) func(*Normalizer) {
+	return func(n *Normalizer) {
+		n.Domains = domains
+	}
+}
+
+func (n *Normalizer) Name() string {
+	return "price"
+}
+
+// Process normalises the offers of a product record.
+func (n *Normalizer) Process(record *pipeline.Record) error {
+	if !record.IsProduct {
+		return nil
+	}
+	offers, _ := record.Fields["offers"].([]interface{})
+	var host string
+	if raw, ok := record.Fields["url"].(string); ok {
+		if u, err := url.Parse(raw); err == nil {
+			host = u.Hostname()
+		}
+	}
+
+	for i, item := range offers {
+		offer, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var text string
+		switch price := offer["price"].(type) {
+		case string:
+			text = price
+		case json.Number:
+			text = price.String()
+		}
+		if text == "" {
+			continue
+		}
+		offerCurrency, _ := offer["currency"].(string)
+		code, _ := Detect(text, offerCurrency, host, n.Domains)
+		if code == "" {
+			code = n.DefaultCurrency
+		}
+		if code == "" {
+			record.Report("offers/*/currency", "no currency for %q", text)
+			continue
+		}
+
+		digits := MinorDigits(code)
+		var minor int64
+		var err error
+		if number, ok := offer["price"].(json.Number); ok {
+			minor, err = toMinor(number.String(), digits)
+		} else {
+			minor, err = ParseAmount(text, digits)
+		}
+		if err != nil {
+			record.Report("offers/*/price", "%v", err)
+			continue
+		}
+		offer["price"] = Format(minor, digits)
+		offer["currency"] = code
+		offer[FieldMinor] = json.Number(strconv.FormatInt(minor, 10))
+		properties := [][2]string{
+			{FieldMinor, strconv.FormatInt(minor, 10)},
+			{PropertyCurrency, code},
+		}
+
+		if n.BaseCurrency != "" {
+			converted, err := n.Rates.Convert(minor, code, n.BaseCurrency)
+			if err != nil {
+				record.Report("offers/*/price", "%v", err)
+			} else {
+				offer[FieldBaseMinor] = json.Number(strconv.FormatInt(converted, 10))
+				offer[FieldBaseCurrency] = n.BaseCurrency
+				properties = append(properties,
+					[2]string{FieldBaseMinor, strconv.FormatInt(converted, 10)},
+					[2]string{FieldBaseCurrency, n.BaseCurrency})
+			}
+		}
+		if i == 0 {
+			for _, property := range properties {
+				record.SetProperty(property[0], property[1])
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package price
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Rates is a table of exchange rates read from a YAML or JSON file:
+//
+//	base: USD
+//	date: "2026-10-01"
+//	rates:
+//	  EUR: 0.92
+//	  GBP: 0.79
+//
+// Each rate is the amount of its currency worth one unit of Base.
+type Rates struct {
+	Base  string             `yaml:"base" json:"base"`
+	Date  string             `yaml:"date" json:"date"`
+	Rates map[string]float64 `yaml:"rates" json:"rates"`
+}
+
+// LoadRates reads and checks the Rates of a file.
+func LoadRates(path string) (*Rates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("price.LoadRates() Error reading %s: %v", path, err), err)
+	}
+	var rates Rates
+	if err := yaml.Unmarshal(data, &rates); err != nil {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("price.LoadRates() Error parsing %s: %v", path, err), err)
+	}
+	rates.Base = strings.ToUpper(rates.Base)
+	if !IsCurrency(rates.Base) {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("price.LoadRates() %s: unknown base currency %q", path, rates.Base), nil)
+	}
+	for code, rate := range rates.Rates {
+		if !IsCurrency(code) {
+			return nil, errors.NewChuxParserError(fmt.Sprintf("price.LoadRates() %s: unknown currency %q", path, code), nil)
+		}
+		if rate <= 0 {
+			return nil, errors.NewChuxParserError(fmt.Sprintf("price.LoadRates() %s: rate of %s must be positive, is %v", path, code, rate), nil)
+		}
+	}
+	return &rates, nil
+}
+
+// Has reports whether amounts in the currency code can be converted.
+func (r *Rates) Has(code string) bool {
+	_, ok := r.rate(code)
+	return ok
+}
+
+func (r *Rates) rate(code string) (*big.Rat, bool) {
+	if code == r.Base {
+		return big.NewRat(1, 1), true
+	}
+	rate, ok := r.Rates[code]
+	if !ok {
+		return nil, false
+	}
+	return new(big.Rat).SetFloat64(rate), true
+}
+
+// Convert converts minor units of the currency from to minor units of
+// the currency to, rounding half away from zero.
+func (r *Rates) Convert(minor int64, from, to string) (int64, error) {
+	fromRate, ok := r.rate(from)
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", from)
+	}
+	toRate, ok := r.rate(to)
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", to)
+	}
+	amount := new(big.Rat).SetInt64(minor)
+	amount.Quo(amount, pow10(MinorDigits(from)))
+	amount.Mul(amount, toRate)
+	amount.Quo(amount, fromRate)
+	return round(amount.Mul(amount, pow10(MinorDigits(to))))
+}
@@ -0,0 +1,234 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/logging"
+	"github.com/chuxorg/chux-parser/parsing"
+	"github.com/chuxorg/chux-parser/s3"
+)
+
+// ObjectSource downloads single objects named by S3 event
+// notifications. It is implemented by s3.Bucket.
+type ObjectSource interface {
+	DownloadObject(key string) (*s3.File, error)
+}
+
+// FileParser parses a downloaded File. It is implemented by
+// parsing.Parser.
+type FileParser interface {
+	Parse(file s3.File) parsing.ParseResult
+}
+
+// StatusWriter records the Status of parsed objects. It is
+// implemented by s3.Bucket.
+type StatusWriter interface {
+	SetStatus(key string, status s3.Status) error
+}
+
+// Consumer is the long-running counterpart of the batch run. It
+// receives S3 ObjectCreated notifications from a Queue and parses
+// only the objects they name. A message is deleted once all of its
+// objects were parsed, otherwise it becomes visible again and is
+// retried after its visibility timeout. A message is retried whole, so
+// the objects it names before the one that failed are parsed again.
+// S3 puts one object in each notification, only hand-made messages
+// naming several objects parse any twice.
+type Consumer struct {
+	Queue  Queue
+	Source ObjectSource
+	Parser FileParser
+	// BucketName, when set, skips records of any other bucket.
+	BucketName string
+	// VisibilityTimeout is re-applied at half its length while a
+	// message is being parsed, so long parses keep their message.
+	VisibilityTimeout time.Duration
+	// WaitTime is the long polling duration of each receive.
+	WaitTime time.Duration
+	// MaxMessages is the number of messages received at once.
+	MaxMessages int
+	// Status, when set, records the result of each object with RunID.
+	Status StatusWriter
+	RunID  string
+	Logger *logging.Logger
+}
+
+func NewConsumer(options ...func(*Consumer)) *Consumer {
+
+	consumer := &Consumer{
+		VisibilityTimeout: 5 * time.Minute,
+		WaitTime:          20 * time.Second,
+		MaxMessages:       1,
+	}
+	for _, option := range options {
+		option(consumer)
+	}
+	consumer.Logger.Debug("Creating new Consumer struct")
+	return consumer
+}
+
+func ConsumerWithQueue(q Queue) func(*Consumer) {
+	return func(c *Consumer) {
+		c.Queue = q
+	}
+}
+
+func ConsumerWithSource(source ObjectSource) func(*Consumer) {
+	return func(c *Consumer) {
+		c.Source = source
+	}
+}
+
+func ConsumerWithParser(parser FileParser) func(*Consumer) {
+	return func(c *Consumer) {
+		c.Parser = parser
+	}
+}
+
+func ConsumerWithBucketName(name string) func(*Consumer) {
+	return func(c *Consumer) {
+		c.BucketName = name
+	}
+}
+
+func ConsumerWithVisibilityTimeout(timeout time.Duration) func(*Consumer) {
+	return func(c *Consumer) {
+		c.VisibilityTimeout = timeout
+	}
+}
+
+func ConsumerWithStatus(status StatusWriter, runID string) func(*Consumer) {
+	return func(c *Consumer) {
+		c.Status = status
+		c.RunID = runID
+	}
+}
+
+func ConsumerWithLogger(l *logging.Logger) func(*Consumer) {
+	return func(c *Consumer) {
+		c.Logger = l
+	}
+}
+
+// Run receives and processes messages until the context is done.
+func (c *Consumer) Run(ctx context.Context) error {
+	c.Logger.Info("Consumer.Run() waiting for S3 notifications")
+	for {
+		messages, err := c.Queue.Receive(ctx, c.MaxMessages, c.WaitTime)
+		if ctx.Err() != nil {
+			c.Logger.Info("Consumer.Run() stopping: %v", ctx.Err())
+			return nil
+		}
+		if err != nil {
+			c.Logger.Error("Consumer.Run() %v", err)
+			if err := sleep(ctx, c.WaitTime); err != nil {
+				return nil
+			}
+			continue
+		}
+		for _, msg := range messages {
+			c.Process(ctx, msg)
+		}
+	}
+}
+
+// Process parses the objects of a single message and deletes the
+// message on success. It returns the results of the parsed objects.
+func (c *Consumer) Process(ctx context.Context, msg Message) []parsing.ParseResult {
+	c.Logger.Debug("Consumer.Process() called for message %s", msg.ID)
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	go c.heartbeat(heartbeatCtx, msg)
+	results, err := c.handle(msg)
+	stopHeartbeat()
+
+	if err != nil {
+		c.Logger.Error("Consumer.Process() message %s will be retried: %v", msg.ID, err)
+		return results
+	}
+	if err := c.Queue.Delete(ctx, msg); err != nil {
+		c.Logger.Error("Consumer.Process() %v", err)
+	}
+	return results
+}
+
+func (c *Consumer) handle(msg Message) ([]parsing.ParseResult, error) {
+	event, err := s3.ParseEvent(msg.Body)
+	if err != nil {
+		// Retrying cannot fix a malformed body, it is dropped.
+		c.Logger.Warning("Consumer.handle() Dropping message %s, not an S3 event: %v", msg.ID, err)
+		return nil, nil
+	}
+
+	var results []parsing.ParseResult
+	for _, record := range event.Records {
+		if !record.IsObjectCreated() {
+			c.Logger.Debug("Consumer.handle() Ignoring %s event", record.EventName)
+			continue
+		}
+		if c.BucketName != "" && record.S3.Bucket.Name != c.BucketName {
+			c.Logger.Warning("Consumer.handle() Ignoring object of bucket %s", record.S3.Bucket.Name)
+			continue
+		}
+		key, err := record.ObjectKey()
+		if err != nil {
+			return results, errors.NewChuxParserError("Consumer.handle() Invalid object key "+record.S3.Object.Key, err)
+		}
+
+		file, err := c.Source.DownloadObject(key)
+		if err != nil {
+			return results, err
+		}
+		if file == nil {
+			continue
+		}
+		result := c.Parser.Parse(*file)
+		results = append(results, result)
+		c.setStatus(key, result)
+		if result.Err != nil {
+			return results, result.Err
+		}
+		c.Logger.Info("Consumer.handle() Parsed %s: %d Products, %d Articles, %d failed, %d skipped, %d retries", key, result.Products, result.Articles, result.Failed, result.Skipped, result.Retries)
+	}
+	return results, nil
+}
+
+// setStatus records result when a StatusWriter is set. Failing to do
+// so does not fail the message. Quarantined objects were already
+// tagged when they were quarantined.
+func (c *Consumer) setStatus(key string, result parsing.ParseResult) {
+	if c.Status == nil || result.Quarantined {
+		return
+	}
+	if err := c.Status.SetStatus(key, result.Status(c.RunID)); err != nil {
+		c.Logger.Warning("Consumer.setStatus() %v", err)
+	}
+}
+
+// heartbeat extends the visibility of msg until the context is done.
+// Without a VisibilityTimeout of at least 2ns there is no heartbeat.
+func (c *Consumer) heartbeat(ctx context.Context, msg Message) {
+	interval := c.VisibilityTimeout / 2
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := c.Queue.ExtendVisibility(ctx, msg, c.VisibilityTimeout)
+			if err != nil {
+				if ctx.Err() == nil {
+					c.Logger.Warning("Consumer.heartbeat() %v", err)
+				}
+				continue
+			}
+			c.Logger.Debug("Consumer.heartbeat() Extended visibility of message %s", msg.ID)
+		}
+	}
+}
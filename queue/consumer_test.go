@@ -0,0 +1,272 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chuxorg/chux-parser/parsing"
+	"github.com/chuxorg/chux-parser/s3"
+)
+
+// event returns an S3 notification of objects created in bucket.
+func event(bucket string, keys ...string) string {
+	var records []string
+	for _, key := range keys {
+		records = append(records, fmt.Sprintf(`{"eventSource":"aws:s3","eventName":"ObjectCreated:Put","s3":{"bucket":{"name":%q},"object":{"key":%q}}}`, bucket, key))
+	}
+	return `{"Records":[` + strings.Join(records, ",") + `]}`
+}
+
+// fakeSource serves every key as a File, failing the keys in fail.
+type fakeSource struct {
+	fail map[string]bool
+}
+
+func (s *fakeSource) DownloadObject(key string) (*s3.File, error) {
+	if s.fail[key] {
+		return nil, errors.New("download failed")
+	}
+	return &s3.File{Path: key, Company: "sweetwater"}, nil
+}
+
+// fakeParser records the parsed keys, failing those in fail, and
+// takes delay for each.
+type fakeParser struct {
+	mu     sync.Mutex
+	parsed []string
+	fail   map[string]bool
+	delay  time.Duration
+}
+
+func (p *fakeParser) Parse(file s3.File) parsing.ParseResult {
+	time.Sleep(p.delay)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.parsed = append(p.parsed, file.Path)
+	result := parsing.ParseResult{Path: file.Path, Company: file.Company, Products: 1}
+	if p.fail[file.Path] {
+		result.Err = errors.New("read failed")
+	}
+	return result
+}
+
+// countingQueue counts the visibility extensions of a Queue.
+type countingQueue struct {
+	Queue
+	mu       sync.Mutex
+	extended int
+}
+
+func (q *countingQueue) ExtendVisibility(ctx context.Context, msg Message, timeout time.Duration) error {
+	q.mu.Lock()
+	q.extended++
+	q.mu.Unlock()
+	return q.Queue.ExtendVisibility(ctx, msg, timeout)
+}
+
+func (q *countingQueue) Extended() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.extended
+}
+
+// testQueue is a Queue implementation the Consumer is tested with.
+type testQueue struct {
+	name string
+	// open returns an empty Queue with the visibility timeout, a
+	// function sending a message to it and one counting its messages.
+	open func(t *testing.T, visibility time.Duration) (q Queue, send func(body string), count func() int)
+}
+
+var queues = []testQueue{
+	{"memory", func(t *testing.T, visibility time.Duration) (Queue, func(string), func() int) {
+		q := NewMemory(visibility)
+		return q, func(body string) { q.Send(body) }, q.Len
+	}},
+	{"dir", func(t *testing.T, visibility time.Duration) (Queue, func(string), func() int) {
+		dir := t.TempDir()
+		n := 0
+		send := func(body string) {
+			n++
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d.json", n)), []byte(body), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		count := func() int {
+			paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return len(paths)
+		}
+		return NewDir(dir, visibility), send, count
+	}},
+}
+
+// receive returns the next visible message of q.
+func receive(t *testing.T, q Queue) Message {
+	t.Helper()
+	messages, err := q.Receive(context.Background(), 1, time.Second)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Receive() = %v, %v, want 1 message", messages, err)
+	}
+	return messages[0]
+}
+
+func TestConsumerProcess(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		fail    string
+		parsed  []string
+		deleted bool
+	}{
+		{"parsed", event("crawls", "sweetwater/day1.jl"), "", []string{"sweetwater/day1.jl"}, true},
+		{"url encoded key", event("crawls", "sweetwater/day+1%281%29.jl"), "", []string{"sweetwater/day 1(1).jl"}, true},
+		{"other bucket", event("other", "sweetwater/day1.jl"), "", nil, true},
+		{"not an S3 event", "not json", "", nil, true},
+		{"test event", `{"Service":"Amazon S3","Event":"s3:TestEvent"}`, "", nil, true},
+		{"download fails", event("crawls", "sweetwater/day1.jl"), "download", nil, false},
+		{"parse fails", event("crawls", "sweetwater/day1.jl"), "parse", []string{"sweetwater/day1.jl"}, false},
+	}
+	for _, tq := range queues {
+		for _, tt := range tests {
+			t.Run(tq.name+"/"+tt.name, func(t *testing.T) {
+				q, send, count := tq.open(t, time.Minute)
+				source := &fakeSource{fail: map[string]bool{}}
+				parser := &fakeParser{fail: map[string]bool{}}
+				switch tt.fail {
+				case "download":
+					source.fail["sweetwater/day1.jl"] = true
+				case "parse":
+					parser.fail["sweetwater/day1.jl"] = true
+				}
+				consumer := NewConsumer(
+					ConsumerWithQueue(q),
+					ConsumerWithSource(source),
+					ConsumerWithParser(parser),
+					ConsumerWithBucketName("crawls"),
+				)
+
+				send(tt.body)
+				consumer.Process(context.Background(), receive(t, q))
+				if strings.Join(parser.parsed, ",") != strings.Join(tt.parsed, ",") {
+					t.Errorf("parsed %v, want %v", parser.parsed, tt.parsed)
+				}
+				if deleted := count() == 0; deleted != tt.deleted {
+					t.Errorf("message deleted %v, want %v", deleted, tt.deleted)
+				}
+			})
+		}
+	}
+}
+
+// A message that failed becomes visible again once its visibility
+// timeout expired and is retried whole, parsing again the objects
+// before the one that failed.
+func TestConsumerRetry(t *testing.T) {
+	for _, tq := range queues {
+		t.Run(tq.name, func(t *testing.T) {
+			q, send, count := tq.open(t, 100*time.Millisecond)
+			parser := &fakeParser{fail: map[string]bool{"sweetwater/day2.jl": true}}
+			consumer := NewConsumer(
+				ConsumerWithQueue(q),
+				ConsumerWithSource(&fakeSource{}),
+				ConsumerWithParser(parser),
+				ConsumerWithVisibilityTimeout(time.Hour),
+			)
+
+			send(event("crawls", "sweetwater/day1.jl", "sweetwater/day2.jl"))
+			consumer.Process(context.Background(), receive(t, q))
+			if messages, err := q.Receive(context.Background(), 1, 0); err != nil || len(messages) != 0 {
+				t.Fatalf("failed message visible before its timeout: %v, %v", messages, err)
+			}
+
+			delete(parser.fail, "sweetwater/day2.jl")
+			time.Sleep(150 * time.Millisecond)
+			consumer.Process(context.Background(), receive(t, q))
+			want := "sweetwater/day1.jl,sweetwater/day2.jl,sweetwater/day1.jl,sweetwater/day2.jl"
+			if got := strings.Join(parser.parsed, ","); got != want {
+				t.Errorf("parsed %s, want %s", got, want)
+			}
+			if count() != 0 {
+				t.Error("retried message not deleted")
+			}
+		})
+	}
+}
+
+// The visibility of a message is extended while it is parsed, so a
+// parse longer than the visibility timeout keeps its message.
+func TestConsumerHeartbeat(t *testing.T) {
+	for _, tq := range queues {
+		t.Run(tq.name, func(t *testing.T) {
+			visibility := 100 * time.Millisecond
+			inner, send, count := tq.open(t, visibility)
+			q := &countingQueue{Queue: inner}
+			consumer := NewConsumer(
+				ConsumerWithQueue(q),
+				ConsumerWithSource(&fakeSource{}),
+				ConsumerWithParser(&fakeParser{delay: 3 * visibility}),
+				ConsumerWithVisibilityTimeout(visibility),
+			)
+
+			send(event("crawls", "sweetwater/day1.jl"))
+			msg := receive(t, q)
+			done := make(chan struct{})
+			go func() {
+				consumer.Process(context.Background(), msg)
+				close(done)
+			}()
+			time.Sleep(2 * visibility)
+			if messages, err := inner.Receive(context.Background(), 1, 0); err != nil || len(messages) != 0 {
+				t.Errorf("message visible while it is parsed: %v, %v", messages, err)
+			}
+			<-done
+			if q.Extended() < 2 {
+				t.Errorf("visibility extended %d times, want at least 2", q.Extended())
+			}
+			if count() != 0 {
+				t.Error("message not deleted")
+			}
+		})
+	}
+}
+
+func TestConsumerRun(t *testing.T) {
+	q := NewMemory(time.Minute)
+	parser := &fakeParser{}
+	consumer := NewConsumer(
+		ConsumerWithQueue(q),
+		ConsumerWithSource(&fakeSource{}),
+		ConsumerWithParser(parser),
+	)
+	consumer.WaitTime = 10 * time.Millisecond
+	q.Send(event("crawls", "sweetwater/day1.jl"))
+	q.Send(event("crawls", "sweetwater/day2.jl"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	go func() {
+		for q.Len() > 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+	}()
+	if err := consumer.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := strings.Join(parser.parsed, ","); got != "sweetwater/day1.jl,sweetwater/day2.jl" {
+		t.Errorf("parsed %s", got)
+	}
+	if q.Len() != 0 {
+		t.Errorf("%d messages left", q.Len())
+	}
+}
@@ -0,0 +1,98 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chuxorg/chux-parser/errors"
+)
+
+// DirQueue is a file-backed Queue. Every *.json file in Dir is a
+// message whose body is the file content, deleting a message removes
+// its file. Dropping S3 event notifications into the directory feeds
+// the Consumer without AWS.
+type DirQueue struct {
+	Dir string
+	// VisibilityTimeout is applied to received messages.
+	VisibilityTimeout time.Duration
+	mu                sync.Mutex
+	invisibleUntil    map[string]time.Time
+}
+
+func NewDir(dir string, visibilityTimeout time.Duration) *DirQueue {
+	return &DirQueue{
+		Dir:               dir,
+		VisibilityTimeout: visibilityTimeout,
+		invisibleUntil:    map[string]time.Time{},
+	}
+}
+
+func (q *DirQueue) Receive(ctx context.Context, max int, wait time.Duration) ([]Message, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		received, err := q.receive(max)
+		if err != nil || len(received) > 0 {
+			return received, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, nil
+		}
+		if err := sleep(ctx, 250*time.Millisecond); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (q *DirQueue) receive(max int) ([]Message, error) {
+	paths, err := filepath.Glob(filepath.Join(q.Dir, "*.json"))
+	if err != nil {
+		return nil, errors.NewChuxParserError("DirQueue.Receive() Error listing "+q.Dir, err)
+	}
+	sort.Strings(paths)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	var received []Message
+	for _, path := range paths {
+		if len(received) == max {
+			break
+		}
+		if now.Before(q.invisibleUntil[path]) {
+			continue
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			// the file was removed by another receiver
+			continue
+		}
+		q.invisibleUntil[path] = now.Add(q.VisibilityTimeout)
+		received = append(received, Message{
+			ID:            filepath.Base(path),
+			ReceiptHandle: path,
+			Body:          string(body),
+		})
+	}
+	return received, nil
+}
+
+func (q *DirQueue) Delete(ctx context.Context, msg Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.invisibleUntil, msg.ReceiptHandle)
+	if err := os.Remove(msg.ReceiptHandle); err != nil {
+		return errors.NewChuxParserError("DirQueue.Delete() Error removing "+msg.ReceiptHandle, err)
+	}
+	return nil
+}
+
+func (q *DirQueue) ExtendVisibility(ctx context.Context, msg Message, timeout time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.invisibleUntil[msg.ReceiptHandle] = time.Now().Add(timeout)
+	return nil
+}
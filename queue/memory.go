@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryQueue is an in-process Queue with SQS-like visibility
+// semantics. It is meant for running the Consumer without AWS.
+type MemoryQueue struct {
+	// VisibilityTimeout is applied to received messages.
+	VisibilityTimeout time.Duration
+	mu                sync.Mutex
+	messages          []*memoryMessage
+	nextID            int
+}
+
+type memoryMessage struct {
+	Message
+	invisibleUntil time.Time
+}
+
+func NewMemory(visibilityTimeout time.Duration) *MemoryQueue {
+	return &MemoryQueue{VisibilityTimeout: visibilityTimeout}
+}
+
+// Send adds a message to the queue and returns its ID.
+func (q *MemoryQueue) Send(body string) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextID++
+	id := fmt.Sprintf("%d", q.nextID)
+	q.messages = append(q.messages, &memoryMessage{
+		Message: Message{ID: id, ReceiptHandle: id, Body: body},
+	})
+	return id
+}
+
+// Len returns the number of messages in the queue, visible or not.
+func (q *MemoryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+func (q *MemoryQueue) Receive(ctx context.Context, max int, wait time.Duration) ([]Message, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		if received := q.receive(max); len(received) > 0 {
+			return received, nil
+		}
+		if !time.Now().Before(deadline) {
+			return nil, nil
+		}
+		if err := sleep(ctx, 50*time.Millisecond); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (q *MemoryQueue) receive(max int) []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	var received []Message
+	for _, m := range q.messages {
+		if len(received) == max {
+			break
+		}
+		if now.Before(m.invisibleUntil) {
+			continue
+		}
+		m.invisibleUntil = now.Add(q.VisibilityTimeout)
+		received = append(received, m.Message)
+	}
+	return received
+}
+
+func (q *MemoryQueue) Delete(ctx context.Context, msg Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, m := range q.messages {
+		if m.ReceiptHandle == msg.ReceiptHandle {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("MemoryQueue.Delete() unknown message %s", msg.ID)
+}
+
+func (q *MemoryQueue) ExtendVisibility(ctx context.Context, msg Message, timeout time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, m := range q.messages {
+		if m.ReceiptHandle == msg.ReceiptHandle {
+			m.invisibleUntil = time.Now().Add(timeout)
+			return nil
+		}
+	}
+	return fmt.Errorf("MemoryQueue.ExtendVisibility() unknown message %s", msg.ID)
+}
@@ -0,0 +1,40 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// Message is a single message received from a Queue. The Body
+// carries an S3 event notification.
+type Message struct {
+	ID            string
+	ReceiptHandle string
+	Body          string
+}
+
+// Queue is the source of S3 event notifications for the Consumer.
+// SQSQueue is used in AWS, MemoryQueue and DirQueue stand in for it
+// when running without AWS.
+type Queue interface {
+	// Receives up to max messages, waiting at most wait for the
+	// first one to arrive. Received messages are hidden from other
+	// receivers until their visibility timeout expires.
+	Receive(ctx context.Context, max int, wait time.Duration) ([]Message, error)
+	// Removes a processed message from the queue.
+	Delete(ctx context.Context, msg Message) error
+	// Keeps a message hidden for timeout from now on.
+	ExtendVisibility(ctx context.Context, msg Message, timeout time.Duration) error
+}
+
+// sleep waits for d or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
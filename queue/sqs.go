@@ -0,0 +1,98 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/logging"
+)
+
+// SQSQueue receives S3 event notifications from an Amazon SQS queue.
+type SQSQueue struct {
+	URL     string
+	Session *session.Session
+	Logger  *logging.Logger
+	svc     *sqs.SQS
+}
+
+func NewSQS(options ...func(*SQSQueue)) *SQSQueue {
+
+	q := &SQSQueue{}
+	for _, option := range options {
+		option(q)
+	}
+	if q.Session == nil {
+		q.Session = session.Must(session.NewSession(&aws.Config{
+			Region: aws.String("us-east-1"),
+		}))
+	}
+	q.svc = sqs.New(q.Session)
+	q.Logger.Debug("SQSQueue created for %s", q.URL)
+	return q
+}
+
+func SQSWithURL(url string) func(*SQSQueue) {
+	return func(q *SQSQueue) {
+		q.URL = url
+	}
+}
+
+func SQSWithSession(sess *session.Session) func(*SQSQueue) {
+	return func(q *SQSQueue) {
+		q.Session = sess
+	}
+}
+
+func SQSWithLogger(l *logging.Logger) func(*SQSQueue) {
+	return func(q *SQSQueue) {
+		q.Logger = l
+	}
+}
+
+func (q *SQSQueue) Receive(ctx context.Context, max int, wait time.Duration) ([]Message, error) {
+	out, err := q.svc.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(q.URL),
+		MaxNumberOfMessages: aws.Int64(int64(max)),
+		WaitTimeSeconds:     aws.Int64(int64(wait / time.Second)),
+	})
+	if err != nil {
+		return nil, errors.NewChuxParserError("SQSQueue.Receive() Error receiving messages", err)
+	}
+
+	messages := make([]Message, 0, len(out.Messages))
+	for _, m := range out.Messages {
+		messages = append(messages, Message{
+			ID:            aws.StringValue(m.MessageId),
+			ReceiptHandle: aws.StringValue(m.ReceiptHandle),
+			Body:          aws.StringValue(m.Body),
+		})
+	}
+	return messages, nil
+}
+
+func (q *SQSQueue) Delete(ctx context.Context, msg Message) error {
+	_, err := q.svc.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.URL),
+		ReceiptHandle: aws.String(msg.ReceiptHandle),
+	})
+	if err != nil {
+		return errors.NewChuxParserError("SQSQueue.Delete() Error deleting message "+msg.ID, err)
+	}
+	return nil
+}
+
+func (q *SQSQueue) ExtendVisibility(ctx context.Context, msg Message, timeout time.Duration) error {
+	_, err := q.svc.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(q.URL),
+		ReceiptHandle:     aws.String(msg.ReceiptHandle),
+		VisibilityTimeout: aws.Int64(int64(timeout / time.Second)),
+	})
+	if err != nil {
+		return errors.NewChuxParserError("SQSQueue.ExtendVisibility() Error changing visibility of "+msg.ID, err)
+	}
+	return nil
+}
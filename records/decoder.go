@@ -0,0 +1,271 @@
+package records
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+
+	"github.com/chuxorg/chux-parser/errors"
+)
+
+// headerSize is the number of bytes Detect looks at.
+const headerSize = 64 * 1024
+
+// DefaultMaxRecordSize caps a single NDJSON line unless
+// WithMaxRecordSize sets another limit.
+const DefaultMaxRecordSize = 50 * 1024 * 1024 // 50MB
+
+// Decoder reads the records of a crawl file as raw JSON objects.
+type Decoder interface {
+	// Next returns the next record, or io.EOF after the last one.
+	// A *RecordError reports a malformed record that was skipped,
+	// Next can be called again. Any other error ends the file.
+	Next() (json.RawMessage, error)
+	// Format returns the Format the Decoder reads.
+	Format() Format
+	// Release returns the buffers of the Decoder to their pool. Callers
+	// defer it, as a Decoder abandoned before io.EOF keeps its buffers
+	// otherwise. The Decoder must not be used after it.
+	Release()
+}
+
+// RecordError reports a single malformed record.
+type RecordError struct {
+	// Record is the 1-based position of the record in the file.
+	Record int
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Record, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// OversizeError reports a record longer than the maximum record size.
+// An NDJSON record is skipped and the rest of the file is still read,
+// in the other formats the file ends at the record.
+type OversizeError struct {
+	// Offset is the position of the record's first byte in the content
+	// read by the Decoder, so the record can be read again from its
+	// source.
+	Offset int64
+	// Size is the length of the record in bytes, for JSON arrays and
+	// concatenated JSON the bytes read of it before giving up.
+	Size int64
+	Max  int
+}
+
+func (e *OversizeError) Error() string {
+	return fmt.Sprintf("record of %d bytes exceeds the maximum record size of %d bytes", e.Size, e.Max)
+}
+
+// DeadLetter receives the records a Decoder skips because they are
+// too large, so they can be inspected later. data holds the record, or
+// only its first bytes up to the maximum record size when it did not
+// fit the read buffer. The reason is an OversizeError locating the
+// complete record.
+type DeadLetter interface {
+	DeadLetter(name string, record int, data []byte, reason error) error
+}
+
+// Options are the settings of a Decoder.
+type Options struct {
+	// MaxRecordSize caps the size of a single NDJSON record.
+	MaxRecordSize int
+	// DeadLetter, when set, receives the oversize records.
+	DeadLetter DeadLetter
+}
+
+func WithMaxRecordSize(size int) func(*Options) {
+	return func(o *Options) {
+		o.MaxRecordSize = size
+	}
+}
+
+func WithDeadLetter(deadLetter DeadLetter) func(*Options) {
+	return func(o *Options) {
+		o.DeadLetter = deadLetter
+	}
+}
+
+// NewDecoder returns a Decoder for the records read from r. With
+// Auto the Format is detected from name and the start of r.
+func NewDecoder(r io.Reader, format Format, name string, options ...func(*Options)) (Decoder, error) {
+	opts := Options{MaxRecordSize: DefaultMaxRecordSize}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	buffered := bufio.NewReaderSize(r, headerSize)
+	if format == Auto {
+		header, err := buffered.Peek(headerSize)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, errors.NewChuxParserError(fmt.Sprintf("records.NewDecoder() Error reading %s: %v", name, err), err)
+		}
+		if format, err = Detect(name, header); err != nil {
+			return nil, errors.NewChuxParserError("records.NewDecoder() "+err.Error(), err)
+		}
+	}
+
+	switch format {
+	case NDJSON:
+		return newLineDecoder(buffered, name, opts), nil
+	case JSONArray:
+		return newStreamDecoder(buffered, true, format, opts.MaxRecordSize), nil
+	case Concatenated:
+		return newStreamDecoder(buffered, false, format, opts.MaxRecordSize), nil
+	case CSV:
+		return newCSVDecoder(buffered, ',', format), nil
+	case TSV:
+		return newCSVDecoder(buffered, '\t', format), nil
+	default:
+		msg := fmt.Sprintf("records.NewDecoder() Unsupported format %d for %s", format, name)
+		return nil, errors.NewChuxParserError(msg, nil)
+	}
+}
+
+// streamDecoder reads a top-level array of objects or objects back
+// to back with a streaming json.Decoder, so records may span lines.
+// A syntax error cannot be recovered from and ends the file, so does
+// a record longer than the maximum record size, which is not read
+// any further than that.
+type streamDecoder struct {
+	decoder *json.Decoder
+	reader  *cappedReader
+	array   bool
+	started bool
+	record  int
+	format  Format
+}
+
+func newStreamDecoder(r io.Reader, array bool, format Format, max int) *streamDecoder {
+	reader := &cappedReader{r: r, max: int64(max)}
+	return &streamDecoder{decoder: json.NewDecoder(reader), reader: reader, array: array, format: format}
+}
+
+// cappedReader fails reading more than max bytes past start, the
+// offset of the record being decoded, so a single record cannot
+// exhaust memory. A max of zero or less reads without a cap.
+type cappedReader struct {
+	r     io.Reader
+	max   int64
+	read  int64
+	start int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if c.max > 0 {
+		left := c.start + c.max + 1 - c.read
+		if left <= 0 {
+			return 0, &OversizeError{Offset: c.start, Size: c.read - c.start, Max: int(c.max)}
+		}
+		if int64(len(p)) > left {
+			p = p[:left]
+		}
+	}
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+func (d *streamDecoder) Release() {}
+
+func (d *streamDecoder) Format() Format {
+	return d.format
+}
+
+func (d *streamDecoder) Next() (json.RawMessage, error) {
+	if d.array && !d.started {
+		d.started = true
+		token, err := d.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return nil, fmt.Errorf("expected a JSON array, found %v", token)
+		}
+	}
+	if d.array && !d.decoder.More() {
+		return nil, io.EOF
+	}
+
+	d.reader.start = d.decoder.InputOffset()
+	var raw json.RawMessage
+	if err := d.decoder.Decode(&raw); err != nil {
+		var oversize *OversizeError
+		if stderrors.As(err, &oversize) {
+			return nil, fmt.Errorf("record %d: %w", d.record+1, err)
+		}
+		return nil, err
+	}
+	d.record++
+	return raw, nil
+}
+
+// csvDecoder turns the rows of a CSV or TSV feed into JSON objects
+// keyed by the column names of the header row.
+type csvDecoder struct {
+	reader  *csv.Reader
+	columns []string
+	record  int
+	format  Format
+}
+
+func newCSVDecoder(r io.Reader, comma rune, format Format) *csvDecoder {
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+	reader.FieldsPerRecord = -1
+	return &csvDecoder{reader: reader, format: format}
+}
+
+func (d *csvDecoder) Release() {}
+
+func (d *csvDecoder) Format() Format {
+	return d.format
+}
+
+func (d *csvDecoder) Next() (json.RawMessage, error) {
+	if d.columns == nil {
+		header, err := d.reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		d.columns = make([]string, len(header))
+		copy(d.columns, header)
+		if len(d.columns) > 0 {
+			d.columns[0] = string(bytes.TrimPrefix([]byte(d.columns[0]), utf8BOM))
+		}
+	}
+
+	row, err := d.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	d.record++
+	if err != nil {
+		return nil, &RecordError{Record: d.record, Err: err}
+	}
+	if len(row) != len(d.columns) {
+		err := fmt.Errorf("expected %d fields, found %d", len(d.columns), len(row))
+		return nil, &RecordError{Record: d.record, Err: err}
+	}
+
+	fields := make(map[string]string, len(row))
+	for i, value := range row {
+		fields[d.columns[i]] = value
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, &RecordError{Record: d.record, Err: err}
+	}
+	return raw, nil
+}
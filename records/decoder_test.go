@@ -0,0 +1,190 @@
+package records
+
+import (
+	stderrors "errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   Format
+	}{
+		{"products.jl", "anything", NDJSON},
+		{"products.jsonl.gz", "", NDJSON},
+		{"feed.csv", "", CSV},
+		{"feed.tsv.zst", "", TSV},
+		{"products.json", `[{"name":"a"}]`, JSONArray},
+		{"products.json", "{\"name\":\"a\"}\n{\"name\":\"b\"}\n", NDJSON},
+		{"products.json", "{\n  \"name\": \"a\"\n}\n", Concatenated},
+		{"products", "", NDJSON},
+		{"feed", "\xef\xbb\xbfsku,name,price\n1,Strat,849.99\n", CSV},
+		{"feed", "sku\tname\tprice\r\n1\tStrat\t849.99\r\n", TSV},
+		{"feed", `"sku","name"` + "\n", CSV},
+	}
+	for _, tt := range tests {
+		got, err := Detect(tt.name, []byte(tt.header))
+		if err != nil || got != tt.want {
+			t.Errorf("Detect(%q, %q) = %v, %v, want %v", tt.name, tt.header, got, err, tt.want)
+		}
+	}
+}
+
+func TestDetectRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"html error page", "<!DOCTYPE html>\n<html><body>503 Service Unavailable</body></html>"},
+		{"xml", `<?xml version="1.0"?><Error><Code>AccessDenied</Code></Error>`},
+		{"binary", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"},
+		{"single column", "hello\nworld\n"},
+		{"empty column", "sku,,price\n"},
+		{"control characters", "a\x01b,c\n"},
+	}
+	for _, tt := range tests {
+		if format, err := Detect("object", []byte(tt.header)); err == nil {
+			t.Errorf("%s: Detect() = %v, want an error", tt.name, format)
+		}
+	}
+}
+
+func TestNewDecoderRejectsUnknownContent(t *testing.T) {
+	_, err := NewDecoder(strings.NewReader("<html>Not Found</html>"), Auto, "products")
+	if err == nil {
+		t.Fatal("NewDecoder() error = nil, want an error")
+	}
+}
+
+func readAll(t *testing.T, decoder Decoder) ([]string, []error) {
+	t.Helper()
+	var records []string
+	var errs []error
+	for {
+		raw, err := decoder.Next()
+		if err == io.EOF {
+			return records, errs
+		}
+		if err != nil {
+			errs = append(errs, err)
+			var recordErr *RecordError
+			if !stderrors.As(err, &recordErr) {
+				return records, errs
+			}
+			continue
+		}
+		records = append(records, string(raw))
+	}
+}
+
+func TestStreamDecoderMaxRecordSize(t *testing.T) {
+	long := `{"name":"` + strings.Repeat("x", 200) + `"}`
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+	}{
+		{"array", JSONArray, `[{"a":1},` + long + `,{"b":2}]`},
+		{"concatenated", Concatenated, "{\"a\":1}\n" + long + "\n{\"b\":2}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder, err := NewDecoder(strings.NewReader(tt.input), tt.format, "products.json", WithMaxRecordSize(64))
+			if err != nil {
+				t.Fatal(err)
+			}
+			records, errs := readAll(t, decoder)
+			if len(records) != 1 || records[0] != `{"a":1}` {
+				t.Errorf("records = %v, want the first one only", records)
+			}
+			var oversize *OversizeError
+			if len(errs) != 1 || !stderrors.As(errs[0], &oversize) || oversize.Max != 64 {
+				t.Errorf("errors = %v, want an OversizeError", errs)
+			}
+		})
+	}
+}
+
+func TestStreamDecoderWithinMaxRecordSize(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("[")
+	for i := 0; i < 100; i++ {
+		if i > 0 {
+			input.WriteString(",")
+		}
+		input.WriteString(`{"name":"` + strings.Repeat("y", 40) + `"}`)
+	}
+	input.WriteString("]")
+	decoder, err := NewDecoder(strings.NewReader(input.String()), JSONArray, "products.json", WithMaxRecordSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, errs := readAll(t, decoder)
+	if len(records) != 100 || len(errs) != 0 {
+		t.Errorf("read %d records and %v, want 100 records", len(records), errs)
+	}
+}
+
+type deadLetters struct {
+	records []int
+	data    []string
+	reasons []error
+}
+
+func (d *deadLetters) DeadLetter(name string, record int, data []byte, reason error) error {
+	d.records = append(d.records, record)
+	d.data = append(d.data, string(data))
+	d.reasons = append(d.reasons, reason)
+	return nil
+}
+
+// An oversize NDJSON record longer than the read buffer is
+// dead-lettered with its start and the range of the complete record.
+func TestLineDecoderDeadLetter(t *testing.T) {
+	long := `{"name":"` + strings.Repeat("x", 100<<10) + `"}`
+	input := "{\"a\":1}\n\n" + long + "\n{\"b\":2}\n"
+	dead := &deadLetters{}
+	decoder, err := NewDecoder(strings.NewReader(input), NDJSON, "products.jl", WithMaxRecordSize(64), WithDeadLetter(dead))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, errs := readAll(t, decoder)
+	if len(records) != 2 || len(errs) != 1 {
+		t.Fatalf("read %v and %v, want 2 records and 1 error", records, errs)
+	}
+	if len(dead.records) != 1 || dead.records[0] != 2 || dead.data[0] != long[:64] {
+		t.Fatalf("dead letters %v %q, want the start of record 2", dead.records, dead.data)
+	}
+	var oversize *OversizeError
+	if !stderrors.As(dead.reasons[0], &oversize) {
+		t.Fatalf("reason = %v, want an OversizeError", dead.reasons[0])
+	}
+	if got := input[oversize.Offset : oversize.Offset+oversize.Size]; got != long {
+		t.Errorf("range %d+%d holds %.20q, want the complete record", oversize.Offset, oversize.Size, got)
+	}
+}
+
+// A line decoder abandoned before the end of the file returns its line
+// buffer on Release.
+func TestLineDecoderRelease(t *testing.T) {
+	long := `{"name":"` + strings.Repeat("x", 100<<10) + `"}`
+	decoder, err := NewDecoder(strings.NewReader(long+"\n{\"b\":2}\n"), NDJSON, "products.jl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := decoder.Next(); err != nil || string(raw) != long {
+		t.Fatalf("Next() = %.20s, %v, want the long record", raw, err)
+	}
+	lines := decoder.(*lineDecoder)
+	if lines.line == nil {
+		t.Fatal("no line buffer taken for a record longer than the read buffer")
+	}
+	decoder.Release()
+	if lines.line != nil {
+		t.Error("line buffer kept after Release()")
+	}
+	decoder.Release()
+}
@@ -0,0 +1,149 @@
+package records
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chuxorg/chux-parser/compression"
+)
+
+// Format is the layout of the records in a crawl file.
+type Format int
+
+const (
+	// Auto detects the Format from the file name and content.
+	Auto Format = iota
+	// NDJSON holds one JSON object per line (.jl, .jsonl).
+	NDJSON
+	// JSONArray holds a single top-level array of objects.
+	JSONArray
+	// Concatenated holds JSON objects back to back, which may span
+	// several lines each, e.g. pretty printed.
+	Concatenated
+	// CSV is a comma separated product feed with a header row.
+	CSV
+	// TSV is a tab separated product feed with a header row.
+	TSV
+)
+
+var formatNames = map[Format]string{
+	Auto:         "auto",
+	NDJSON:       "ndjson",
+	JSONArray:    "json-array",
+	Concatenated: "concatenated",
+	CSV:          "csv",
+	TSV:          "tsv",
+}
+
+func (f Format) String() string {
+	return formatNames[f]
+}
+
+// ParseFormat returns the Format with the given name, as used in
+// configuration. An empty name is Auto.
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return Auto, nil
+	}
+	for format, formatName := range formatNames {
+		if formatName == name {
+			return format, nil
+		}
+	}
+	return Auto, fmt.Errorf("unknown record format %q", name)
+}
+
+// extensions maps file name suffixes to the Format they imply.
+var extensions = map[string]Format{
+	".jl":     NDJSON,
+	".jsonl":  NDJSON,
+	".ndjson": NDJSON,
+	".csv":    CSV,
+	".tsv":    TSV,
+}
+
+// Detect determines the Format of a file from its name, ignoring a
+// compression suffix, and the first bytes of its content. A .json
+// file, or one without a known suffix, is told apart by its content:
+// a leading '[' is a JSON array, a first line holding a complete
+// object is NDJSON, an object spanning lines is concatenated JSON and
+// a header row of at least two column names is a CSV or TSV feed.
+// Anything else, such as an HTML error page or binary data, is an
+// error.
+func Detect(name string, header []byte) (Format, error) {
+	if format, ok := extensions[strings.ToLower(filepath.Ext(compression.TrimExt(name)))]; ok {
+		return format, nil
+	}
+
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(header, utf8BOM), " \t\r\n")
+	if len(trimmed) == 0 {
+		return NDJSON, nil
+	}
+	switch trimmed[0] {
+	case '[':
+		return JSONArray, nil
+	case '{':
+		end := bytes.IndexByte(trimmed, '\n')
+		if end < 0 {
+			// The first line does not fit the header, line based
+			// reading is the safe choice for very long records.
+			return NDJSON, nil
+		}
+		if json.Valid(trimmed[:end]) {
+			return NDJSON, nil
+		}
+		return Concatenated, nil
+	case '<':
+		return Auto, fmt.Errorf("%s looks like HTML or XML, not records", name)
+	}
+
+	firstLine := trimmed
+	if end := bytes.IndexByte(trimmed, '\n'); end >= 0 {
+		firstLine = trimmed[:end]
+	}
+	firstLine = bytes.TrimRight(firstLine, "\r")
+	format, comma := CSV, ","
+	if bytes.Count(firstLine, []byte{'\t'}) > bytes.Count(firstLine, []byte{','}) {
+		format, comma = TSV, "\t"
+	}
+	if !isHeader(string(firstLine), comma) {
+		return Auto, fmt.Errorf("%s holds neither JSON nor a %s header row", name, format)
+	}
+	return format, nil
+}
+
+// maxColumnName is the length of the longest column name isHeader
+// accepts.
+const maxColumnName = 128
+
+// isHeader reports whether line is a plausible header row: at least
+// two column names separated by comma, each short, printable text.
+func isHeader(line, comma string) bool {
+	if !utf8.ValidString(line) {
+		return false
+	}
+	columns := strings.Split(line, comma)
+	if len(columns) < 2 {
+		return false
+	}
+	for _, column := range columns {
+		column = strings.Trim(strings.TrimSpace(column), `"`)
+		if column == "" || len(column) > maxColumnName {
+			return false
+		}
+		for _, r := range column {
+			if !unicode.IsPrint(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
@@ -0,0 +1,140 @@
+package records
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxPooledSize is the capacity above which a line buffer is not
+// returned to the pool, so one huge record does not pin its buffer.
+const maxPooledSize = 1024 * 1024
+
+// linePool holds the buffers of lines longer than the read buffer,
+// shared by the lineDecoders of consecutive files.
+var linePool = sync.Pool{
+	New: func() interface{} {
+		return new([]byte)
+	},
+}
+
+// lineDecoder reads one JSON object per line. A malformed line is
+// reported and skipped, so is a line longer than the maximum record
+// size, which is also sent to the DeadLetter.
+type lineDecoder struct {
+	reader     *bufio.Reader
+	name       string
+	max        int
+	deadLetter DeadLetter
+	record     int
+	// offset is the number of bytes read before the current line.
+	offset int64
+	// line collects lines spanning several reads of the buffer. It is
+	// taken from the linePool when first needed.
+	line *[]byte
+}
+
+func newLineDecoder(r *bufio.Reader, name string, opts Options) *lineDecoder {
+	return &lineDecoder{reader: r, name: name, max: opts.MaxRecordSize, deadLetter: opts.DeadLetter}
+}
+
+func (d *lineDecoder) Format() Format {
+	return NDJSON
+}
+
+func (d *lineDecoder) Next() (json.RawMessage, error) {
+	for {
+		offset := d.offset
+		line, size, err := d.readLine()
+		if err != nil {
+			d.Release()
+			return nil, err
+		}
+		if size <= int64(d.max) && len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if d.record == 0 {
+			line = bytes.TrimPrefix(line, utf8BOM)
+		}
+		d.record++
+		if size > int64(d.max) {
+			return nil, d.oversize(line, offset, size)
+		}
+		if !json.Valid(line) {
+			return nil, &RecordError{Record: d.record, Err: fmt.Errorf("invalid JSON")}
+		}
+		raw := make(json.RawMessage, len(line))
+		copy(raw, line)
+		return raw, nil
+	}
+}
+
+// readLine returns the next line without its line feed and the size
+// of the complete line. Of a line longer than the maximum record size
+// only the first bytes are returned. The line is valid until the next
+// call.
+func (d *lineDecoder) readLine() ([]byte, int64, error) {
+	var size int64
+	collected := false
+	for {
+		chunk, err := d.reader.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			return nil, 0, err
+		}
+		if err == io.EOF && size == 0 && len(chunk) == 0 {
+			return nil, 0, io.EOF
+		}
+		d.offset += int64(len(chunk))
+		chunk = bytes.TrimSuffix(chunk, []byte{'\n'})
+		size += int64(len(chunk))
+
+		if err != bufio.ErrBufferFull && !collected {
+			// The whole line is in the read buffer, no copy is needed
+			return chunk, size, nil
+		}
+		if !collected {
+			collected = true
+			if d.line == nil {
+				d.line = linePool.Get().(*[]byte)
+			}
+			*d.line = (*d.line)[:0]
+		}
+		// Beyond the maximum only the size is counted
+		if keep := d.max - len(*d.line); keep > 0 {
+			if keep > len(chunk) {
+				keep = len(chunk)
+			}
+			*d.line = append(*d.line, chunk[:keep]...)
+		}
+		if err != bufio.ErrBufferFull {
+			return *d.line, size, nil
+		}
+	}
+}
+
+// oversize skips an oversize record starting at offset after sending
+// it to the DeadLetter.
+func (d *lineDecoder) oversize(line []byte, offset, size int64) error {
+	var err error = &OversizeError{Offset: offset, Size: size, Max: d.max}
+	if d.deadLetter != nil {
+		if dlErr := d.deadLetter.DeadLetter(d.name, d.record, line, err); dlErr != nil {
+			err = fmt.Errorf("%w, dead-lettering it failed: %v", err, dlErr)
+		}
+	}
+	return &RecordError{Record: d.record, Err: err}
+}
+
+// Release returns the line buffer to the linePool. Next releases it
+// too once the file has been read.
+func (d *lineDecoder) Release() {
+	if d.line == nil {
+		return
+	}
+	if cap(*d.line) <= maxPooledSize {
+		linePool.Put(d.line)
+	}
+	d.line = nil
+}
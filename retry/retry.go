@@ -0,0 +1,156 @@
+// Package retry retries operations on transient S3 and MongoDB
+// failures with jittered exponential backoff.
+package retry
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Policy decides how often and how long to wait before an operation
+// is attempted again.
+type Policy struct {
+	// MaxAttempts is the number of attempts including the first one,
+	// values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, it doubles with
+	// every further retry up to MaxDelay. The actual wait is a random
+	// duration up to the backoff.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Retryable classifies errors, IsRetryable when nil.
+	Retryable func(error) bool
+}
+
+// Default returns the Policy used when none is configured.
+func Default() Policy {
+	return Policy{
+		MaxAttempts: 5,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+// Never returns a Policy that makes a single attempt.
+func Never() Policy {
+	return Policy{MaxAttempts: 1}
+}
+
+// Do calls op until it succeeds, fails with an error that is not
+// retryable, the attempts are exhausted or ctx is done. op receives
+// the number of the attempt starting at 0. Do returns the number of
+// retries made and the last error.
+func (p Policy) Do(ctx context.Context, op func(attempt int) error) (int, error) {
+	for attempt := 0; ; attempt++ {
+		err := op(attempt)
+		if err == nil || !p.ShouldRetry(attempt, err) {
+			return attempt, err
+		}
+
+		timer := time.NewTimer(p.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		case <-timer.C:
+		}
+	}
+}
+
+// ShouldRetry reports whether attempt, which failed with err, is to be
+// followed by another one.
+func (p Policy) ShouldRetry(attempt int, err error) bool {
+	if attempt+1 >= p.MaxAttempts {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// Backoff returns a random wait before the retry following attempt.
+func (p Policy) Backoff(attempt int) time.Duration {
+	backoff := p.BaseDelay
+	for i := 0; i < attempt && backoff < p.MaxDelay; i++ {
+		backoff *= 2
+	}
+	if p.MaxDelay > 0 && backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff)) + 1)
+}
+
+// IsRetryable reports whether err is transient: throttling, server
+// side and timeout errors of AWS, network timeouts, refused and reset
+// connections, connections closed mid-stream, temporary DNS failures
+// and MongoDB errors labelled as retryable. Other network errors, such
+// as an unknown host, and errors of a cancelled context are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if stderrors.Is(err, context.Canceled) {
+		return false
+	}
+	if stderrors.Is(err, io.ErrUnexpectedEOF) ||
+		stderrors.Is(err, syscall.ECONNRESET) ||
+		stderrors.Is(err, syscall.ECONNREFUSED) ||
+		stderrors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var awsErr awserr.Error
+	if stderrors.As(err, &awsErr) {
+		var failure awserr.RequestFailure
+		if stderrors.As(err, &failure) {
+			if failure.StatusCode() == 429 || failure.StatusCode() >= 500 {
+				return true
+			}
+		}
+		switch awsErr.Code() {
+		case "SlowDown", "RequestTimeout", "InternalError", "ServiceUnavailable":
+			return true
+		}
+		if request.IsErrorRetryable(awsErr) || request.IsErrorThrottle(awsErr) {
+			return true
+		}
+		// The cause of a request that could not be sent, e.g. a reset
+		// connection, decides.
+		if awsErr.OrigErr() != nil && awsErr.OrigErr() != err {
+			return IsRetryable(awsErr.OrigErr())
+		}
+		return false
+	}
+
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+	var labeled mongo.LabeledError
+	if stderrors.As(err, &labeled) {
+		return labeled.HasErrorLabel("RetryableWriteError") ||
+			labeled.HasErrorLabel("TransientTransactionError")
+	}
+
+	var dnsErr *net.DNSError
+	if stderrors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if stderrors.As(err, &netErr) {
+		return netErr.Timeout()
+	}
+	return false
+}
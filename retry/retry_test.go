@@ -0,0 +1,175 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// timeoutError is a net.Error timing out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func opError(err error) error {
+	return &net.OpError{Op: "read", Net: "tcp", Err: err}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"other", errors.New("invalid record"), false},
+		{"EOF", io.EOF, false},
+		{"cut connection", io.ErrUnexpectedEOF, true},
+		{"wrapped cut connection", fmt.Errorf("reading part 3: %w", io.ErrUnexpectedEOF), true},
+		{"cancelled", context.Canceled, false},
+		{"wrapped cancelled", fmt.Errorf("getting object: %w", context.Canceled), false},
+		{"deadline", context.DeadlineExceeded, true},
+
+		{"reset connection", opError(os.NewSyscallError("read", syscall.ECONNRESET)), true},
+		{"refused connection", opError(os.NewSyscallError("connect", syscall.ECONNREFUSED)), true},
+		{"broken pipe", opError(os.NewSyscallError("write", syscall.EPIPE)), true},
+		{"network timeout", opError(timeoutError{}), true},
+		{"unreachable network", opError(os.NewSyscallError("connect", syscall.ENETUNREACH)), false},
+		{"unknown host", opError(&net.DNSError{Err: "no such host", Name: "s3.invalid", IsNotFound: true}), false},
+		{"DNS timeout", &net.DNSError{Err: "i/o timeout", Name: "s3.amazonaws.com", IsTimeout: true}, true},
+		{"temporary DNS failure", &net.DNSError{Err: "server misbehaving", Name: "s3.amazonaws.com", IsTemporary: true}, true},
+
+		{"AWS throttled", awserr.NewRequestFailure(awserr.New("SlowDown", "reduce your request rate", nil), 503, "1"), true},
+		{"AWS too many requests", awserr.NewRequestFailure(awserr.New("TooManyRequestsException", "rate exceeded", nil), 429, "2"), true},
+		{"AWS throttling", awserr.New("ThrottlingException", "rate exceeded", nil), true},
+		{"AWS internal error", awserr.NewRequestFailure(awserr.New("InternalError", "we encountered an internal error", nil), 500, "3"), true},
+		{"AWS bad gateway", awserr.NewRequestFailure(awserr.New("BadGateway", "", nil), 502, "4"), true},
+		{"AWS request timeout", awserr.NewRequestFailure(awserr.New("RequestTimeout", "idle connection", nil), 400, "5"), true},
+		{"AWS not found", awserr.NewRequestFailure(awserr.New("NoSuchKey", "the key does not exist", nil), 404, "6"), false},
+		{"AWS access denied", awserr.NewRequestFailure(awserr.New("AccessDenied", "access denied", nil), 403, "7"), false},
+		{"AWS bad request", awserr.NewRequestFailure(awserr.New("InvalidArgument", "invalid range", nil), 400, "8"), false},
+		{"AWS reset connection", awserr.New("RequestError", "send request failed", opError(os.NewSyscallError("read", syscall.ECONNRESET))), true},
+		{"AWS unknown host", awserr.New("RequestError", "send request failed", opError(&net.DNSError{Err: "no such host", Name: "s3.invalid", IsNotFound: true})), false},
+		{"AWS cancelled", awserr.New("RequestCanceled", "request context canceled", context.Canceled), false},
+
+		{"Mongo retryable write", mongo.CommandError{Code: 91, Labels: []string{"RetryableWriteError"}}, true},
+		{"Mongo transient transaction", mongo.CommandError{Code: 112, Labels: []string{"TransientTransactionError"}}, true},
+		{"Mongo labelled write exception", mongo.WriteException{Labels: []string{"RetryableWriteError"}}, true},
+		{"Mongo network error", mongo.CommandError{Labels: []string{"NetworkError"}}, true},
+		{"Mongo duplicate key", mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}, false},
+		{"Mongo unauthorized", mongo.CommandError{Code: 13, Name: "Unauthorized"}, false},
+		{"Mongo no documents", mongo.ErrNoDocuments, false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%s: %v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Policy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	limits := []time.Duration{
+		100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond,
+		time.Second, time.Second, time.Second,
+	}
+	for attempt, limit := range limits {
+		for i := 0; i < 1000; i++ {
+			if got := p.Backoff(attempt); got <= 0 || got > limit {
+				t.Fatalf("Backoff(%d) = %v, want in (0, %v]", attempt, got, limit)
+			}
+		}
+	}
+	if got := p.Backoff(1000); got <= 0 || got > time.Second {
+		t.Errorf("Backoff(1000) = %v, want in (0, 1s]", got)
+	}
+
+	// Without a MaxDelay the backoff keeps doubling.
+	unbounded := Policy{BaseDelay: time.Millisecond}
+	if got := unbounded.Backoff(3); got <= 0 || got > 8*time.Millisecond {
+		t.Errorf("Backoff(3) without MaxDelay = %v, want in (0, 8ms]", got)
+	}
+	if got := (Policy{}).Backoff(3); got != 0 {
+		t.Errorf("Backoff(3) without BaseDelay = %v, want 0", got)
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	transient := io.ErrUnexpectedEOF
+	tests := []struct {
+		name    string
+		policy  Policy
+		attempt int
+		err     error
+		want    bool
+	}{
+		{"first attempt", Default(), 0, transient, true},
+		{"last attempt", Default(), 4, transient, false},
+		{"not retryable", Default(), 0, errors.New("invalid record"), false},
+		{"never", Never(), 0, transient, false},
+		{"no policy", Policy{}, 0, transient, false},
+		{"own classification", Policy{MaxAttempts: 2, Retryable: func(error) bool { return true }}, 0, errors.New("invalid record"), true},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.ShouldRetry(tt.attempt, tt.err); got != tt.want {
+			t.Errorf("%s: ShouldRetry(%d, %v) = %v, want %v", tt.name, tt.attempt, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := Policy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+	failing := func(n int, err error) func(int) error {
+		return func(attempt int) error {
+			if attempt < n {
+				return err
+			}
+			return nil
+		}
+	}
+	tests := []struct {
+		name    string
+		op      func(int) error
+		retries int
+		failed  bool
+	}{
+		{"succeeds", failing(0, nil), 0, false},
+		{"succeeds on retry", failing(2, io.ErrUnexpectedEOF), 2, false},
+		{"attempts exhausted", failing(10, io.ErrUnexpectedEOF), 3, true},
+		{"not retryable", failing(10, errors.New("invalid record")), 0, true},
+	}
+	for _, tt := range tests {
+		retries, err := p.Do(context.Background(), tt.op)
+		if retries != tt.retries || (err != nil) != tt.failed {
+			t.Errorf("%s: Do() = %d, %v, want %d retries, failed %v", tt.name, retries, err, tt.retries, tt.failed)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	slow := Policy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	done := make(chan struct{})
+	go func() {
+		retries, err := slow.Do(ctx, failing(10, io.ErrUnexpectedEOF))
+		if retries != 0 || err != io.ErrUnexpectedEOF {
+			t.Errorf("Do() with a cancelled context = %d, %v, want 0, %v", retries, err, io.ErrUnexpectedEOF)
+		}
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Do() kept waiting after its context was cancelled")
+	}
+}
@@ -1,26 +1,34 @@
 package s3
 
 import (
-	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
-	"net/url"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/chuxorg/chux-parser/charset"
+	"github.com/chuxorg/chux-parser/compression"
 	"github.com/chuxorg/chux-parser/errors"
 	"github.com/chuxorg/chux-parser/logging"
+	"github.com/chuxorg/chux-parser/records"
+	"github.com/chuxorg/chux-parser/retry"
+	"github.com/chuxorg/chux-parser/urls"
 )
 
 const basePath = "data/"
 
+// DefaultPartSize is the size of the parts of objects downloaded over
+// parallel connections.
+const DefaultPartSize = 16 << 20
+
 var logger *logging.Logger
 
 // Define a struct to hold the JSON object's URL field
@@ -37,27 +45,181 @@ type IBucket interface {
 
 type Bucket struct {
 	Name         string
+	Region       string
 	Profile      string
 	DownloadPath string
-	Session      *session.Session
-	Logger       *logging.Logger
+	// Format of the records in the Bucket's objects, records.Auto
+	// detects it per object.
+	Format records.Format
+	// Charset is the charset of objects whose Content-Type names none
+	// and that are not UTF-8, see charset.Detect.
+	Charset string
+	// Endpoint replaces the AWS S3 endpoint, e.g. with the url of a
+	// MinIO server or of an s3test.Server. PathStyle and DisableSSL
+	// are usually needed with it.
+	Endpoint   string
+	PathStyle  bool
+	DisableSSL bool
+	// Objects larger than PartSize are downloaded with Concurrency
+	// parallel range requests, a Concurrency of 1 disables it.
+	PartSize    int64
+	Concurrency int
+	// QuarantinePrefix, when set, enables quarantining malformed
+	// objects below it, see Quarantine. QuarantineMove removes them
+	// from their original key.
+	QuarantinePrefix string
+	QuarantineMove   bool
+	// DeadLetterPrefix, when set, enables DeadLetter to store the
+	// records skipped for being too large below it.
+	DeadLetterPrefix string
+	// MaxRecordSize caps the size of the first record read to find the
+	// company of an object, see records.WithMaxRecordSize.
+	MaxRecordSize int
+	// RunID is recorded in the quarantine reports and dead letters.
+	RunID string
+	// Filter selects the objects Download downloads by their Status.
+	Filter StatusFilter
+	// Verify checks the size and checksum of downloaded objects. Objects
+	// that fail are not returned by Download and DownloadObject, see
+	// OpenObject for streamed objects.
+	Verify bool
+	// Retry is applied to listing and reading objects.
+	Retry   retry.Policy
+	Session *session.Session
+	Logger  *logging.Logger
 }
 
 func New(options ...func(*Bucket)) *Bucket {
 
-	bucket := &Bucket{}
+	bucket := &Bucket{
+		Region:      "us-east-1",
+		PartSize:    DefaultPartSize,
+		Concurrency: 1,
+		Verify:      true,
+		Retry:       retry.Default(),
+	}
 	for _, option := range options {
 		option(bucket)
 	}
-	bucket.Logger.Debug("Creating new Bucket struct")
-	bucketName := os.Getenv("AWS_SOURCE_BUCKET")
-	bucket.Name = bucketName
-	bucket.Profile = "csailer"
-	bucket.DownloadPath = os.Getenv("AWS_DOWNLOAD_PATH")
-	bucket.Logger.Debug("Bucket struct created with the following settings\nName: %s\nProfile: %s\nDownloadPath: %s", bucket.Name, bucket.Profile, bucket.DownloadPath)
+	bucket.Logger.Debug("Bucket struct created with the following settings\nName: %s\nRegion: %s\nProfile: %s\nEndpoint: %s\nDownloadPath: %s", bucket.Name, bucket.Region, bucket.Profile, bucket.Endpoint, bucket.DownloadPath)
 	return bucket
 }
 
+func WithName(name string) func(*Bucket) {
+	return func(b *Bucket) {
+		b.Name = name
+	}
+}
+
+func WithRegion(region string) func(*Bucket) {
+	return func(b *Bucket) {
+		b.Region = region
+	}
+}
+
+func WithProfile(profile string) func(*Bucket) {
+	return func(b *Bucket) {
+		b.Profile = profile
+	}
+}
+
+func WithDownloadPath(path string) func(*Bucket) {
+	return func(b *Bucket) {
+		b.DownloadPath = path
+	}
+}
+
+func WithEndpoint(endpoint string) func(*Bucket) {
+	return func(b *Bucket) {
+		b.Endpoint = endpoint
+	}
+}
+
+func WithPathStyle(pathStyle bool) func(*Bucket) {
+	return func(b *Bucket) {
+		b.PathStyle = pathStyle
+	}
+}
+
+func WithDisableSSL(disableSSL bool) func(*Bucket) {
+	return func(b *Bucket) {
+		b.DisableSSL = disableSSL
+	}
+}
+
+func WithPartSize(size int64) func(*Bucket) {
+	return func(b *Bucket) {
+		b.PartSize = size
+	}
+}
+
+func WithConcurrency(concurrency int) func(*Bucket) {
+	return func(b *Bucket) {
+		b.Concurrency = concurrency
+	}
+}
+
+func WithFilter(filter StatusFilter) func(*Bucket) {
+	return func(b *Bucket) {
+		b.Filter = filter
+	}
+}
+
+func WithQuarantine(prefix string, move bool) func(*Bucket) {
+	return func(b *Bucket) {
+		b.QuarantinePrefix = prefix
+		b.QuarantineMove = move
+	}
+}
+
+func WithDeadLetter(prefix string) func(*Bucket) {
+	return func(b *Bucket) {
+		b.DeadLetterPrefix = prefix
+	}
+}
+
+func WithMaxRecordSize(size int) func(*Bucket) {
+	return func(b *Bucket) {
+		b.MaxRecordSize = size
+	}
+}
+
+func WithRunID(runID string) func(*Bucket) {
+	return func(b *Bucket) {
+		b.RunID = runID
+	}
+}
+
+func WithCharset(charset string) func(*Bucket) {
+	return func(b *Bucket) {
+		b.Charset = charset
+	}
+}
+
+func WithVerify(verify bool) func(*Bucket) {
+	return func(b *Bucket) {
+		b.Verify = verify
+	}
+}
+
+func WithRetry(policy retry.Policy) func(*Bucket) {
+	return func(b *Bucket) {
+		b.Retry = policy
+	}
+}
+
+func WithSession(sess *session.Session) func(*Bucket) {
+	return func(b *Bucket) {
+		b.Session = sess
+	}
+}
+
+func WithFormat(format records.Format) func(*Bucket) {
+	return func(b *Bucket) {
+		b.Format = format
+	}
+}
+
 func WithLogger(l *logging.Logger) func(*Bucket) {
 	return func(b *Bucket) {
 		b.Logger = l
@@ -67,111 +229,380 @@ func WithLogger(l *logging.Logger) func(*Bucket) {
 func (b *Bucket) Download() ([]File, error) {
 	logging := b.Logger
 	logging.Debug("Bucket.Download() called")
-	s3Bucket := os.Getenv("AWS_SOURCE_BUCKET")
-	region := "us-east-1"
-	logging.Info("Downloading files from S3 bucket %s", s3Bucket)
-	// Create a new AWS session with the specified region
-	sess := session.Must(session.NewSession(&aws.Config{
-		Region: aws.String(region),
-	}))
-
-	// Create a new S3 service instance using the session
-	svc := s3.New(sess)
+	logging.Info("Downloading files from S3 bucket %s", b.Name)
+	svc, err := b.service()
+	if err != nil {
+		return nil, err
+	}
 
 	// List objects in the S3 bucket
-	resp, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(s3Bucket)})
+	objects, err := b.List("", b.Filter)
 	if err != nil {
-		logging.Error("Bucket.Download() Error listing objects:", err)
-		return nil, errors.NewChuxParserError("Bucket.Download() Error listing objects:", err)
+		return nil, err
 	}
 	var files []File
 
-	for _, item := range resp.Contents {
-		// Download the object from S3
-		fileReader, err := svc.GetObject(&s3.GetObjectInput{
-			Bucket: aws.String(s3Bucket),
-			Key:    item.Key,
-		})
-		if err != nil {
-			msg := fmt.Sprintf("Bucket.Download() Error getting object %v. Continuing", err)
-			logging.Warning(msg, err)
-			continue
-		}
-
-		lineReader := bufio.NewReader(fileReader.Body)
-		lineStr, err := lineReader.ReadString('\n')
-		if err != nil && err != io.EOF {
-			msg := fmt.Sprintf("Bucket.Download() Error reading line:%v. Continuing", err)
-			logging.Warning(msg, err)
+	// Quarantined objects and their reports are not downloaded
+	quarantined := b.quarantinedKeys(objects)
+	for _, object := range objects {
+		if b.isQuarantineKey(object.Key) || quarantined[object.Key] || b.isDeadLetterKey(object.Key) {
 			continue
 		}
-
-		// Unmarshal the JSON object into a Line struct
-		var lineObj Line
-		err = json.Unmarshal([]byte(lineStr), &lineObj)
+		file, err := b.fetchFile(svc, object.Key)
 		if err != nil {
-			msg := fmt.Sprintf("Bucket.Download() Error unmarshalling JSON object:%v. Continuing", err)
-			logging.Warning(msg, err)
+			logging.Warning("%s. Continuing", err.Error())
 			continue
 		}
+		if file != nil {
+			files = append(files, *file)
+		}
+	}
+	logging.Info("Bucket.Download() Files Ready to Process: %d", len(files))
+	return files, nil
+}
 
-		// Extract the FQDN from the URL
-		companyName, err := b.extractCompanyName(lineObj.URL)
+// DownloadObject downloads a single object from the Bucket. It is
+// used when the key is already known, for example from an S3 event
+// notification. A nil File without an error is returned when the
+// object belongs to a company that is not parsed.
+func (b *Bucket) DownloadObject(key string) (*File, error) {
+	b.Logger.Debug("Bucket.DownloadObject() called for %s", key)
+	svc, err := b.service()
+	if err != nil {
+		return nil, err
+	}
+	return b.fetchFile(svc, key)
+}
+
+// service returns an S3 client for the Bucket's session and Endpoint.
+// Without a session from WithSession, one is created on first use from
+// the Bucket's Region and Profile with the SDK's default credentials,
+// use awsauth.New for the other credential sources.
+func (b *Bucket) service() (*s3.S3, error) {
+	if b.Session == nil {
+		sess, err := session.NewSessionWithOptions(session.Options{
+			Config:            aws.Config{Region: aws.String(b.Region)},
+			Profile:           b.Profile,
+			SharedConfigState: session.SharedConfigEnable,
+		})
 		if err != nil {
-			msg := fmt.Sprintf("Bucket.Download() Error extracting company name:%v. Continuing", err)
-			log.Println(msg, err)
-			continue
+			return nil, errors.NewChuxParserError(fmt.Sprintf("Bucket.service() Error creating the AWS session: %v", err), err)
 		}
+		b.Session = sess
+	}
+
+	// Failed requests are retried by the Bucket's Retry policy, which
+	// counts them, instead of the SDK.
+	cfg := aws.NewConfig().
+		WithMaxRetries(0).
+		WithS3ForcePathStyle(b.PathStyle).
+		WithDisableSSL(b.DisableSSL)
+	if b.Endpoint != "" {
+		cfg = cfg.WithEndpoint(b.Endpoint)
+	}
+	svc := s3.New(b.Session, cfg)
+	// Objects stored with a Content-Encoding are fetched as stored, so
+	// they are verified against the size and checksum S3 holds before
+	// the compression package decodes them. Otherwise Go's HTTP
+	// transport would decode gzip on the fly.
+	svc.Handlers.Build.PushBack(func(r *request.Request) {
+		r.HTTPRequest.Header.Set("Accept-Encoding", "identity")
+	})
+	return svc, nil
+}
 
-		// Read the entire content of the file
-		contentBytes, err := ioutil.ReadAll(fileReader.Body)
+// OpenObject opens a single object of the Bucket for streaming. The
+// returned File carries the object's metadata but no Content, which
+// is read from the returned ReadCloser instead. The caller must close
+// it. With Verify the object is only verified by the Verify method of
+// the reader once it has been read, after the caller has used what it
+// read. A nil File and reader without an error are returned when the
+// object belongs to a company that is not parsed.
+func (b *Bucket) OpenObject(key string) (*File, io.ReadCloser, error) {
+	b.Logger.Debug("Bucket.OpenObject() called for %s", key)
+	svc, err := b.service()
+	if err != nil {
+		return nil, nil, err
+	}
+	return b.openObject(svc, key)
+}
+
+// fetchFile downloads the object stored under key and returns it as
+// a File with its Content read into memory.
+func (b *Bucket) fetchFile(svc *s3.S3, key string) (*File, error) {
+	file, body, err := b.openObject(svc, key)
+	if err != nil || file == nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// Read the entire content of the file
+	contentBytes, err := ioutil.ReadAll(body)
+	if err != nil {
+		msg := fmt.Sprintf("Bucket.fetchFile() Error reading file content of %s: %v", key, err)
+		return nil, errors.NewChuxParserError(msg, err)
+	}
+	// An object that fails verification is not returned, so it is
+	// never parsed.
+	checksum, err := body.(readCloser).Verify()
+	if err != nil {
+		return nil, err
+	}
+	file.Content = string(contentBytes)
+	file.Checksum = checksum.String()
+	file.Verified = checksum.Verified
+	file.Retries = body.(readCloser).Retries()
+	file.Invalid = body.(readCloser).Invalid()
+	if file.Invalid > 0 {
+		b.Logger.Warning("Bucket.fetchFile() Replaced %d invalid %s sequences in %s", file.Invalid, file.Charset, key)
+	}
+	return file, nil
+}
+
+// openObject requests the object stored under key and determines its
+// company and record Format.
+func (b *Bucket) openObject(svc *s3.S3, key string) (*File, io.ReadCloser, error) {
+	// Download the object from S3, a failed read resumes where it
+	// stopped
+	object, meta, err := b.openBody(svc, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b.Verify {
+		object = newVerifier(object, key, meta)
+	}
+
+	// Compressed objects are decompressed transparently
+	content, format, err := compression.NewReader(object, key, meta.ContentEncoding)
+	if err != nil {
+		object.Close()
+		msg := fmt.Sprintf("Bucket.openObject() Error decompressing %s: %v", key, err)
+		return b.malformed(key, malformedError(key, msg, err))
+	}
+	if format != compression.None {
+		b.Logger.Debug("Bucket.openObject() Decompressing %s as %s", key, format)
+	}
+	closer := closers{content, object}
+
+	// Records are decoded as UTF-8, other encodings are transcoded
+	hint := charset.FromContentType(meta.ContentType)
+	if hint == "" {
+		hint = b.Charset
+	}
+	text, err := charset.NewReader(content, key, hint)
+	if err != nil {
+		closer.Close()
+		msg := fmt.Sprintf("Bucket.openObject() Error detecting the charset of %s: %v", key, err)
+		return b.malformed(key, malformedError(key, msg, err))
+	}
+	if text.Encoding() != charset.UTF8 {
+		b.Logger.Debug("Bucket.openObject() Transcoding %s from %s", key, text.Encoding())
+	}
+
+	// The company is taken from the url of the first record. What the
+	// decoder consumed to find it is replayed in front of the returned
+	// reader so no record is lost.
+	var consumed bytes.Buffer
+	var decoderOptions []func(*records.Options)
+	if b.MaxRecordSize > 0 {
+		decoderOptions = append(decoderOptions, records.WithMaxRecordSize(b.MaxRecordSize))
+	}
+	decoder, err := records.NewDecoder(io.TeeReader(text, &consumed), b.Format, key, decoderOptions...)
+	if err != nil {
+		closer.Close()
+		msg := fmt.Sprintf("Bucket.openObject() Error detecting the record format of %s: %v", key, err)
+		return b.malformed(key, malformedError(key, msg, err))
+	}
+	// Only the first record is read, the decoder is not used to the end.
+	defer decoder.Release()
+	first, err := decoder.Next()
+	if err != nil {
+		closer.Close()
+		msg := fmt.Sprintf("Bucket.openObject() Error reading first record of %s: %v", key, err)
+		return b.malformed(key, malformedError(key, msg, err))
+	}
+
+	// Unmarshal the JSON object into a Line struct
+	var lineObj Line
+	err = json.Unmarshal(first, &lineObj)
+	if err != nil {
+		closer.Close()
+		msg := fmt.Sprintf("Bucket.openObject() Error unmarshalling JSON object of %s: %v", key, err)
+		return b.malformed(key, malformedError(key, msg, err))
+	}
+
+	// Extract the FQDN from the URL
+	companyName, err := b.extractCompanyName(lineObj.URL)
+	if err != nil {
+		closer.Close()
+		msg := fmt.Sprintf("Bucket.openObject() Error extracting company name of %s: %v", key, err)
+		return b.malformed(key, malformedError(key, msg, err))
+	}
+
+	if strings.Contains(strings.ToLower(companyName), "ebay") || companyName == "" {
+		closer.Close()
+		b.Logger.Debug("Bucket.openObject() Skipping %s for company '%s'", key, companyName)
+		return nil, nil, nil
+	}
+
+	file := &File{
+		Company:      companyName,
+		LastModified: meta.LastModified,
+		Size:         meta.Size,
+		IsProduct:    b.isProduct(companyName),
+		IsParsed:     false,
+		Path:         key,
+		Format:       decoder.Format().String(),
+		Charset:      text.Encoding(),
+		Retries:      object.Retries(),
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+	}
+	body := readCloser{
+		Reader: io.MultiReader(&consumed, text),
+		Closer: closer,
+		object: object,
+		text:   text,
+	}
+	return file, body, nil
+}
+
+// objectBody is the body of an object, read over one or several
+// connections.
+type objectBody interface {
+	io.ReadCloser
+	Retries() int
+}
+
+// objectMeta holds the metadata of an object needed for its File and
+// its verification.
+type objectMeta struct {
+	Size                 int64
+	ETag                 string
+	ContentEncoding      string
+	ContentType          string
+	LastModified         time.Time
+	ChecksumSHA256       string
+	ChecksumCRC32C       string
+	ChecksumSHA1         string
+	ChecksumCRC32        string
+	ServerSideEncryption string
+	SSECustomerAlgorithm string
+}
+
+// openBody requests the body of the object stored under key. With a
+// Concurrency above 1, objects larger than PartSize are downloaded in
+// parts over parallel connections.
+func (b *Bucket) openBody(svc *s3.S3, key string) (objectBody, objectMeta, error) {
+	object := &objectReader{
+		svc:    svc,
+		bucket: b.Name,
+		key:    key,
+		policy: b.Retry,
+		logger: b.Logger,
+	}
+
+	if b.Concurrency > 1 && b.PartSize > 0 {
+		var head *s3.HeadObjectOutput
+		retries, err := b.Retry.Do(context.Background(), func(attempt int) error {
+			var err error
+			head, err = svc.HeadObject(&s3.HeadObjectInput{
+				Bucket:       aws.String(b.Name),
+				Key:          aws.String(key),
+				ChecksumMode: aws.String(s3.ChecksumModeEnabled),
+			})
+			return err
+		})
 		if err != nil {
-			msg := fmt.Sprintf("Bucket.Download() Error reading file content:%v. Continuing", err)
-			log.Println(msg, err)
-			continue
+			msg := fmt.Sprintf("Bucket.openBody() Error getting metadata of %s: %v", key, err)
+			return nil, objectMeta{}, errors.NewChuxParserError(msg, err)
 		}
-
-		if !strings.Contains(strings.ToLower(companyName), "ebay") && companyName != "" {
-
-			file := File{
-				Company:      companyName,
-				Content:      string(contentBytes),
-				LastModified: *item.LastModified,
-				Size:         *item.Size,
-				IsProduct:    b.isProduct(companyName),
-				IsParsed:     false,
-				Path:         *item.Key,
-				DateCreated:  time.Now(),
-				DateModified: time.Now(),
-			}
-
-			files = append(files, file)
+		meta := objectMeta{
+			Size:                 aws.Int64Value(head.ContentLength),
+			ETag:                 aws.StringValue(head.ETag),
+			ContentEncoding:      aws.StringValue(head.ContentEncoding),
+			ContentType:          aws.StringValue(head.ContentType),
+			LastModified:         aws.TimeValue(head.LastModified),
+			ChecksumSHA256:       aws.StringValue(head.ChecksumSHA256),
+			ChecksumCRC32C:       aws.StringValue(head.ChecksumCRC32C),
+			ChecksumSHA1:         aws.StringValue(head.ChecksumSHA1),
+			ChecksumCRC32:        aws.StringValue(head.ChecksumCRC32),
+			ServerSideEncryption: aws.StringValue(head.ServerSideEncryption),
+			SSECustomerAlgorithm: aws.StringValue(head.SSECustomerAlgorithm),
+		}
+		if meta.Size > b.PartSize {
+			parts := newPartReader(svc, b.Name, key, meta.ETag, meta.Size, b.PartSize, b.Concurrency, retries, b.Retry, b.Logger)
+			return parts, meta, nil
 		}
+		object.retries = retries
 	}
-	logging.Info("Bucket.Download() Files Ready to Process: ", len(files))
-	return files, nil
-}
 
-// The extractCompanyName function takes a raw URL string as input, parses it, and extracts the hostname.
-// It then removes the domain extension and any subdomains (e.g., "www").
-// The resulting company name is returned.
-func (b *Bucket) extractCompanyName(rawURL string) (string, error) {
-	parsedURL, err := url.Parse(rawURL)
+	output, err := object.open()
 	if err != nil {
-		return "", err
+		return nil, objectMeta{}, err
 	}
+	return object, objectMeta{
+		Size:                 aws.Int64Value(output.ContentLength),
+		ETag:                 aws.StringValue(output.ETag),
+		ContentEncoding:      aws.StringValue(output.ContentEncoding),
+		ContentType:          aws.StringValue(output.ContentType),
+		LastModified:         aws.TimeValue(output.LastModified),
+		ChecksumSHA256:       aws.StringValue(output.ChecksumSHA256),
+		ChecksumCRC32C:       aws.StringValue(output.ChecksumCRC32C),
+		ChecksumSHA1:         aws.StringValue(output.ChecksumSHA1),
+		ChecksumCRC32:        aws.StringValue(output.ChecksumCRC32),
+		ServerSideEncryption: aws.StringValue(output.ServerSideEncryption),
+		SSECustomerAlgorithm: aws.StringValue(output.SSECustomerAlgorithm),
+	}, nil
+}
 
-	hostname := parsedURL.Hostname()
-	parts := strings.Split(hostname, ".")
+// readCloser combines a Reader with the Closer of the stream it reads.
+type readCloser struct {
+	io.Reader
+	io.Closer
+	object objectBody
+	text   *charset.Reader
+}
 
-	var domain string
-	if len(parts) >= 2 {
-		domain = parts[len(parts)-2]
-	} else {
-		domain = hostname
+// Retries returns the number of requests for the object that were
+// repeated, including those made while reading it.
+func (r readCloser) Retries() int {
+	return r.object.Retries()
+}
+
+// Invalid returns the number of invalid sequences replaced while
+// transcoding the object to UTF-8.
+func (r readCloser) Invalid() int {
+	return r.text.Invalid()
+}
+
+// Verify verifies the object once it has been read and returns its
+// Checksum. Without verification the Checksum is empty.
+func (r readCloser) Verify() (Checksum, error) {
+	if v, ok := r.object.(*verifier); ok {
+		return v.Verify()
+	}
+	return Checksum{}, nil
+}
+
+// closers closes a chain of streams, outermost first.
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var first error
+	for _, closer := range c {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
 	}
+	return first
+}
 
-	return domain, nil
+// The extractCompanyName function takes a raw URL string as input and returns the
+// company it belongs to, the label of its hostname left of the public suffix, so
+// www.thomann.de and www.thomann.co.uk are both thomann. See urls.Company.
+func (b *Bucket) extractCompanyName(rawURL string) (string, error) {
+	return urls.Company(rawURL)
 }
 
 // The isProduct function takes a slice of strings and a target string as input.
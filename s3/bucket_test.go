@@ -0,0 +1,61 @@
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/chuxorg/chux-parser/retry"
+	"github.com/chuxorg/chux-parser/s3/s3test"
+)
+
+const testBucket = "chux-crawler"
+
+// newTestBucket returns a Bucket of the testBucket of server, retrying
+// without waiting.
+func newTestBucket(t *testing.T, server *s3test.Server, options ...func(*Bucket)) *Bucket {
+	t.Helper()
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("test", "test", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := []func(*Bucket){
+		WithName(testBucket),
+		WithSession(sess),
+		WithEndpoint(server.URL),
+		WithPathStyle(true),
+		WithDisableSSL(true),
+		WithRetry(retry.Policy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+	}
+	return New(append(defaults, options...)...)
+}
+
+// crawl returns n product records of sweetwater in NDJSON.
+func crawl(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, `{"url":"https://www.sweetwater.com/store/detail/Strat%[1]d","name":"Fender Player Stratocaster %[1]d","sku":"Strat%[1]d"}`+"\n", i)
+	}
+	return sb.String()
+}
+
+func gzipped(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,55 @@
+package s3
+
+import (
+	stderrors "errors"
+	"strconv"
+	"strings"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/records"
+)
+
+// deadLetterSuffix is appended to the key of the object a dead letter
+// was read from, followed by the number of the record.
+const deadLetterSuffix = ".record-"
+
+// DeadLetter stores a record that was skipped for being too large
+// below the DeadLetterPrefix, keyed by the object name it was read
+// from and its position. data may only hold the start of the record,
+// the reason is kept in the object's metadata. With an OversizeError
+// of the records package as the reason, so is the byte range of the
+// complete record in the content of the source object as read by
+// OpenObject, decompressed and transcoded to UTF-8: the record starts
+// chux-offset bytes into it and is chux-size bytes long. It implements
+// records.DeadLetter.
+func (b *Bucket) DeadLetter(name string, record int, data []byte, reason error) error {
+	if b.DeadLetterPrefix == "" {
+		return errors.NewChuxParserError("Bucket.DeadLetter() no dead letter prefix configured", nil)
+	}
+	key := b.DeadLetterPrefix + name + deadLetterSuffix + strconv.Itoa(record)
+	b.Logger.Warning("Bucket.DeadLetter() Storing record %d of %s as %s: %v", record, name, key, reason)
+
+	metadata := map[string]string{
+		"chux-source": name,
+		"chux-record": strconv.Itoa(record),
+		"chux-reason": reason.Error(),
+	}
+	var oversize *records.OversizeError
+	if stderrors.As(reason, &oversize) {
+		metadata["chux-offset"] = strconv.FormatInt(oversize.Offset, 10)
+		metadata["chux-size"] = strconv.FormatInt(oversize.Size, 10)
+	}
+	if b.RunID != "" {
+		metadata["chux-run-id"] = b.RunID
+	}
+	svc, err := b.service()
+	if err != nil {
+		return err
+	}
+	return b.putObject(svc, key, data, "application/x-ndjson", metadata)
+}
+
+// isDeadLetterKey reports whether key is below the DeadLetterPrefix.
+func (b *Bucket) isDeadLetterKey(key string) bool {
+	return b.DeadLetterPrefix != "" && strings.HasPrefix(key, b.DeadLetterPrefix)
+}
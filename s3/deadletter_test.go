@@ -0,0 +1,67 @@
+package s3
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/chuxorg/chux-parser/records"
+	"github.com/chuxorg/chux-parser/s3/s3test"
+)
+
+// The complete oversize record can be read again from its source with
+// the range stored along with its dead letter.
+func TestDeadLetterRange(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	long := `{"url":"https://www.sweetwater.com/store/detail/Long","description":"` + strings.Repeat("x", 100<<10) + `"}`
+	content := crawl(2) + long + "\n" + crawl(1)
+	server.Put(testBucket, s3test.Object{Key: "sweetwater/products.jl.gz", Content: gzipped(t, content)})
+	bucket := newTestBucket(t, server, WithDeadLetter("dead-letter/"), WithRunID("run-1"))
+
+	_, body, err := bucket.OpenObject("sweetwater/products.jl.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	decoder, err := records.NewDecoder(body, records.NDJSON, "sweetwater/products.jl.gz", records.WithMaxRecordSize(1024), records.WithDeadLetter(bucket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Release()
+	for {
+		if _, err := decoder.Next(); err == io.EOF {
+			break
+		}
+	}
+
+	dead, ok := server.Object(testBucket, "dead-letter/sweetwater/products.jl.gz.record-3")
+	if !ok {
+		t.Fatal("record 3 was not dead-lettered")
+	}
+	if string(dead.Content) != long[:1024] || dead.Metadata["chux-run-id"] != "run-1" {
+		t.Errorf("dead letter holds %.20q... with metadata %v, want the start of record 3 of run-1", dead.Content, dead.Metadata)
+	}
+	offset, _ := strconv.Atoi(dead.Metadata["chux-offset"])
+	size, _ := strconv.Atoi(dead.Metadata["chux-size"])
+	if source := dead.Metadata["chux-source"]; source != "sweetwater/products.jl.gz" {
+		t.Fatalf("chux-source = %q, want the source key", source)
+	}
+
+	_, body, err = bucket.OpenObject(dead.Metadata["chux-source"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if _, err := io.CopyN(io.Discard, body, int64(offset)); err != nil {
+		t.Fatal(err)
+	}
+	record := make([]byte, size)
+	if _, err := io.ReadFull(body, record); err != nil {
+		t.Fatal(err)
+	}
+	if string(record) != long {
+		t.Errorf("range %d+%d holds %.40q, want the complete record", offset, size, record)
+	}
+}
@@ -0,0 +1,136 @@
+package s3
+
+import (
+	"io"
+	"testing"
+
+	"github.com/chuxorg/chux-parser/s3/s3test"
+)
+
+// downloadCase is an object stored on a s3test.Server along with the
+// Bucket options it is downloaded with and the faults injected.
+type downloadCase struct {
+	name    string
+	object  s3test.Object
+	options []func(*Bucket)
+	// fail requests fail with 503, cut reads are cut after cutAfter
+	// bytes.
+	fail     int
+	cut      int
+	cutAfter int
+	// retries is the least number of retries expected.
+	retries int
+}
+
+func downloadCases(t *testing.T) []downloadCase {
+	content := crawl(500)
+	multipart := []func(*Bucket){WithConcurrency(4), WithPartSize(4 << 10)}
+	return []downloadCase{
+		{name: "plain", object: s3test.Object{Key: "sweetwater/products.jl", Content: []byte(content)}},
+		{name: "gz", object: s3test.Object{Key: "sweetwater/products.jl.gz", Content: gzipped(t, content)}},
+		{name: "content encoding gzip", object: s3test.Object{Key: "sweetwater/products.jl", Content: gzipped(t, content), ContentEncoding: "gzip"}},
+		{name: "multipart", object: s3test.Object{Key: "sweetwater/products.jl", Content: []byte(content), ChecksumAlgorithm: "CRC32C"}, options: multipart},
+		{name: "multipart gz", object: s3test.Object{Key: "sweetwater/products.jl.gz", Content: gzipped(t, content)}, options: multipart},
+		{name: "cut read", object: s3test.Object{Key: "sweetwater/products.jl", Content: []byte(content)}, cut: 2, cutAfter: 1000, retries: 2},
+		{name: "cut multipart", object: s3test.Object{Key: "sweetwater/products.jl", Content: []byte(content)}, options: multipart, cut: 3, cutAfter: 100, retries: 3},
+		{name: "503", object: s3test.Object{Key: "sweetwater/products.jl", Content: []byte(content), ChecksumAlgorithm: "SHA256"}, fail: 2, retries: 2},
+		{name: "503 multipart", object: s3test.Object{Key: "sweetwater/products.jl", Content: []byte(content)}, options: multipart, fail: 2, retries: 2},
+	}
+}
+
+// start stores the object of tt on a new Server and injects its
+// faults.
+func (tt downloadCase) start(t *testing.T) (*s3test.Server, *Bucket) {
+	t.Helper()
+	server := s3test.NewServer()
+	t.Cleanup(server.Close)
+	server.Put(testBucket, tt.object)
+	bucket := newTestBucket(t, server, append([]func(*Bucket){WithVerify(true)}, tt.options...)...)
+	server.FailRequests(tt.fail)
+	server.CutReads(tt.cut, tt.cutAfter)
+	return server, bucket
+}
+
+func TestDownloadObject(t *testing.T) {
+	content := crawl(500)
+	for _, tt := range downloadCases(t) {
+		t.Run(tt.name, func(t *testing.T) {
+			_, bucket := tt.start(t)
+			file, err := bucket.DownloadObject(tt.object.Key)
+			if err != nil {
+				t.Fatalf("DownloadObject() error = %v", err)
+			}
+			if file == nil {
+				t.Fatal("DownloadObject() returned no File")
+			}
+			if file.Content != content {
+				t.Errorf("Content has %d bytes, want the %d bytes stored", len(file.Content), len(content))
+			}
+			if file.Company != "sweetwater" || !file.IsProduct || file.Format != "ndjson" {
+				t.Errorf("File = %s %v %s, want sweetwater products in ndjson", file.Company, file.IsProduct, file.Format)
+			}
+			if !file.Verified {
+				t.Error("File was not verified")
+			}
+			if file.Retries < tt.retries {
+				t.Errorf("Retries = %d, want at least %d", file.Retries, tt.retries)
+			}
+		})
+	}
+}
+
+func TestOpenObject(t *testing.T) {
+	content := crawl(500)
+	for _, tt := range downloadCases(t) {
+		t.Run(tt.name, func(t *testing.T) {
+			_, bucket := tt.start(t)
+			file, body, err := bucket.OpenObject(tt.object.Key)
+			if err != nil {
+				t.Fatalf("OpenObject() error = %v", err)
+			}
+			defer body.Close()
+			data, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("reading %s: %v", tt.object.Key, err)
+			}
+			if string(data) != content {
+				t.Errorf("read %d bytes, want the %d bytes stored", len(data), len(content))
+			}
+			if file.Company != "sweetwater" || file.Content != "" {
+				t.Errorf("File = %s with %d bytes of Content, want sweetwater without Content", file.Company, len(file.Content))
+			}
+			reader := body.(readCloser)
+			if checksum, err := reader.Verify(); err != nil || checksum.String() == "" {
+				t.Errorf("Verify() = %v, %v, want a checksum", checksum, err)
+			}
+			if reader.Retries() < tt.retries {
+				t.Errorf("Retries() = %d, want at least %d", reader.Retries(), tt.retries)
+			}
+		})
+	}
+}
+
+func TestDownload(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	content := crawl(50)
+	server.Put(testBucket, s3test.Object{Key: "sweetwater/a.jl", Content: []byte(content)})
+	server.Put(testBucket, s3test.Object{Key: "sweetwater/b.jl.gz", Content: gzipped(t, content)})
+	server.Put(testBucket, s3test.Object{Key: "sweetwater/c.jl", Content: gzipped(t, content), ContentEncoding: "gzip"})
+	server.Put(testBucket, s3test.Object{Key: "sweetwater/error.html", Content: []byte("<html>Not Found</html>")})
+	bucket := newTestBucket(t, server, WithVerify(true), WithConcurrency(4), WithPartSize(1<<10))
+	server.FailRequests(1)
+
+	files, err := bucket.Download()
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Download() returned %d files, want 3", len(files))
+	}
+	for _, file := range files {
+		if file.Content != content || !file.Verified {
+			t.Errorf("%s has %d bytes verified %v, want the %d bytes stored verified", file.Path, len(file.Content), file.Verified, len(content))
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package s3
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Event is an S3 event notification as it is delivered to
+// SQS queues and Lambda functions.
+type Event struct {
+	Records []EventRecord `json:"Records"`
+}
+
+// EventRecord is a single record of an S3 event notification.
+type EventRecord struct {
+	EventSource string    `json:"eventSource"`
+	EventName   string    `json:"eventName"`
+	EventTime   time.Time `json:"eventTime"`
+	S3          EventS3   `json:"s3"`
+}
+
+// EventS3 holds the bucket and object an EventRecord refers to.
+type EventS3 struct {
+	Bucket EventBucket `json:"bucket"`
+	Object EventObject `json:"object"`
+}
+
+type EventBucket struct {
+	Name string `json:"name"`
+}
+
+// EventObject describes the object of an EventRecord. S3 delivers
+// the Key URL encoded, use EventRecord.ObjectKey to decode it.
+type EventObject struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+	ETag string `json:"eTag"`
+}
+
+// snsEnvelope is used to unwrap notifications that reach the
+// queue through an SNS topic rather than directly from S3.
+type snsEnvelope struct {
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+}
+
+// ParseEvent decodes an S3 event notification from a message body.
+// Notifications fanned out through SNS are unwrapped first. Bodies
+// without records, such as the s3:TestEvent S3 sends when a
+// notification is configured, yield an empty Event.
+func ParseEvent(body string) (Event, error) {
+	var envelope snsEnvelope
+	if err := json.Unmarshal([]byte(body), &envelope); err == nil && envelope.Type == "Notification" {
+		body = envelope.Message
+	}
+
+	var event Event
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// IsObjectCreated returns true for any of the s3:ObjectCreated:* events.
+func (r EventRecord) IsObjectCreated() bool {
+	return strings.HasPrefix(r.EventName, "ObjectCreated:")
+}
+
+// ObjectKey returns the decoded object key of the record.
+func (r EventRecord) ObjectKey() (string, error) {
+	return url.QueryUnescape(r.S3.Object.Key)
+}
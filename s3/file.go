@@ -3,38 +3,57 @@ package s3
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"os"
+	"net/url"
 	"time"
 
 	"github.com/chuxorg/chux-parser/errors"
 	"github.com/chuxorg/chux-parser/logging"
+	"github.com/chuxorg/chux-parser/retry"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // The file Struct is used to track the status of a file's
-// parsing and storage in the datastores
+// parsing and storage in the datastores. Its JSON keys are in lower
+// camel case and empty fields are left out of its BSON documents.
 type File struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
 	OwnerID      primitive.ObjectID `bson:"ownerId,omitempty" json:"ownerId,omitempty"`
-	Company      string             `bson:"company, omitempty" json:"company,omitempty"`
-	Content      string             `bson:"content", omitempty" json:"content,omitempty"`
-	LastModified time.Time          `bson:"lastModified", omitempty" json:"lastModified,omitempty"`
-	Size         int64              `bson:"size", omitempty" json:"size,omitempty"`
-	IsProduct    bool               `bson:"isProduct", omitempty" json:"isProduct,omitempty"`
-	IsParsed     bool               `bson:"isParsed", omitempty" json:"isParsed,omitempty"`
-	DateCreated  time.Time          `bson:"dateCreated", omitempty" json:"dateCreated,omitempty"`
-	DateModified time.Time          `bson:"dateModified", omitempty" json:"dateModified,omitempty"`
-	Path         string             `bson:"path", omitempty" json:"path,omitempty"`
-	ArchivedPath string             `bson:"archivedPath", omitempty" json:"archivedPath,omitempty"`
-	Logger       *logging.Logger    `bson:"-" json:"-"`
+	Company      string             `bson:"company,omitempty" json:"company,omitempty"`
+	Content      string             `bson:"content,omitempty" json:"content,omitempty"`
+	LastModified time.Time          `bson:"lastModified,omitempty" json:"lastModified,omitempty"`
+	Size         int64              `bson:"size,omitempty" json:"size,omitempty"`
+	IsProduct    bool               `bson:"isProduct,omitempty" json:"isProduct,omitempty"`
+	IsParsed     bool               `bson:"isParsed,omitempty" json:"isParsed,omitempty"`
+	DateCreated  time.Time          `bson:"dateCreated,omitempty" json:"dateCreated,omitempty"`
+	DateModified time.Time          `bson:"dateModified,omitempty" json:"dateModified,omitempty"`
+	Path         string             `bson:"path,omitempty" json:"path,omitempty"`
+	Format       string             `bson:"format,omitempty" json:"format,omitempty"`
+	ArchivedPath string             `bson:"archivedPath,omitempty" json:"archivedPath,omitempty"`
+	// Charset is the encoding the object was transcoded from, Invalid
+	// the number of sequences that were not valid in it.
+	Charset string `bson:"charset,omitempty" json:"charset,omitempty"`
+	Invalid int    `bson:"invalid,omitempty" json:"invalid,omitempty"`
+	// Checksum is the Checksum of the downloaded object, Verified is set
+	// when it matched the one S3 holds.
+	Checksum string `bson:"checksum,omitempty" json:"checksum,omitempty"`
+	Verified bool   `bson:"verified,omitempty" json:"verified,omitempty"`
+	// Retries counts the repeated requests made to download the File.
+	Retries int `bson:"retries,omitempty" json:"retries,omitempty"`
+	// MongoURI is the connection URI, with the credentials filled in,
+	// of the Database the File is saved to.
+	MongoURI string          `bson:"-" json:"-"`
+	Database string          `bson:"-" json:"-"`
+	Retry    retry.Policy    `bson:"-" json:"-"`
+	Logger   *logging.Logger `bson:"-" json:"-"`
 }
 
 func NewFile(options ...func(*File)) *File {
 
-	file := &File{}
+	file := &File{
+		Retry: retry.Default(),
+	}
 	for _, option := range options {
 		option(file)
 	}
@@ -45,6 +64,19 @@ func NewFile(options ...func(*File)) *File {
 	return file
 }
 
+func FileWithMongo(uri, database string) func(*File) {
+	return func(file *File) {
+		file.MongoURI = uri
+		file.Database = database
+	}
+}
+
+func FileWithRetry(policy retry.Policy) func(*File) {
+	return func(file *File) {
+		file.Retry = policy
+	}
+}
+
 func FileWithLogger(logger *logging.Logger) func(*File) {
 	return func(file *File) {
 		file.Logger = logger
@@ -68,21 +100,14 @@ func (f *File) ToJSON() string {
 func (f *File) Save(files []interface{}) error {
 	logging := f.Logger
 	logging.Debug("File.Save() called")
-	database := os.Getenv("MONGO_DATABASE")
+	database := f.Database
 	collectionName := "files"
-	username := os.Getenv("MONGO_USER_NAME")
-	password := os.Getenv("MONGO_PASSWORD")
-
-	uri := os.Getenv("MONGO_URI")
-	mongoURL := fmt.Sprintf(uri, username, password)
-	uri = mongoURL + os.Getenv("MONGO_DATABASE") + "?retryWrites=true&w=majority"
-
-	maskedURI := fmt.Sprintf(uri, "******", "******") + "?retryWrites=true&w=majority"
-	logging.Info("Saving to MongoDB: %s", maskedURI)
+	uri := f.MongoURI
+	logging.Info("Saving to MongoDB: %s", maskURI(uri))
 
 	client, err := mongo.NewClient(options.Client().ApplyURI(uri))
 	if err != nil {
-		logging.Error("File.Save() error creating new client", err)
+		logging.Error("File.Save() error creating new client: %v", err)
 		return errors.NewChuxParserError("File.Save() Error creating new client", err)
 	}
 
@@ -92,7 +117,7 @@ func (f *File) Save(files []interface{}) error {
 
 	err = client.Connect(ctx)
 	if err != nil {
-		logging.Error("File.Save() error connecting to MongoDB", err)
+		logging.Error("File.Save() error connecting to MongoDB: %v", err)
 		return errors.NewChuxParserError("File.Save() Error connecting to MongoDB", err)
 	}
 
@@ -103,6 +128,7 @@ func (f *File) Save(files []interface{}) error {
 	collection := client.Database(database).Collection(collectionName)
 	logging.Info("Inserting %d files to MongoDB", len(files))
 	cnt := 0
+	policy := f.Retry
 	for _, file := range files {
 		f, ok := file.(File)
 		if !ok {
@@ -110,9 +136,15 @@ func (f *File) Save(files []interface{}) error {
 			continue
 		}
 		f.Content = ""
-		_, err := collection.InsertOne(ctx, f)
+		retries, err := policy.Do(ctx, func(attempt int) error {
+			_, err := collection.InsertOne(ctx, f)
+			return err
+		})
+		if retries > 0 {
+			logging.Warning("File.Save() InsertOne of %s needed %d retries", f.Path, retries)
+		}
 		if err != nil {
-			logging.Error("File.Save() error calling InsertOne to MongoDB", err)
+			logging.Error("File.Save() error calling InsertOne to MongoDB: %v", err)
 			continue
 		}
 		cnt++
@@ -122,3 +154,12 @@ func (f *File) Save(files []interface{}) error {
 
 	return nil
 }
+
+// maskURI returns uri with its password masked, for logs.
+func maskURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "******"
+	}
+	return u.Redacted()
+}
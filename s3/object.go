@@ -0,0 +1,94 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/logging"
+	"github.com/chuxorg/chux-parser/retry"
+)
+
+// objectReader reads the body of an object. When the connection fails
+// mid-stream, the object is requested again from the current offset
+// with a byte range, pinned to the ETag of the first response so a
+// replaced object is not spliced into the old one.
+type objectReader struct {
+	svc    *s3.S3
+	bucket string
+	key    string
+	policy retry.Policy
+	logger *logging.Logger
+
+	body    io.ReadCloser
+	etag    string
+	offset  int64
+	retries int
+}
+
+// open requests the object from the current offset.
+func (o *objectReader) open() (*s3.GetObjectOutput, error) {
+	var output *s3.GetObjectOutput
+	retries, err := o.policy.Do(context.Background(), func(attempt int) error {
+		input := &s3.GetObjectInput{
+			Bucket: aws.String(o.bucket),
+			Key:    aws.String(o.key),
+		}
+		if o.offset == 0 {
+			input.ChecksumMode = aws.String(s3.ChecksumModeEnabled)
+		} else {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", o.offset))
+			input.IfMatch = aws.String(o.etag)
+		}
+		if attempt > 0 {
+			o.logger.Warning("objectReader.open() Retrying %s at offset %d, attempt %d", o.key, o.offset, attempt+1)
+		}
+		var err error
+		output, err = o.svc.GetObject(input)
+		return err
+	})
+	o.retries += retries
+	if err != nil {
+		msg := fmt.Sprintf("Bucket.openObject() Error getting object %s: %v", o.key, err)
+		return nil, errors.NewChuxParserError(msg, err)
+	}
+	if o.offset == 0 {
+		o.etag = aws.StringValue(output.ETag)
+	}
+	o.body = output.Body
+	return output, nil
+}
+
+func (o *objectReader) Read(p []byte) (int, error) {
+	for attempt := 0; ; attempt++ {
+		n, err := o.body.Read(p)
+		o.offset += int64(n)
+		if err == nil || err == io.EOF || !o.policy.ShouldRetry(attempt, err) {
+			return n, err
+		}
+
+		o.logger.Warning("objectReader.Read() Reading %s failed at offset %d: %v", o.key, o.offset, err)
+		o.body.Close()
+		o.retries++
+		time.Sleep(o.policy.Backoff(attempt))
+		if _, err := o.open(); err != nil {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (o *objectReader) Close() error {
+	return o.body.Close()
+}
+
+// Retries returns the number of requests that were repeated.
+func (o *objectReader) Retries() int {
+	return o.retries
+}
@@ -0,0 +1,153 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/logging"
+	"github.com/chuxorg/chux-parser/retry"
+)
+
+// partReader reads a large object with concurrent byte range requests
+// and returns the parts in order, so the records are split exactly as
+// when the object is read over a single connection. At most
+// concurrency parts are requested or held in memory at a time.
+type partReader struct {
+	svc    *s3.S3
+	bucket string
+	key    string
+	etag   string
+	policy retry.Policy
+	logger *logging.Logger
+
+	parts   []chan part
+	slots   chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	current []byte
+	next    int
+	err     error
+	retries int64
+}
+
+type part struct {
+	data []byte
+	err  error
+}
+
+// newPartReader starts the requests for the parts of an object of
+// size bytes with the given ETag. retries counts the requests already
+// repeated for the object, such as its HEAD request.
+func newPartReader(svc *s3.S3, bucket, key, etag string, size, partSize int64, concurrency int, retries int, policy retry.Policy, logger *logging.Logger) *partReader {
+	count := int((size + partSize - 1) / partSize)
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &partReader{
+		svc:     svc,
+		bucket:  bucket,
+		key:     key,
+		etag:    etag,
+		policy:  policy,
+		logger:  logger,
+		parts:   make([]chan part, count),
+		slots:   make(chan struct{}, concurrency),
+		ctx:     ctx,
+		cancel:  cancel,
+		retries: int64(retries),
+	}
+	for i := range r.parts {
+		r.parts[i] = make(chan part, 1)
+	}
+	logger.Debug("partReader Downloading %s in %d parts of %d bytes with %d connections", key, count, partSize, concurrency)
+
+	go func() {
+		for i := range r.parts {
+			// A slot is freed when a part has been read, which bounds
+			// the memory held by parts read ahead.
+			select {
+			case r.slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			start := int64(i) * partSize
+			end := start + partSize - 1
+			if end >= size {
+				end = size - 1
+			}
+			go r.fetch(i, start, end)
+		}
+	}()
+	return r
+}
+
+// fetch requests the bytes from start to end inclusive as part i.
+func (r *partReader) fetch(i int, start, end int64) {
+	var data []byte
+	retries, err := r.policy.Do(r.ctx, func(attempt int) error {
+		if attempt > 0 {
+			r.logger.Warning("partReader.fetch() Retrying part %d of %s, attempt %d", i, r.key, attempt+1)
+		}
+		output, err := r.svc.GetObjectWithContext(r.ctx, &s3.GetObjectInput{
+			Bucket:  aws.String(r.bucket),
+			Key:     aws.String(r.key),
+			Range:   aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+			IfMatch: aws.String(r.etag),
+		})
+		if err != nil {
+			return err
+		}
+		defer output.Body.Close()
+		data, err = ioutil.ReadAll(output.Body)
+		if err == nil && int64(len(data)) != end-start+1 {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	})
+	atomic.AddInt64(&r.retries, int64(retries))
+	if err != nil {
+		msg := fmt.Sprintf("partReader.fetch() Error getting bytes %d-%d of %s: %v", start, end, r.key, err)
+		err = errors.NewChuxParserError(msg, err)
+	}
+	r.parts[i] <- part{data: data, err: err}
+}
+
+func (r *partReader) Read(p []byte) (int, error) {
+	for len(r.current) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.next == len(r.parts) {
+			return 0, io.EOF
+		}
+		if r.next > 0 {
+			<-r.slots
+		}
+		part := <-r.parts[r.next]
+		r.next++
+		if part.err != nil {
+			r.err = part.err
+			r.cancel()
+			return 0, r.err
+		}
+		r.current = part.data
+	}
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	return n, nil
+}
+
+// Close stops the requests of parts not yet read.
+func (r *partReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+// Retries returns the number of part requests that were repeated.
+func (r *partReader) Retries() int {
+	return int(atomic.LoadInt64(&r.retries))
+}
@@ -0,0 +1,285 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/retry"
+)
+
+// The reasons an object is quarantined for.
+const (
+	// ReasonMalformed objects cannot be decompressed or their first
+	// record cannot be read, so their company is unknown.
+	ReasonMalformed = "malformed"
+	// ReasonFailureRate objects have too many records that could not
+	// be decoded or saved.
+	ReasonFailureRate = "failure-rate"
+)
+
+// reportSuffix is appended to the quarantined key for the report.
+const reportSuffix = ".quarantine.json"
+
+// QuarantineReport is the sidecar JSON stored next to a quarantined
+// object, explaining why it was quarantined.
+type QuarantineReport struct {
+	Key           string `json:"key"`
+	QuarantineKey string `json:"quarantineKey"`
+	Reason        string `json:"reason"`
+	Error         string `json:"error,omitempty"`
+	Records       int    `json:"records,omitempty"`
+	Failed        int    `json:"failed,omitempty"`
+	RunID         string `json:"runId,omitempty"`
+	// Moved is set when the object was removed from Key.
+	Moved bool      `json:"moved"`
+	Time  time.Time `json:"time"`
+}
+
+// MalformedError is wrapped by the errors of objects that cannot be
+// read no matter how often they are requested.
+type MalformedError struct {
+	Key string
+	Err error
+}
+
+func (e *MalformedError) Error() string {
+	return fmt.Sprintf("%s is malformed: %v", e.Key, e.Err)
+}
+
+func (e *MalformedError) Unwrap() error {
+	return e.Err
+}
+
+// malformedError returns the error for an object that could not be opened.
+// Errors that may go away on another attempt are returned as they are.
+func malformedError(key, msg string, err error) error {
+	if retry.IsRetryable(err) || stderrors.Is(err, ErrVerification) {
+		return errors.NewChuxParserError(msg, err)
+	}
+	return errors.NewChuxParserError(msg, &MalformedError{Key: key, Err: err})
+}
+
+// malformed is returned by openObject for objects that could not be
+// opened. Malformed objects are quarantined when quarantine is enabled
+// and then skipped like objects of companies that are not parsed.
+func (b *Bucket) malformed(key string, err error) (*File, io.ReadCloser, error) {
+	var malformedErr *MalformedError
+	if b.QuarantinePrefix == "" || !stderrors.As(err, &malformedErr) {
+		return nil, nil, err
+	}
+	report := QuarantineReport{
+		Reason: ReasonMalformed,
+		Error:  err.Error(),
+	}
+	if qErr := b.Quarantine(key, report); qErr != nil {
+		b.Logger.Error("Bucket.malformed() %v", qErr)
+		return nil, nil, err
+	}
+	return nil, nil, nil
+}
+
+// isQuarantineKey reports whether key is below the QuarantinePrefix.
+func (b *Bucket) isQuarantineKey(key string) bool {
+	return b.QuarantinePrefix != "" && strings.HasPrefix(key, b.QuarantinePrefix)
+}
+
+// Quarantine copies the object stored under key below the
+// QuarantinePrefix with report as a sidecar JSON. With QuarantineMove
+// the object is deleted afterwards, otherwise it is tagged as
+// StatusQuarantined and no longer downloaded while its report exists.
+func (b *Bucket) Quarantine(key string, report QuarantineReport) error {
+	if b.QuarantinePrefix == "" {
+		return errors.NewChuxParserError("Bucket.Quarantine() no quarantine prefix configured", nil)
+	}
+	svc, err := b.service()
+	if err != nil {
+		return err
+	}
+	report.Key = key
+	report.QuarantineKey = b.QuarantinePrefix + key
+	report.Moved = b.QuarantineMove
+	report.Time = time.Now().UTC()
+	if report.RunID == "" {
+		report.RunID = b.RunID
+	}
+	b.Logger.Warning("Bucket.Quarantine() Quarantining %s to %s: %s", key, report.QuarantineKey, report.Reason)
+
+	if err := b.copyObject(svc, key, report.QuarantineKey); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(report, "", "  ")
+	if err := b.putObject(svc, report.QuarantineKey+reportSuffix, data, "application/json", nil); err != nil {
+		return err
+	}
+
+	if b.QuarantineMove {
+		return b.deleteObject(svc, key)
+	}
+	status := Status{State: StatusQuarantined, RunID: report.RunID, Failed: report.Failed}
+	if err := b.SetStatus(key, status); err != nil {
+		// The report alone keeps the object from being downloaded.
+		b.Logger.Warning("Bucket.Quarantine() %v", err)
+	}
+	return nil
+}
+
+// Quarantined returns the reports of the quarantined objects.
+func (b *Bucket) Quarantined() ([]QuarantineReport, error) {
+	if b.QuarantinePrefix == "" {
+		return nil, errors.NewChuxParserError("Bucket.Quarantined() no quarantine prefix configured", nil)
+	}
+	svc, err := b.service()
+	if err != nil {
+		return nil, err
+	}
+	objects, err := b.listObjects(svc, b.QuarantinePrefix)
+	if err != nil {
+		return nil, err
+	}
+	var reports []QuarantineReport
+	for _, object := range objects {
+		key := aws.StringValue(object.Key)
+		if !strings.HasSuffix(key, reportSuffix) {
+			continue
+		}
+		report, err := b.report(svc, key)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// Release undoes the quarantine of the object originally stored under
+// key: a moved object is copied back, the quarantined copy and its
+// report are deleted and the status tags are removed, so the object
+// is parsed again by the next run.
+func (b *Bucket) Release(key string) error {
+	if b.QuarantinePrefix == "" {
+		return errors.NewChuxParserError("Bucket.Release() no quarantine prefix configured", nil)
+	}
+	svc, err := b.service()
+	if err != nil {
+		return err
+	}
+	quarantineKey := b.QuarantinePrefix + key
+	report, err := b.report(svc, quarantineKey+reportSuffix)
+	if err != nil {
+		return err
+	}
+	b.Logger.Info("Bucket.Release() Releasing %s", key)
+
+	if report.Moved {
+		if err := b.copyObject(svc, quarantineKey, key); err != nil {
+			return err
+		}
+	} else if err := b.clearStatus(svc, key); err != nil {
+		return err
+	}
+	if err := b.deleteObject(svc, quarantineKey); err != nil {
+		return err
+	}
+	return b.deleteObject(svc, quarantineKey+reportSuffix)
+}
+
+// quarantinedKeys returns the original keys of the quarantined objects
+// among objects, as found by their reports.
+func (b *Bucket) quarantinedKeys(objects []ObjectStatus) map[string]bool {
+	keys := map[string]bool{}
+	for _, object := range objects {
+		if b.isQuarantineKey(object.Key) && strings.HasSuffix(object.Key, reportSuffix) {
+			key := strings.TrimSuffix(strings.TrimPrefix(object.Key, b.QuarantinePrefix), reportSuffix)
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+func (b *Bucket) report(svc *s3.S3, key string) (QuarantineReport, error) {
+	var report QuarantineReport
+	var data []byte
+	_, err := b.Retry.Do(context.Background(), func(attempt int) error {
+		output, err := svc.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(b.Name),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+		defer output.Body.Close()
+		data, err = ioutil.ReadAll(output.Body)
+		return err
+	})
+	if err != nil {
+		msg := fmt.Sprintf("Bucket.report() Error getting quarantine report %s: %v", key, err)
+		return report, errors.NewChuxParserError(msg, err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		msg := fmt.Sprintf("Bucket.report() Error decoding quarantine report %s: %v", key, err)
+		return report, errors.NewChuxParserError(msg, err)
+	}
+	return report, nil
+}
+
+// copyObject copies an object within the Bucket. S3 copies objects of
+// up to 5 GB in a single request.
+func (b *Bucket) copyObject(svc *s3.S3, from, to string) error {
+	_, err := b.Retry.Do(context.Background(), func(attempt int) error {
+		_, err := svc.CopyObject(&s3.CopyObjectInput{
+			Bucket:     aws.String(b.Name),
+			Key:        aws.String(to),
+			CopySource: aws.String((&url.URL{Path: b.Name + "/" + from}).EscapedPath()),
+		})
+		return err
+	})
+	if err != nil {
+		msg := fmt.Sprintf("Bucket.copyObject() Error copying %s to %s: %v", from, to, err)
+		return errors.NewChuxParserError(msg, err)
+	}
+	return nil
+}
+
+func (b *Bucket) putObject(svc *s3.S3, key string, data []byte, contentType string, metadata map[string]string) error {
+	_, err := b.Retry.Do(context.Background(), func(attempt int) error {
+		_, err := svc.PutObject(&s3.PutObjectInput{
+			Bucket:      aws.String(b.Name),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(data),
+			ContentType: aws.String(contentType),
+			Metadata:    aws.StringMap(metadata),
+		})
+		return err
+	})
+	if err != nil {
+		msg := fmt.Sprintf("Bucket.putObject() Error writing %s: %v", key, err)
+		return errors.NewChuxParserError(msg, err)
+	}
+	return nil
+}
+
+func (b *Bucket) deleteObject(svc *s3.S3, key string) error {
+	_, err := b.Retry.Do(context.Background(), func(attempt int) error {
+		_, err := svc.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(b.Name),
+			Key:    aws.String(key),
+		})
+		return err
+	})
+	if err != nil {
+		msg := fmt.Sprintf("Bucket.deleteObject() Error deleting %s: %v", key, err)
+		return errors.NewChuxParserError(msg, err)
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+// Package s3test provides an in-process fake S3 server for running
+// the download and parse pipeline offline. It serves the path style
+// requests of a Bucket configured with the Server's URL, WithPathStyle
+// and WithDisableSSL.
+package s3test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object is an object stored in a Server.
+type Object struct {
+	Key             string
+	Content         []byte
+	ContentEncoding string
+	// ContentType defaults to application/octet-stream.
+	ContentType  string
+	LastModified time.Time
+	// ChecksumAlgorithm is SHA256 or CRC32C for objects uploaded with
+	// an additional checksum, which is returned when requested.
+	ChecksumAlgorithm string
+	Tags              map[string]string
+	// Metadata holds the user metadata of the object by lower case
+	// name, without the x-amz-meta- prefix.
+	Metadata map[string]string
+}
+
+// ETag returns the quoted MD5 of the Object's Content, as S3 does for
+// objects that were not uploaded in parts.
+func (o Object) ETag() string {
+	sum := md5.Sum(o.Content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// Server is a fake S3 server supporting ListObjectsV2, GetObject with
+// byte ranges, HeadObject, PutObject, CopyObject, DeleteObject and
+// object tagging. Any credentials are
+// accepted. Failures can be injected with FailRequests and CutReads.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	buckets map[string]map[string]Object
+	// fail is the number of requests still to fail, cut the number of
+	// object reads still to cut after cutAfter bytes.
+	fail     int
+	cut      int
+	cutAfter int
+}
+
+// NewServer starts a Server. The caller must Close it.
+func NewServer() *Server {
+	s := &Server{buckets: map[string]map[string]Object{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// CreateBucket adds an empty bucket.
+func (s *Server) CreateBucket(bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = map[string]Object{}
+	}
+}
+
+// PutObject stores content under key, creating the bucket if needed.
+func (s *Server) PutObject(bucket, key string, content []byte) {
+	s.Put(bucket, Object{Key: key, Content: content})
+}
+
+// Put stores object, creating the bucket if needed.
+func (s *Server) Put(bucket string, object Object) {
+	if object.LastModified.IsZero() {
+		object.LastModified = time.Now()
+	}
+	s.CreateBucket(bucket)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets[bucket][object.Key] = object
+}
+
+// Object returns the object stored under key.
+func (s *Server) Object(bucket, key string) (Object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.buckets[bucket][key]
+	return object, ok
+}
+
+// FailRequests makes the next n requests fail with 503 SlowDown.
+func (s *Server) FailRequests(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = n
+}
+
+// CutReads makes the next n object reads drop the connection after
+// sending after bytes of the body.
+func (s *Server) CutReads(n, after int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cut = n
+	s.cutAfter = after
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	s.mu.Lock()
+	if s.fail > 0 {
+		s.fail--
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, "SlowDown", "Please reduce your request rate.")
+		return
+	}
+	if s.cut > 0 && key != "" && r.Method == http.MethodGet {
+		s.cut--
+		w = &cutWriter{ResponseWriter: w, remaining: s.cutAfter}
+	}
+	objects, ok := s.buckets[bucket]
+	object, found := objects[key]
+	var list []Object
+	if ok && key == "" {
+		prefix := r.URL.Query().Get("prefix")
+		for _, o := range objects {
+			if strings.HasPrefix(o.Key, prefix) {
+				list = append(list, o)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	_, tagging := r.URL.Query()["tagging"]
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+	case key == "" && r.Method == http.MethodGet:
+		writeList(w, r, bucket, list)
+	case !tagging && r.Method == http.MethodPut:
+		s.putObject(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		s.mu.Lock()
+		delete(objects, key)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case !found:
+		writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+	case tagging && r.Method == http.MethodGet:
+		writeTagging(w, object.Tags)
+	case tagging && r.Method == http.MethodPut:
+		var body taggingBody
+		if err := xml.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "MalformedXML", err.Error())
+			return
+		}
+		object.Tags = map[string]string{}
+		for _, tag := range body.TagSet {
+			object.Tags[tag.Key] = tag.Value
+		}
+		s.Put(bucket, object)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		if match := r.Header.Get("If-Match"); match != "" && match != object.ETag() {
+			writeError(w, http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+			return
+		}
+		w.Header().Set("ETag", object.ETag())
+		if object.ContentEncoding != "" {
+			w = &encodingWriter{ResponseWriter: w, encoding: object.ContentEncoding}
+		}
+		contentType := object.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		for name, value := range object.Metadata {
+			w.Header().Set("X-Amz-Meta-"+name, value)
+		}
+		if r.Header.Get("X-Amz-Checksum-Mode") == "ENABLED" && r.Header.Get("Range") == "" {
+			switch object.ChecksumAlgorithm {
+			case "SHA256":
+				sum := sha256.Sum256(object.Content)
+				w.Header().Set("X-Amz-Checksum-Sha256", base64.StdEncoding.EncodeToString(sum[:]))
+			case "CRC32C":
+				sum := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+				sum.Write(object.Content)
+				w.Header().Set("X-Amz-Checksum-Crc32c", base64.StdEncoding.EncodeToString(sum.Sum(nil)))
+			}
+		}
+		http.ServeContent(w, r, key, object.LastModified, bytes.NewReader(object.Content))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
+}
+
+// putObject stores the body of r under key, or the object named by
+// its x-amz-copy-source header.
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	object := Object{Key: key, ContentEncoding: r.Header.Get("Content-Encoding"), ContentType: r.Header.Get("Content-Type")}
+	for name := range r.Header {
+		if meta := strings.ToLower(name); strings.HasPrefix(meta, "x-amz-meta-") {
+			if object.Metadata == nil {
+				object.Metadata = map[string]string{}
+			}
+			object.Metadata[strings.TrimPrefix(meta, "x-amz-meta-")] = r.Header.Get(name)
+		}
+	}
+	if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+		source, err := url.PathUnescape(source)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidArgument", err.Error())
+			return
+		}
+		fromBucket, fromKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+		from, ok := s.Object(fromBucket, fromKey)
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		object = from
+		object.Key = key
+		object.LastModified = time.Now()
+		s.Put(bucket, object)
+		writeXML(w, http.StatusOK, copyObjectResult{
+			ETag:         object.ETag(),
+			LastModified: object.LastModified.UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	object.Content = content
+	s.Put(bucket, object)
+	w.Header().Set("ETag", object.ETag())
+	w.WriteHeader(http.StatusOK)
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	ETag         string   `xml:"ETag"`
+	LastModified string   `xml:"LastModified"`
+}
+
+// cutWriter aborts the response after remaining bytes of the body.
+type cutWriter struct {
+	http.ResponseWriter
+	remaining int
+}
+
+func (c *cutWriter) Write(p []byte) (int, error) {
+	if len(p) > c.remaining {
+		c.ResponseWriter.Write(p[:c.remaining])
+		c.ResponseWriter.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	c.remaining -= len(p)
+	return c.ResponseWriter.Write(p)
+}
+
+// encodingWriter sets the Content-Encoding header of the response
+// once http.ServeContent has set its Content-Length, which it leaves
+// out for encoded content while S3 sends it.
+type encodingWriter struct {
+	http.ResponseWriter
+	encoding string
+}
+
+func (e *encodingWriter) WriteHeader(status int) {
+	e.Header().Set("Content-Encoding", e.encoding)
+	e.ResponseWriter.WriteHeader(status)
+}
+
+type listBucketResult struct {
+	XMLName     xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name        string         `xml:"Name"`
+	Prefix      string         `xml:"Prefix"`
+	KeyCount    int            `xml:"KeyCount"`
+	MaxKeys     int            `xml:"MaxKeys"`
+	IsTruncated bool           `xml:"IsTruncated"`
+	Contents    []listContents `xml:"Contents"`
+
+	NextContinuationToken string `xml:"NextContinuationToken,omitempty"`
+}
+
+type listContents struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// writeList writes a page of objects. The continuation token is the
+// last key of the previous page.
+func writeList(w http.ResponseWriter, r *http.Request, bucket string, objects []Object) {
+	query := r.URL.Query()
+	maxKeys := 1000
+	if n, err := strconv.Atoi(query.Get("max-keys")); err == nil && n > 0 && n < maxKeys {
+		maxKeys = n
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	if token := query.Get("continuation-token"); token != "" {
+		i := sort.Search(len(objects), func(i int) bool { return objects[i].Key > token })
+		objects = objects[i:]
+	}
+
+	result := listBucketResult{
+		Name:    bucket,
+		Prefix:  query.Get("prefix"),
+		MaxKeys: maxKeys,
+	}
+	if len(objects) > maxKeys {
+		objects = objects[:maxKeys]
+		result.IsTruncated = true
+		result.NextContinuationToken = objects[maxKeys-1].Key
+	}
+	result.KeyCount = len(objects)
+	for _, o := range objects {
+		result.Contents = append(result.Contents, listContents{
+			Key:          o.Key,
+			LastModified: o.LastModified.UTC().Format("2006-01-02T15:04:05.000Z"),
+			ETag:         o.ETag(),
+			Size:         len(o.Content),
+			StorageClass: "STANDARD",
+		})
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+type taggingBody struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  []tag    `xml:"TagSet>Tag"`
+}
+
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+func writeTagging(w http.ResponseWriter, tags map[string]string) {
+	var body taggingBody
+	for key, value := range tags {
+		body.TagSet = append(body.TagSet, tag{Key: key, Value: value})
+	}
+	sort.Slice(body.TagSet, func(i, j int) bool { return body.TagSet[i].Key < body.TagSet[j].Key })
+	writeXML(w, http.StatusOK, body)
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeXML(w, status, errorResponse{Code: code, Message: message})
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprint(w, xml.Header)
+	xml.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,284 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/chuxorg/chux-parser/errors"
+)
+
+// The values of the chux-status tag.
+const (
+	StatusParsed      = "parsed"
+	StatusFailed      = "failed"
+	StatusQuarantined = "quarantined"
+)
+
+// The tags written by SetStatus. Other tags of an object are kept.
+const (
+	tagStatus   = "chux-status"
+	tagRunID    = "chux-run-id"
+	tagProducts = "chux-products"
+	tagArticles = "chux-articles"
+	tagFailed   = "chux-failed"
+)
+
+// maxTags is the number of tags S3 allows on an object.
+const maxTags = 10
+
+// Status is the processing state of an object as recorded in its tags.
+type Status struct {
+	// State is StatusParsed, StatusFailed, StatusQuarantined or empty
+	// for objects that were not processed yet.
+	State    string
+	RunID    string
+	Products int
+	Articles int
+	Failed   int
+}
+
+// StatusFilter selects objects by the State of their Status.
+type StatusFilter struct {
+	// Only, when not empty, selects the objects in one of these States.
+	Only []string
+	// Skip excludes the objects in one of these States.
+	Skip []string
+}
+
+// ParseStatusFilter returns the StatusFilter of comma separated States.
+func ParseStatusFilter(only, skip string) StatusFilter {
+	return StatusFilter{Only: splitStates(only), Skip: splitStates(skip)}
+}
+
+func splitStates(states string) []string {
+	var result []string
+	for _, state := range strings.Split(states, ",") {
+		if state = strings.TrimSpace(state); state != "" {
+			result = append(result, state)
+		}
+	}
+	return result
+}
+
+// IsEmpty reports whether the filter selects every object, in which
+// case no tags need to be read.
+func (f StatusFilter) IsEmpty() bool {
+	return len(f.Only) == 0 && len(f.Skip) == 0
+}
+
+// Match reports whether an object in state is selected.
+func (f StatusFilter) Match(state string) bool {
+	for _, skip := range f.Skip {
+		if state == skip {
+			return false
+		}
+	}
+	if len(f.Only) == 0 {
+		return true
+	}
+	for _, only := range f.Only {
+		if state == only {
+			return true
+		}
+	}
+	return false
+}
+
+// ObjectStatus is an object listed by List.
+type ObjectStatus struct {
+	Key    string
+	Size   int64
+	Status Status
+}
+
+// List returns the objects of the Bucket below prefix selected by
+// filter. The Status of the objects is only read when the filter is
+// not empty.
+func (b *Bucket) List(prefix string, filter StatusFilter) ([]ObjectStatus, error) {
+	svc, err := b.service()
+	if err != nil {
+		return nil, err
+	}
+	objects, err := b.listObjects(svc, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []ObjectStatus
+	for _, object := range objects {
+		listed := ObjectStatus{
+			Key:  aws.StringValue(object.Key),
+			Size: aws.Int64Value(object.Size),
+		}
+		if !filter.IsEmpty() {
+			status, err := b.status(svc, listed.Key)
+			if err != nil {
+				return nil, err
+			}
+			if !filter.Match(status.State) {
+				continue
+			}
+			listed.Status = status
+		}
+		result = append(result, listed)
+	}
+	return result, nil
+}
+
+// listObjects returns every object below prefix, following the pages
+// of the listing.
+func (b *Bucket) listObjects(svc *s3.S3, prefix string) ([]*s3.Object, error) {
+	var objects []*s3.Object
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(b.Name)}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	for {
+		var page *s3.ListObjectsV2Output
+		retries, err := b.Retry.Do(context.Background(), func(attempt int) error {
+			if attempt > 0 {
+				b.Logger.Warning("Bucket.listObjects() Retrying to list objects, attempt %d", attempt+1)
+			}
+			var err error
+			page, err = svc.ListObjectsV2(input)
+			return err
+		})
+		if retries > 0 {
+			b.Logger.Info("Bucket.listObjects() Listing objects needed %d retries", retries)
+		}
+		if err != nil {
+			b.Logger.Error("Bucket.listObjects() Error listing objects: %v", err)
+			return nil, errors.NewChuxParserError(fmt.Sprintf("Bucket.listObjects() Error listing objects: %v", err), err)
+		}
+		objects = append(objects, page.Contents...)
+		if !aws.BoolValue(page.IsTruncated) {
+			return objects, nil
+		}
+		input.ContinuationToken = page.NextContinuationToken
+	}
+}
+
+// Status returns the Status recorded in the tags of the object stored
+// under key.
+func (b *Bucket) Status(key string) (Status, error) {
+	svc, err := b.service()
+	if err != nil {
+		return Status{}, err
+	}
+	return b.status(svc, key)
+}
+
+func (b *Bucket) status(svc *s3.S3, key string) (Status, error) {
+	tags, err := b.tags(svc, key)
+	if err != nil {
+		return Status{}, err
+	}
+	var status Status
+	for _, tag := range tags {
+		value := aws.StringValue(tag.Value)
+		switch aws.StringValue(tag.Key) {
+		case tagStatus:
+			status.State = value
+		case tagRunID:
+			status.RunID = value
+		case tagProducts:
+			status.Products, _ = strconv.Atoi(value)
+		case tagArticles:
+			status.Articles, _ = strconv.Atoi(value)
+		case tagFailed:
+			status.Failed, _ = strconv.Atoi(value)
+		}
+	}
+	return status, nil
+}
+
+// SetStatus records status in the tags of the object stored under key.
+// Tags not written by chux-parser are kept as long as S3's limit of
+// ten tags allows.
+func (b *Bucket) SetStatus(key string, status Status) error {
+	b.Logger.Debug("Bucket.SetStatus() Tagging %s as %s", key, status.State)
+	svc, err := b.service()
+	if err != nil {
+		return err
+	}
+	existing, err := b.tags(svc, key)
+	if err != nil {
+		return err
+	}
+
+	tags := []*s3.Tag{
+		{Key: aws.String(tagStatus), Value: aws.String(status.State)},
+		{Key: aws.String(tagProducts), Value: aws.String(strconv.Itoa(status.Products))},
+		{Key: aws.String(tagArticles), Value: aws.String(strconv.Itoa(status.Articles))},
+		{Key: aws.String(tagFailed), Value: aws.String(strconv.Itoa(status.Failed))},
+	}
+	if status.RunID != "" {
+		tags = append(tags, &s3.Tag{Key: aws.String(tagRunID), Value: aws.String(status.RunID)})
+	}
+	for _, tag := range existing {
+		if len(tags) == maxTags {
+			b.Logger.Warning("Bucket.SetStatus() Dropping tags of %s beyond the limit of %d", key, maxTags)
+			break
+		}
+		if !strings.HasPrefix(aws.StringValue(tag.Key), "chux-") {
+			tags = append(tags, tag)
+		}
+	}
+
+	return b.putTags(svc, key, tags)
+}
+
+// clearStatus removes the tags written by SetStatus from the object
+// stored under key.
+func (b *Bucket) clearStatus(svc *s3.S3, key string) error {
+	existing, err := b.tags(svc, key)
+	if err != nil {
+		return err
+	}
+	tags := []*s3.Tag{}
+	for _, tag := range existing {
+		if !strings.HasPrefix(aws.StringValue(tag.Key), "chux-") {
+			tags = append(tags, tag)
+		}
+	}
+	return b.putTags(svc, key, tags)
+}
+
+// putTags replaces the tags of the object stored under key.
+func (b *Bucket) putTags(svc *s3.S3, key string, tags []*s3.Tag) error {
+	_, err := b.Retry.Do(context.Background(), func(attempt int) error {
+		_, err := svc.PutObjectTagging(&s3.PutObjectTaggingInput{
+			Bucket:  aws.String(b.Name),
+			Key:     aws.String(key),
+			Tagging: &s3.Tagging{TagSet: tags},
+		})
+		return err
+	})
+	if err != nil {
+		msg := fmt.Sprintf("Bucket.putTags() Error tagging %s: %v", key, err)
+		return errors.NewChuxParserError(msg, err)
+	}
+	return nil
+}
+
+// tags returns the tags of the object stored under key.
+func (b *Bucket) tags(svc *s3.S3, key string) ([]*s3.Tag, error) {
+	var output *s3.GetObjectTaggingOutput
+	_, err := b.Retry.Do(context.Background(), func(attempt int) error {
+		var err error
+		output, err = svc.GetObjectTagging(&s3.GetObjectTaggingInput{
+			Bucket: aws.String(b.Name),
+			Key:    aws.String(key),
+		})
+		return err
+	})
+	if err != nil {
+		msg := fmt.Sprintf("Bucket.tags() Error getting tags of %s: %v", key, err)
+		return nil, errors.NewChuxParserError(msg, err)
+	}
+	return output.TagSet, nil
+}
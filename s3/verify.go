@@ -0,0 +1,155 @@
+package s3
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/chuxorg/chux-parser/errors"
+)
+
+// ErrVerification is wrapped by the errors of objects whose size or
+// checksum differs from what S3 reports.
+var ErrVerification = stderrors.New("object failed integrity verification")
+
+// Checksum is the checksum of a downloaded object.
+type Checksum struct {
+	// Algorithm is sha256, crc32c, sha1, crc32 or md5.
+	Algorithm string
+	// Value is encoded as S3 reports it, hex for md5 and base64 for
+	// the additional checksums.
+	Value string
+	// Verified is set when Value matched the checksum S3 holds. Objects
+	// without a usable checksum, e.g. multipart uploads or objects
+	// encrypted with KMS, only have their size verified.
+	Verified bool
+}
+
+func (c Checksum) String() string {
+	if c.Algorithm == "" {
+		return ""
+	}
+	return c.Algorithm + ":" + c.Value
+}
+
+// verifier computes the checksum of an object's body while it is read
+// and compares it and the size with the metadata of the object when
+// the body ends.
+type verifier struct {
+	objectBody
+	key  string
+	size int64
+
+	algorithm string
+	want      string
+	hash      hash.Hash
+	encode    func([]byte) string
+
+	read  int64
+	ended bool
+	err   error
+}
+
+// newVerifier picks the strongest checksum S3 reports for the object
+// described by meta.
+func newVerifier(body objectBody, key string, meta objectMeta) *verifier {
+	v := &verifier{objectBody: body, key: key, size: meta.Size}
+	base64Encode := base64.StdEncoding.EncodeToString
+	checksums := []struct {
+		algorithm string
+		want      string
+		hash      func() hash.Hash
+		encode    func([]byte) string
+	}{
+		{"sha256", meta.ChecksumSHA256, sha256.New, base64Encode},
+		{"crc32c", meta.ChecksumCRC32C, func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) }, base64Encode},
+		{"sha1", meta.ChecksumSHA1, sha1.New, base64Encode},
+		{"crc32", meta.ChecksumCRC32, func() hash.Hash { return crc32.NewIEEE() }, base64Encode},
+		{"md5", etagMD5(meta), md5.New, hex.EncodeToString},
+	}
+	for _, c := range checksums {
+		// Checksums of multipart uploads end in -<parts> and cover the
+		// parts, not the object.
+		if c.want != "" && !strings.Contains(c.want, "-") {
+			v.algorithm, v.want, v.hash, v.encode = c.algorithm, c.want, c.hash(), c.encode
+			return v
+		}
+	}
+	// Without a checksum to compare with, one is still recorded.
+	v.algorithm, v.hash, v.encode = "sha256", sha256.New(), base64Encode
+	return v
+}
+
+// etagMD5 returns the ETag of the object when it is the MD5 of its
+// content, which is not the case for multipart uploads and objects
+// encrypted with KMS or customer keys.
+func etagMD5(meta objectMeta) string {
+	if meta.ServerSideEncryption == "aws:kms" || meta.SSECustomerAlgorithm != "" {
+		return ""
+	}
+	etag := strings.Trim(meta.ETag, `"`)
+	if len(etag) != 32 {
+		return ""
+	}
+	if _, err := hex.DecodeString(etag); err != nil {
+		return ""
+	}
+	return strings.ToLower(etag)
+}
+
+func (v *verifier) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	n, err := v.objectBody.Read(p)
+	v.hash.Write(p[:n])
+	v.read += int64(n)
+	if err == io.EOF {
+		v.ended = true
+		if v.err = v.check(); v.err != nil {
+			return n, v.err
+		}
+	}
+	return n, err
+}
+
+// check compares the size and checksum of the complete body.
+func (v *verifier) check() error {
+	if v.read != v.size {
+		msg := fmt.Sprintf("Bucket: %s has %d bytes, S3 reports %d", v.key, v.read, v.size)
+		return errors.NewChuxParserError(msg, ErrVerification)
+	}
+	if v.want != "" && v.encode(v.hash.Sum(nil)) != v.want {
+		msg := fmt.Sprintf("Bucket: %s has %s %s, S3 reports %s", v.key, v.algorithm, v.encode(v.hash.Sum(nil)), v.want)
+		return errors.NewChuxParserError(msg, ErrVerification)
+	}
+	return nil
+}
+
+// Verify reads what is left of the body, which a decompressor may not
+// have consumed, and returns the checksum of the object or the error
+// of a failed verification.
+func (v *verifier) Verify() (Checksum, error) {
+	if !v.ended && v.err == nil {
+		if _, err := io.Copy(io.Discard, v); err != nil && v.err == nil {
+			msg := fmt.Sprintf("Bucket: Error reading %s for verification: %v", v.key, err)
+			return Checksum{}, errors.NewChuxParserError(msg, err)
+		}
+	}
+	if v.err != nil {
+		return Checksum{}, v.err
+	}
+	return Checksum{
+		Algorithm: v.algorithm,
+		Value:     v.encode(v.hash.Sum(nil)),
+		Verified:  v.want != "",
+	}, nil
+}
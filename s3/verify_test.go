@@ -0,0 +1,58 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/chuxorg/chux-parser/s3/s3test"
+)
+
+// Objects stored with Content-Encoding: gzip are verified as stored
+// and decompressed afterwards.
+func TestVerifyContentEncodingGzip(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	content := crawl(20)
+	server.Put(testBucket, s3test.Object{
+		Key:             "sweetwater/products.jl",
+		Content:         gzipped(t, content),
+		ContentEncoding: "gzip",
+		ContentType:     "application/x-ndjson",
+	})
+	bucket := newTestBucket(t, server)
+
+	files, err := bucket.Download()
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Download() returned %d files, want 1", len(files))
+	}
+	if files[0].Content != content {
+		t.Errorf("Content = %q, want %q", files[0].Content, content)
+	}
+	if !files[0].Verified || files[0].Checksum == "" {
+		t.Errorf("Verified = %v, Checksum = %q, want a verified md5", files[0].Verified, files[0].Checksum)
+	}
+
+	file, err := bucket.DownloadObject("sweetwater/products.jl")
+	if err != nil {
+		t.Fatalf("DownloadObject() error = %v", err)
+	}
+	if file.Content != content || !file.Verified {
+		t.Errorf("DownloadObject() = %q verified %v, want the decompressed content verified", file.Content, file.Verified)
+	}
+}
+
+func TestVerifySHA256(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	server.Put(testBucket, s3test.Object{Key: "sweetwater/products.jl", Content: []byte(crawl(3)), ChecksumAlgorithm: "SHA256"})
+
+	file, err := newTestBucket(t, server).DownloadObject("sweetwater/products.jl")
+	if err != nil {
+		t.Fatalf("DownloadObject() error = %v", err)
+	}
+	if !file.Verified || file.Checksum[:7] != "sha256:" {
+		t.Errorf("Checksum = %q verified %v, want a verified sha256", file.Checksum, file.Verified)
+	}
+}
@@ -0,0 +1,221 @@
+// Package schema validates crawl records against the JSON Schema of
+// their company, so records missing required fields or holding values
+// of the wrong type are found before they reach their model.
+package schema
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/logging"
+	"github.com/chuxorg/chux-parser/pipeline"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// The modes of a Validator.
+const (
+	// Lenient reports violations as pipeline.Issues and keeps the
+	// record.
+	Lenient = "lenient"
+	// Strict rejects records with violations.
+	Strict = "strict"
+)
+
+// Default is the name of the schema of companies without their own.
+const Default = "default"
+
+// The file name suffixes of the schema files of products and articles.
+const (
+	suffix        = ".schema.json"
+	articleSuffix = ".article.schema.json"
+)
+
+// Validator is a pipeline.Stage validating records against the schema
+// file of their company in Dir, e.g. thomann.schema.json for products
+// and thomann.article.schema.json for articles, or else the default
+// schema of their kind. Records without a schema are not validated.
+// The schemas are compiled on first use.
+type Validator struct {
+	Dir    string
+	Mode   string
+	Logger *logging.Logger
+
+	mu      sync.Mutex
+	schemas map[string]*jsonschema.Schema
+}
+
+// New returns a new Validator
+func New(options ...func(*Validator)) *Validator {
+
+	v := &Validator{
+		Mode:    Lenient,
+		schemas: map[string]*jsonschema.Schema{},
+	}
+	for _, option := range options {
+		option(v)
+	}
+	return v
+}
+
+func WithDir(dir string) func(*Validator) {
+	return func(v *Validator) {
+		v.Dir = dir
+	}
+}
+
+func WithMode(mode string) func(*Validator) {
+	return func(v *Validator) {
+		v.Mode = mode
+	}
+}
+
+func WithLogger(logger *logging.Logger) func(*Validator) {
+	return func(v *Validator) {
+		v.Logger = logger
+	}
+}
+
+func (v *Validator) Name() string {
+	return "schema"
+}
+
+// Process validates the Fields of record. Each violation is reported
+// for the field it concerns, in Strict mode the record is rejected
+// when there are any.
+func (v *Validator) Process(record *pipeline.Record) error {
+	name := record.Company + suffix
+	if !record.IsProduct {
+		name = record.Company + articleSuffix
+	}
+	schema, err := v.schema(name)
+	if err != nil || schema == nil {
+		return err
+	}
+
+	err = schema.Validate(record.Fields)
+	if err == nil {
+		return nil
+	}
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return errors.NewChuxParserError(fmt.Sprintf("schema.Process() Error validating record %d of %s: %v", record.Index, record.Key, err), err)
+	}
+	violations := 0
+	for _, leaf := range leaves(validationErr) {
+		message := leaf.Message
+		if isRequired(leaf) {
+			message = "required field is missing"
+		}
+		for _, field := range fields(leaf) {
+			record.Report(field, "%s", message)
+			violations++
+		}
+	}
+	if v.Mode == Strict {
+		return fmt.Errorf("schema violations: %d", violations)
+	}
+	return nil
+}
+
+// schema returns the compiled schema of the file name, or else of the
+// default file of its kind, nil when there is neither.
+func (v *Validator) schema(name string) (*jsonschema.Schema, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if schema, ok := v.schemas[name]; ok {
+		return schema, nil
+	}
+
+	fallback := Default + suffix
+	if strings.HasSuffix(name, articleSuffix) {
+		fallback = Default + articleSuffix
+	}
+	var schema *jsonschema.Schema
+	for _, file := range []string{name, fallback} {
+		path := filepath.Join(v.Dir, file)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		compiled, err := jsonschema.NewCompiler().Compile(path)
+		if err != nil {
+			msg := fmt.Sprintf("schema.schema() Error compiling %s: %v", path, err)
+			return nil, errors.NewChuxParserError(msg, err)
+		}
+		v.Logger.Info("schema.schema() Validating records of %s with %s", name, path)
+		schema = compiled
+		break
+	}
+	v.schemas[name] = schema
+	return schema, nil
+}
+
+// Check compiles every schema file in Dir, so broken schemas are found
+// before any record is parsed.
+func (v *Validator) Check() error {
+	paths, err := filepath.Glob(filepath.Join(v.Dir, "*"+suffix))
+	if err != nil {
+		return errors.NewChuxParserError("schema.Check() Error listing "+v.Dir, err)
+	}
+	for _, path := range paths {
+		if _, err := v.schema(filepath.Base(path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leaves returns the violations err is made of.
+func leaves(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	var result []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		result = append(result, leaves(cause)...)
+	}
+	return result
+}
+
+// isRequired reports whether leaf is a violated required keyword.
+func isRequired(leaf *jsonschema.ValidationError) bool {
+	return strings.HasSuffix(leaf.KeywordLocation, "/required")
+}
+
+// missing matches the properties named in the message of a violated
+// required keyword.
+var missing = regexp.MustCompile(`'((?:[^'\\]|\\.)*)'`)
+
+// index matches the array indices of an instance location.
+var index = regexp.MustCompile(`/\d+(/|$)`)
+
+// fields returns the fields a violation concerns, as a path with array
+// indices replaced by *, e.g. offers/*/price, so violations of the
+// same field in different elements are counted together.
+func fields(leaf *jsonschema.ValidationError) []string {
+	location := leaf.InstanceLocation
+	for index.MatchString(location) {
+		location = index.ReplaceAllString(location, "/*$1")
+	}
+	location = strings.TrimPrefix(location, "/")
+
+	if !isRequired(leaf) {
+		if location == "" {
+			return []string{"$"}
+		}
+		return []string{location}
+	}
+	var result []string
+	for _, match := range missing.FindAllStringSubmatch(leaf.Message, -1) {
+		field := match[1]
+		if location != "" {
+			field = location + "/" + field
+		}
+		result = append(result, field)
+	}
+	return result
+}
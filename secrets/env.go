@@ -0,0 +1,35 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// Env reads secret values from the process environment, e.g. when
+// they are injected by the ECS task definition. With a Prefix only
+// variables starting with it are read, and the prefix is removed from
+// their names.
+type Env struct {
+	Prefix string
+}
+
+func NewEnv(prefix string) *Env {
+	return &Env{Prefix: prefix}
+}
+
+func (p *Env) Name() string {
+	return "env:" + p.Prefix
+}
+
+func (p *Env) Fetch(ctx context.Context) (map[string]string, error) {
+	values := map[string]string{}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(name, p.Prefix) {
+			continue
+		}
+		values[strings.TrimPrefix(name, p.Prefix)] = value
+	}
+	return values, nil
+}
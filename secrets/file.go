@@ -0,0 +1,85 @@
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chuxorg/chux-parser/errors"
+)
+
+// File reads secret values from a local file for development. A file
+// ending in .json holds a flat JSON object, any other file is read in
+// .env notation: KEY=VALUE lines, optionally quoted or prefixed with
+// export, and # comments.
+type File struct {
+	Path string
+}
+
+func NewFile(path string) *File {
+	return &File{Path: path}
+}
+
+func (p *File) Name() string {
+	return "file:" + p.Path
+}
+
+func (p *File) Fetch(ctx context.Context) (map[string]string, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, errors.NewChuxParserError("File.Fetch() failed to read "+p.Path, err)
+	}
+
+	if strings.EqualFold(filepath.Ext(p.Path), ".json") {
+		values, err := decodeJSON(data)
+		if err != nil {
+			return nil, errors.NewChuxParserError("File.Fetch() failed to unmarshal "+p.Path, err)
+		}
+		return values, nil
+	}
+
+	values, err := decodeDotEnv(data)
+	if err != nil {
+		return nil, errors.NewChuxParserError("File.Fetch() failed to read "+p.Path, err)
+	}
+	return values, nil
+}
+
+func decodeDotEnv(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNumber)
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			if value[0] == '"' {
+				unquoted, err := strconv.Unquote(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid quoted value of %s", lineNumber, name)
+				}
+				value = unquoted
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		}
+		values[name] = value
+	}
+	return values, scanner.Err()
+}
@@ -0,0 +1,137 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/chuxorg/chux-parser/errors"
+)
+
+// Provider is a source of secret values such as database credentials.
+// Values are returned to the caller and bound into typed settings with
+// Bind, they are never written to the process environment or logs.
+type Provider interface {
+	// Fetch returns all secret values of the provider by name.
+	Fetch(ctx context.Context) (map[string]string, error)
+	// Name describes the provider for log messages. It never
+	// includes secret values.
+	Name() string
+}
+
+// Names of the available providers, as used by Open.
+const (
+	ProviderSecretsManager = "secretsmanager"
+	ProviderSSM            = "ssm"
+	ProviderFile           = "file"
+	ProviderEnv            = "env"
+)
+
+// Open returns the Provider of the given kind. The meaning of source
+// depends on the kind: the secret id for Secrets Manager, the
+// parameter path for SSM, the file path for a file and an optional
+// name prefix for the environment. The session is used by the AWS
+// providers, a default one is created when it is nil.
+func Open(kind, source string, sess *session.Session) (Provider, error) {
+	switch strings.ToLower(kind) {
+	case ProviderSecretsManager, "":
+		return NewSecretsManager(
+			SecretsManagerWithSecretID(source),
+			SecretsManagerWithSession(sess),
+		), nil
+	case ProviderSSM:
+		return NewSSM(
+			SSMWithPath(source),
+			SSMWithSession(sess),
+		), nil
+	case ProviderFile:
+		return NewFile(source), nil
+	case ProviderEnv:
+		return NewEnv(source), nil
+	default:
+		return nil, errors.NewChuxParserError(fmt.Sprintf("secrets.Open() unknown provider %q", kind), nil)
+	}
+}
+
+// Bind copies values into the fields of the struct target points to.
+// A field receives the value named by its `secret` tag, fields whose
+// value is missing are left unchanged. String, bool, integer and
+// time.Duration fields are supported.
+func Bind(values map[string]string, target interface{}) error {
+	return BindTag(values, "secret", target)
+}
+
+// BindTag works like Bind but names fields with the given struct tag,
+// so settings can be bound from sources other than secrets, such as
+// the environment.
+func BindTag(values map[string]string, tag string, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.NewChuxParserError("secrets.BindTag() target must be a pointer to a struct", nil)
+	}
+	return bindStruct(values, tag, v.Elem())
+}
+
+func bindStruct(values map[string]string, tag string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			if err := bindStruct(values, tag, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		name := field.Tag.Get(tag)
+		if name == "" {
+			continue
+		}
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			// the value is left out of the message on purpose
+			msg := fmt.Sprintf("secrets.BindTag() invalid value for %s", name)
+			return errors.NewChuxParserError(msg, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/chuxorg/chux-parser/errors"
+)
+
+// SecretsManager reads a JSON object of secret values from a single
+// AWS Secrets Manager secret.
+type SecretsManager struct {
+	SecretID string
+	Session  *session.Session
+}
+
+func NewSecretsManager(options ...func(*SecretsManager)) *SecretsManager {
+
+	provider := &SecretsManager{}
+	for _, option := range options {
+		option(provider)
+	}
+	return provider
+}
+
+func SecretsManagerWithSecretID(id string) func(*SecretsManager) {
+	return func(p *SecretsManager) {
+		p.SecretID = id
+	}
+}
+
+func SecretsManagerWithSession(sess *session.Session) func(*SecretsManager) {
+	return func(p *SecretsManager) {
+		p.Session = sess
+	}
+}
+
+func (p *SecretsManager) Name() string {
+	return "secretsmanager:" + p.SecretID
+}
+
+func (p *SecretsManager) Fetch(ctx context.Context) (map[string]string, error) {
+	sess, err := sessionOrDefault(p.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := secretsmanager.New(sess)
+	result, err := svc.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(p.SecretID),
+	})
+	if err != nil {
+		return nil, errors.NewChuxParserError("SecretsManager.Fetch() failed to get secret value of "+p.SecretID, err)
+	}
+
+	values, err := decodeJSON([]byte(aws.StringValue(result.SecretString)))
+	if err != nil {
+		return nil, errors.NewChuxParserError("SecretsManager.Fetch() failed to unmarshal secret "+p.SecretID, err)
+	}
+	return values, nil
+}
+
+// sessionOrDefault returns sess or a new session from the default
+// credential chain when sess is nil.
+func sessionOrDefault(sess *session.Session) (*session.Session, error) {
+	if sess != nil {
+		return sess, nil
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.NewChuxParserError("secrets: failed to create AWS session", err)
+	}
+	return sess, nil
+}
+
+// decodeJSON decodes a flat JSON object of secret values. Numbers and
+// booleans are kept in their JSON notation.
+func decodeJSON(data []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for name, value := range raw {
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			values[name] = s
+			continue
+		}
+		values[name] = string(value)
+	}
+	return values, nil
+}
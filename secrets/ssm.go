@@ -0,0 +1,70 @@
+package secrets
+
+import (
+	"context"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/chuxorg/chux-parser/errors"
+)
+
+// SSM reads every parameter below a path of the AWS SSM Parameter
+// Store. SecureString parameters are decrypted. A parameter is named
+// by the last element of its path, so /chux/dev/MONGO_URI becomes
+// MONGO_URI.
+type SSM struct {
+	Path    string
+	Session *session.Session
+}
+
+func NewSSM(options ...func(*SSM)) *SSM {
+
+	provider := &SSM{}
+	for _, option := range options {
+		option(provider)
+	}
+	return provider
+}
+
+func SSMWithPath(p string) func(*SSM) {
+	return func(provider *SSM) {
+		provider.Path = p
+	}
+}
+
+func SSMWithSession(sess *session.Session) func(*SSM) {
+	return func(provider *SSM) {
+		provider.Session = sess
+	}
+}
+
+func (p *SSM) Name() string {
+	return "ssm:" + p.Path
+}
+
+func (p *SSM) Fetch(ctx context.Context) (map[string]string, error) {
+	sess, err := sessionOrDefault(p.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := ssm.New(sess)
+	values := map[string]string{}
+	input := &ssm.GetParametersByPathInput{
+		Path:           aws.String(p.Path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	}
+	err = svc.GetParametersByPathPagesWithContext(ctx, input, func(page *ssm.GetParametersByPathOutput, lastPage bool) bool {
+		for _, parameter := range page.Parameters {
+			values[path.Base(aws.StringValue(parameter.Name))] = aws.StringValue(parameter.Value)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.NewChuxParserError("SSM.Fetch() failed to get parameters below "+p.Path, err)
+	}
+	return values, nil
+}
@@ -0,0 +1,176 @@
+package taxonomy
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+const (
+	// PropertyCategory is the additional property holding the category
+	// of a product.
+	PropertyCategory = "category"
+	// PropertyCategoryMatch is the additional property holding how the
+	// category was matched, see Match.
+	PropertyCategoryMatch = "categoryMatch"
+	// DefaultThreshold is the similarity of the names matched fuzzily.
+	DefaultThreshold = 0.8
+)
+
+// ignored are the breadcrumbs of the retailers' home pages, left out of
+// their paths.
+var ignored = map[string]bool{"home": true, "homepage": true, "shop": true, "all": true, "all categories": true, "products": true}
+
+// Mapper is a pipeline stage mapping the retailer categories of
+// products onto the Taxonomy, and reporting those not mapped in its
+// file to Report once the products are saved.
+type Mapper struct {
+	Taxonomy *Taxonomy
+	// Threshold is the similarity, between 0 and 1, of the names
+	// matched fuzzily. Above 1 nothing is.
+	Threshold float64
+	Report    Report
+}
+
+// New returns a new Mapper
+func New(options ...func(*Mapper)) *Mapper {
+
+	m := &Mapper{
+		Taxonomy:  &Taxonomy{},
+		Threshold: DefaultThreshold,
+		Report:    NewMemoryReport(),
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+func WithTaxonomy(taxonomy *Taxonomy) func(*Mapper) {
+	return func(m *Mapper) {
+		m.Taxonomy = taxonomy
+	}
+}
+
+func WithThreshold(threshold float64) func(*Mapper) {
+	return func(m *Mapper) {
+		m.Threshold = threshold
+	}
+}
+
+func WithReport(report Report) func(*Mapper) {
+	return func(m *Mapper) {
+		m.Report = report
+	}
+}
+
+func (m *Mapper) Name() string {
+	return "taxonomy"
+}
+
+// Process sets the category of a product, and how it was matched, as
+// additional properties when its breadcrumbs or category fields map
+// onto the Taxonomy. The categoryId of the product is left alone.
+// Products whose path is not mapped in the Taxonomy file are reported.
+func (m *Mapper) Process(record *pipeline.Record) error {
+	if !record.IsProduct {
+		return nil
+	}
+	field, path := Path(record.Fields)
+	if len(path) == 0 {
+		record.Report("breadcrumbs", "no category")
+		return nil
+	}
+	match, ok := m.Taxonomy.Map(record.Company, path, m.Threshold)
+	if ok {
+		record.SetProperty(PropertyCategory, match.Category)
+		record.SetProperty(PropertyCategoryMatch, match.How)
+	}
+	if match.How == MatchMapping {
+		return nil
+	}
+	joined := strings.Join(path, Separator)
+	if !ok {
+		record.Report(field, "unmapped category %q", joined)
+	}
+	url, _ := record.Fields["url"].(string)
+	record.State[m.Name()] = &Unmapped{
+		Key:     record.Company + ":" + key(path),
+		Company: record.Company,
+		Path:    joined,
+		Match:   match.Category,
+		How:     match.How,
+		Example: url,
+	}
+	return nil
+}
+
+// Commit adds the path of a saved product to the Report when it is not
+// mapped in the Taxonomy file.
+func (m *Mapper) Commit(record *pipeline.Record) error {
+	u, ok := record.State[m.Name()].(*Unmapped)
+	if !ok {
+		return nil
+	}
+	u.Updated = time.Now().UTC()
+	return m.Report.Add(context.Background(), *u)
+}
+
+// Path returns the retailer category path of a product and the field
+// it was read from: the names of its breadcrumbs without the home page
+// and the product itself, or else its category or categories field.
+func Path(fields map[string]interface{}) (field string, path []string) {
+	name, _ := fields["name"].(string)
+	if crumbs, ok := fields["breadcrumbs"].([]interface{}); ok {
+		for i, crumb := range crumbs {
+			var text string
+			switch c := crumb.(type) {
+			case string:
+				text = c
+			case map[string]interface{}:
+				text = stringField(c, "name")
+			}
+			text = strings.TrimSpace(text)
+			if text == "" || len(path) == 0 && ignored[strings.ToLower(text)] {
+				continue
+			}
+			if i == len(crumbs)-1 && strings.EqualFold(text, strings.TrimSpace(name)) {
+				continue
+			}
+			path = append(path, text)
+		}
+		if len(path) > 0 {
+			return "breadcrumbs", path
+		}
+	}
+	for _, field := range []string{"category", "categories"} {
+		switch v := fields[field].(type) {
+		case string:
+			path = Split(v)
+		case []interface{}:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					path = append(path, Split(s)...)
+				}
+			}
+		}
+		if len(path) > 0 {
+			return field, path
+		}
+	}
+	return "", nil
+}
+
+// stringField returns the string value of a field, matching its name
+// ignoring case as encoding/json does.
+func stringField(fields map[string]interface{}, name string) string {
+	for k, v := range fields {
+		if strings.EqualFold(k, name) {
+			s, _ := v.(string)
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package taxonomy
+
+import (
+	"context"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"github.com/chuxorg/chux-parser/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultCollection is the MongoDB collection of the Unmapped paths.
+const DefaultCollection = "unmappedCategories"
+
+// MongoReport is a Report counting the Unmapped paths in a MongoDB
+// collection, one document per company and path.
+type MongoReport struct {
+	Client     *mongodb.Client
+	Collection string
+}
+
+// NewMongoReport returns a MongoReport of the collection in the
+// database of client.
+func NewMongoReport(client *mongodb.Client, collection string) *MongoReport {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	return &MongoReport{Client: client, Collection: collection}
+}
+
+func (r *MongoReport) Add(ctx context.Context, u Unmapped) error {
+	ctx, cancel := r.Client.Context(ctx)
+	defer cancel()
+	collection, err := r.Client.Collection(ctx, r.Collection)
+	if err != nil {
+		return err
+	}
+	update := bson.M{
+		"$inc": bson.M{"count": 1},
+		"$set": bson.M{
+			"company": u.Company,
+			"path":    u.Path,
+			"match":   u.Match,
+			"how":     u.How,
+			"example": u.Example,
+			"updated": u.Updated,
+		},
+	}
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": u.Key}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return errors.NewChuxParserError("taxonomy.MongoReport.Add() Error saving "+u.Key, err)
+	}
+	return nil
+}
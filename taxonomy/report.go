@@ -0,0 +1,129 @@
+package taxonomy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Unmapped is a retailer path that is not mapped in the Taxonomy file,
+// with the number of products seen in it.
+type Unmapped struct {
+	Key     string `bson:"_id"`
+	Company string `bson:"company"`
+	Path    string `bson:"path"`
+	// Match is the category the path was matched onto fuzzily or
+	// through its parent, empty when it was not.
+	Match   string `bson:"match,omitempty"`
+	How     string `bson:"how,omitempty"`
+	Count   int    `bson:"count"`
+	Example string `bson:"example,omitempty"`
+	// Updated is when a product was last seen in the path.
+	Updated time.Time `bson:"updated"`
+}
+
+// Report collects the Unmapped retailer paths, so the Taxonomy file
+// can be extended. MongoReport keeps them across runs, MemoryReport
+// within a run.
+type Report interface {
+	// Add counts a product in the Unmapped path of u.
+	Add(ctx context.Context, u Unmapped) error
+}
+
+// MemoryReport is an in-process Report.
+type MemoryReport struct {
+	mu       sync.Mutex
+	unmapped map[string]*Unmapped
+}
+
+func NewMemoryReport() *MemoryReport {
+	return &MemoryReport{unmapped: map[string]*Unmapped{}}
+}
+
+func (r *MemoryReport) Add(ctx context.Context, u Unmapped) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if seen, ok := r.unmapped[u.Key]; ok {
+		seen.Count++
+		seen.Updated = u.Updated
+		return nil
+	}
+	u.Count = 1
+	r.unmapped[u.Key] = &u
+	return nil
+}
+
+// Unmapped returns the Unmapped paths by company, the most frequent
+// first.
+func (r *MemoryReport) Unmapped() []Unmapped {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Unmapped, 0, len(r.unmapped))
+	for _, u := range r.unmapped {
+		list = append(list, *u)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Company != list[j].Company {
+			return list[i].Company < list[j].Company
+		}
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Path < list[j].Path
+	})
+	return list
+}
+
+// WriteFile writes the Unmapped paths to path as the retailers section
+// of a Taxonomy file, each mapped onto the category it was matched
+// onto, if any, and commented with how and the number of products.
+// Nothing is written when there are none.
+func (r *MemoryReport) WriteFile(path string) error {
+	list := r.Unmapped()
+	if len(list) == 0 {
+		return nil
+	}
+	retailers := &yaml.Node{Kind: yaml.MappingNode}
+	var paths *yaml.Node
+	for i, u := range list {
+		if i == 0 || u.Company != list[i-1].Company {
+			paths = &yaml.Node{Kind: yaml.MappingNode}
+			retailers.Content = append(retailers.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: u.Company}, paths)
+		}
+		comment := fmt.Sprintf("%d products", u.Count)
+		if u.Count == 1 {
+			comment = "1 product"
+		}
+		if u.How != "" {
+			comment = u.How + ", " + comment
+		}
+		if u.Example != "" {
+			comment += ", e.g. " + u.Example
+		}
+		paths.Content = append(paths.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: u.Path},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: u.Match, Style: yaml.DoubleQuotedStyle, LineComment: comment},
+		)
+	}
+	doc := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "retailers", HeadComment: "Retailer categories not mapped in the taxonomy file, see -taxonomy-file."},
+		retailers,
+	}}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return errors.NewChuxParserError(fmt.Sprintf("taxonomy.MemoryReport.WriteFile() Error encoding %s: %v", path, err), err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return errors.NewChuxParserError(fmt.Sprintf("taxonomy.MemoryReport.WriteFile() Error writing %s: %v", path, err), err)
+	}
+	return nil
+}
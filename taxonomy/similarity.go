@@ -0,0 +1,85 @@
+package taxonomy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopwords are left out of the names compared.
+var stopwords = map[string]bool{"and": true, "the": true, "of": true, "for": true, "with": true, "all": true}
+
+// words returns the words of a category name as compared: in lower
+// case, without punctuation and stopwords, and singular, so "Effects
+// Pedals & Accessories" and "effect pedal accessory" are the same.
+func words(name string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var result []string
+	for _, field := range fields {
+		if !stopwords[field] {
+			result = append(result, singular(field))
+		}
+	}
+	return result
+}
+
+// singular returns the singular of an English plural, roughly.
+func singular(word string) string {
+	switch {
+	case len(word) <= 3 || strings.HasSuffix(word, "ss") || strings.HasSuffix(word, "us"):
+		return word
+	case strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"), strings.HasSuffix(word, "xes"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "s"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+// similarity returns how similar two names are between 0 and 1, the
+// larger of the Dice coefficients of their words and of the letter
+// pairs of their words run together, so "Solidbody" and "Solid Body"
+// are similar as are "Keyboard" and "Keyboards".
+func similarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	byWords := dice(a, b)
+	byPairs := dice(pairs(strings.Join(a, "")), pairs(strings.Join(b, "")))
+	if byPairs > byWords {
+		return byPairs
+	}
+	return byWords
+}
+
+// pairs returns the pairs of adjacent letters of s.
+func pairs(s string) []string {
+	runes := []rune(s)
+	if len(runes) < 2 {
+		return []string{s}
+	}
+	result := make([]string, 0, len(runes)-1)
+	for i := 0; i < len(runes)-1; i++ {
+		result = append(result, string(runes[i:i+2]))
+	}
+	return result
+}
+
+// dice returns the Dice coefficient of two multisets.
+func dice(a, b []string) float64 {
+	counts := map[string]int{}
+	for _, s := range a {
+		counts[s]++
+	}
+	common := 0
+	for _, s := range b {
+		if counts[s] > 0 {
+			counts[s]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
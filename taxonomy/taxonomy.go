@@ -0,0 +1,212 @@
+package taxonomy
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/chuxorg/chux-parser/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Default names the retailer mappings applying to companies without
+// their own entry, and after their own.
+const Default = "default"
+
+// Separator joins the categories of a path, as in "Guitars > Electric
+// Guitars".
+const Separator = " > "
+
+// split splits a category path at the separators retailers use.
+var split = regexp.MustCompile(`\s*(?:>|»|›|/|\|)\s*`)
+
+// Taxonomy is the canonical category tree and the mapping of retailer
+// categories onto it, read from a YAML file:
+//
+//	categories:
+//	  - Guitars > Electric Guitars > Solid Body
+//	retailers:
+//	  sweetwater:
+//	    Guitars > Solidbody Electric Guitars: Guitars > Electric Guitars > Solid Body
+//	  default:
+//	    Electric Guitars: Guitars > Electric Guitars
+//
+// The parents of the categories are categories too. Retailer paths
+// match ignoring case and separators, their categories are named as
+// chux-parser extracts companies from the record urls.
+type Taxonomy struct {
+	Categories []string                     `yaml:"categories"`
+	Retailers  map[string]map[string]string `yaml:"retailers"`
+
+	// nodes are the canonical categories, the parents included.
+	nodes []node
+	// mappings are the retailer mappings by company and path key.
+	mappings map[string]map[string]string
+}
+
+// node is a canonical category and the words of its name.
+type node struct {
+	path  string
+	depth int
+	words []string
+}
+
+// Load reads the Taxonomy of a YAML file. Retailer categories mapped
+// onto categories missing from the tree are an error.
+func Load(path string) (*Taxonomy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("taxonomy.Load() Error reading %s: %v", path, err), err)
+	}
+	var t Taxonomy
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("taxonomy.Load() Error parsing %s: %v", path, err), err)
+	}
+	if len(t.Categories) == 0 {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("taxonomy.Load() %s holds no categories", path), nil)
+	}
+	if err := t.compile(); err != nil {
+		return nil, errors.NewChuxParserError(fmt.Sprintf("taxonomy.Load() %s: %v", path, err), err)
+	}
+	return &t, nil
+}
+
+// compile builds the nodes and mappings of the Taxonomy.
+func (t *Taxonomy) compile() error {
+	paths := map[string]bool{}
+	for _, category := range t.Categories {
+		parts := Split(category)
+		for i := range parts {
+			paths[strings.Join(parts[:i+1], Separator)] = true
+		}
+	}
+	t.nodes = nil
+	for path := range paths {
+		parts := Split(path)
+		t.nodes = append(t.nodes, node{path: path, depth: len(parts), words: words(parts[len(parts)-1])})
+	}
+	sort.Slice(t.nodes, func(i, j int) bool { return t.nodes[i].path < t.nodes[j].path })
+
+	t.mappings = map[string]map[string]string{}
+	for company, mappings := range t.Retailers {
+		t.mappings[company] = map[string]string{}
+		for from, to := range mappings {
+			category := strings.Join(Split(to), Separator)
+			if !paths[category] {
+				return fmt.Errorf("%s: %q is mapped onto %q, which is not a category", company, from, to)
+			}
+			t.mappings[company][key(Split(from))] = category
+		}
+	}
+	return nil
+}
+
+// Split returns the categories of a path, without empty ones.
+func Split(path string) []string {
+	var parts []string
+	for _, part := range split.Split(path, -1) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// key returns the key of a retailer path in the mappings.
+func key(path []string) string {
+	parts := make([]string, len(path))
+	for i, part := range path {
+		parts[i] = strings.Join(strings.Fields(strings.ToLower(part)), " ")
+	}
+	return strings.Join(parts, Separator)
+}
+
+// How a retailer path was mapped onto a category.
+const (
+	// MatchMapping is a path mapped in the Taxonomy file.
+	MatchMapping = "mapping"
+	// MatchParent is a path whose parent is mapped in the Taxonomy
+	// file, but not the path itself.
+	MatchParent = "parent"
+	// MatchFuzzy is a path whose name is similar to a category.
+	MatchFuzzy = "fuzzy"
+)
+
+// Match is the category a retailer path is mapped onto.
+type Match struct {
+	Category string
+	// How is MatchMapping, MatchParent or MatchFuzzy.
+	How string
+	// Similarity of a fuzzy Match, between 0 and 1.
+	Similarity float64
+}
+
+// Map maps the retailer path of company onto a category. Unless the
+// path is mapped, the deepest of its categories whose name is similar
+// to a category by at least threshold is matched fuzzily, within the
+// category its mapped parent is mapped onto if any. When the deepest
+// category matches nothing, a shallower one may: "Accessories > Guitar
+// Strings" is matched fuzzily onto "Accessories" with a similarity of
+// 1 when no category is named like "Guitar Strings". ok is false when
+// the path cannot be mapped.
+func (t *Taxonomy) Map(company string, path []string, threshold float64) (match Match, ok bool) {
+	var parent string
+	for n := len(path); n > 0 && parent == ""; n-- {
+		category, found := t.lookup(company, key(path[:n]))
+		if !found {
+			continue
+		}
+		if n == len(path) {
+			return Match{Category: category, How: MatchMapping, Similarity: 1}, true
+		}
+		parent = category
+		// Only the categories below the mapped parent are matched
+		// fuzzily.
+		path = path[n:]
+	}
+	if match, ok := t.fuzzy(path, parent, threshold); ok {
+		return match, true
+	}
+	if parent != "" {
+		return Match{Category: parent, How: MatchParent}, true
+	}
+	return Match{}, false
+}
+
+func (t *Taxonomy) lookup(company, key string) (string, bool) {
+	if category, ok := t.mappings[company][key]; ok {
+		return category, true
+	}
+	category, ok := t.mappings[Default][key]
+	return category, ok
+}
+
+// fuzzy returns the category most similar to the deepest category of
+// path matching one, below within if it is not empty. A threshold above
+// 1 matches nothing.
+func (t *Taxonomy) fuzzy(path []string, within string, threshold float64) (Match, bool) {
+	if threshold > 1 {
+		return Match{}, false
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		name := words(path[i])
+		var best *node
+		var score float64
+		for j := range t.nodes {
+			n := &t.nodes[j]
+			if within != "" && !strings.HasPrefix(n.path, within+Separator) {
+				continue
+			}
+			s := similarity(name, n.words)
+			if s > score || s == score && best != nil && n.depth > best.depth {
+				best, score = n, s
+			}
+		}
+		if best != nil && score >= threshold {
+			return Match{Category: best.path, How: MatchFuzzy, Similarity: score}, true
+		}
+	}
+	return Match{}, false
+}
@@ -0,0 +1,107 @@
+package taxonomy
+
+import (
+	"testing"
+
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+func taxonomy(t *testing.T) *Taxonomy {
+	t.Helper()
+	tx := &Taxonomy{
+		Categories: []string{
+			"Guitars > Electric Guitars > Solid Body",
+			"Guitars > Electric Guitars > Hollow Body",
+			"Guitars > Acoustic Guitars",
+			"Accessories > Straps",
+			"Accessories > Cables",
+			"Keyboards",
+		},
+		Retailers: map[string]map[string]string{
+			"sweetwater": {"Guitars > Solidbody Electric Guitars": "Guitars > Electric Guitars > Solid Body"},
+			Default:      {"Electric Guitars": "Guitars > Electric Guitars"},
+		},
+	}
+	if err := tx.compile(); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestMap(t *testing.T) {
+	tx := taxonomy(t)
+	tests := []struct {
+		company    string
+		path       string
+		want       string
+		how        string
+		similarity float64
+	}{
+		{"sweetwater", "Guitars > Solidbody Electric Guitars", "Guitars > Electric Guitars > Solid Body", MatchMapping, 1},
+		{"sweetwater", "guitars/solidbody  electric guitars", "Guitars > Electric Guitars > Solid Body", MatchMapping, 1},
+		{"thomann", "Electric Guitars", "Guitars > Electric Guitars", MatchMapping, 1},
+		{"thomann", "Electric Guitars > Hollowbody", "Guitars > Electric Guitars > Hollow Body", MatchFuzzy, 1},
+		{"thomann", "Electric Guitars > Signature Models", "Guitars > Electric Guitars", MatchParent, 0},
+		{"thomann", "Gear > Strap", "Accessories > Straps", MatchFuzzy, 1},
+		{"thomann", "Keyboard", "Keyboards", MatchFuzzy, 1},
+		// The deepest category matching nothing, its parent is matched
+		// fuzzily and as a whole.
+		{"thomann", "Accessories > Guitar Strings", "Accessories", MatchFuzzy, 1},
+	}
+	for _, tt := range tests {
+		match, ok := tx.Map(tt.company, Split(tt.path), DefaultThreshold)
+		if !ok || match.Category != tt.want || match.How != tt.how || match.Similarity != tt.similarity {
+			t.Errorf("Map(%q, %q) = %+v, %v, want %q, %s, %v", tt.company, tt.path, match, ok, tt.want, tt.how, tt.similarity)
+		}
+	}
+
+	for _, path := range []string{"Drums > Cymbals", "Software"} {
+		if match, ok := tx.Map("thomann", Split(path), DefaultThreshold); ok {
+			t.Errorf("Map(%q) = %+v, want not mapped", path, match)
+		}
+	}
+	if match, ok := tx.Map("thomann", Split("Electric Guitars > Hollowbody"), 1.1); !ok || match.How != MatchParent {
+		t.Errorf("Map() above threshold 1 = %+v, %v, want the mapped parent", match, ok)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	report := NewMemoryReport()
+	m := New(WithTaxonomy(taxonomy(t)), WithReport(report))
+	tests := []struct {
+		fields   map[string]interface{}
+		category string
+		how      string
+		reported bool
+	}{
+		{map[string]interface{}{"breadcrumbs": []interface{}{"Home", "Guitars", "Solidbody Electric Guitars", "Fender Player Stratocaster"}, "name": "Fender Player Stratocaster"}, "Guitars > Electric Guitars > Solid Body", MatchMapping, false},
+		{map[string]interface{}{"category": "Accessories > Guitar Strings"}, "Accessories", MatchFuzzy, true},
+		{map[string]interface{}{"categories": []interface{}{"Drums", "Cymbals"}}, "", "", true},
+	}
+	for _, tt := range tests {
+		record := &pipeline.Record{Company: "sweetwater", IsProduct: true, Fields: tt.fields, State: map[string]interface{}{}}
+		if err := m.Process(record); err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+		if got := record.Property(PropertyCategory); got != tt.category {
+			t.Errorf("%v: %s = %q, want %q", tt.fields, PropertyCategory, got, tt.category)
+		}
+		if got := record.Property(PropertyCategoryMatch); got != tt.how {
+			t.Errorf("%v: %s = %q, want %q", tt.fields, PropertyCategoryMatch, got, tt.how)
+		}
+		for _, field := range []string{"categoryId", "isCategorized"} {
+			if v, ok := record.Fields[field]; ok {
+				t.Errorf("%v: %s set to %v", tt.fields, field, v)
+			}
+		}
+		if err := m.Commit(record); err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+		if _, reported := record.State[m.Name()]; reported != tt.reported {
+			t.Errorf("%v: reported %v, want %v", tt.fields, reported, tt.reported)
+		}
+	}
+	if unmapped := report.Unmapped(); len(unmapped) != 2 {
+		t.Errorf("Unmapped() = %+v, want 2 paths", unmapped)
+	}
+}
@@ -0,0 +1,46 @@
+package urls
+
+import (
+	"github.com/chuxorg/chux-parser/pipeline"
+)
+
+// PropertyOriginalCanonical is the additional property keeping the
+// canonical url a product was scraped with when it was changed.
+const PropertyOriginalCanonical = "originalCanonicalUrl"
+
+func (c *Canonicalizer) Name() string {
+	return "urls"
+}
+
+// Process sets the canonicalUrl of a record to the Canonical form of
+// the one it was scraped with, or else of its url, which is kept as
+// it is. Articles have no canonical url in their model, theirs is
+// only seen by the later stages.
+//
+// The canonicalUrl is the key chux-models upserts products on, so a
+// product stored before the stage was enabled is saved again as a new
+// document. Rewrite the canonicalUrl of the stored products with
+// Canonical before enabling it, the stage then finds them again.
+func (c *Canonicalizer) Process(record *pipeline.Record) error {
+	field := "canonicalUrl"
+	raw, _ := record.Fields[field].(string)
+	scraped := raw
+	if raw == "" {
+		field = "url"
+		raw, _ = record.Fields[field].(string)
+	}
+	if raw == "" {
+		record.Report("url", "no url")
+		return nil
+	}
+	canonical, err := c.Canonical(raw, record.Company)
+	if err != nil {
+		record.Report(field, "%v", err)
+		return nil
+	}
+	if scraped != "" && scraped != canonical && record.IsProduct {
+		record.SetProperty(PropertyOriginalCanonical, scraped)
+	}
+	record.Fields["canonicalUrl"] = canonical
+	return nil
+}
@@ -0,0 +1,182 @@
+// Package urls canonicalises the urls of crawl records, so the same
+// page scraped with tracking parameters, a fragment, a mixed-case host
+// or a trailing slash is recognised as one.
+package urls
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// DefaultParams are the query parameters stripped from every url:
+// analytics, click ids and affiliate tags. A trailing * matches every
+// parameter with the prefix.
+var DefaultParams = []string{
+	"utm_*", "gclid", "gclsrc", "dclid", "gbraid", "wbraid", "fbclid", "msclkid", "yclid",
+	"mc_cid", "mc_eid", "_ga", "_gl", "igshid", "srsltid", "irclickid", "irgwc", "clickid",
+	"affid", "aff_id", "affiliate_id", "partner_id",
+}
+
+// DefaultRetailers are the query parameters stripped from the urls of
+// the retailers using their own in addition to DefaultParams.
+var DefaultRetailers = map[string][]string{
+	"amazon":       {"tag", "ref", "ref_*", "psc", "th", "pd_rd_*", "pf_rd_*", "qid", "sr", "keywords"},
+	"ebay":         {"hash", "_trkparms", "_trksid", "mkcid", "mkevt", "mkrid", "campid", "toolid"},
+	"reverb":       {"bk", "show_sold", "utm_*"},
+	"sweetwater":   {"mrkgadid", "mrkgcl", "mrkgen", "mrkgbflag", "mrkgcat", "acctid", "dsproductgroupid", "dsproductid", "prodSku"},
+	"thomann":      {"offid", "affid", "partner_id", "reviews"},
+	"guitarcenter": {"source", "kwid", "rNtt", "icid"},
+}
+
+// Canonicalizer canonicalises urls, see Canonical.
+type Canonicalizer struct {
+	// Params are stripped from every url.
+	Params []string
+	// Retailers maps companies to the parameters stripped from their
+	// urls in addition.
+	Retailers map[string][]string
+}
+
+// New returns a new Canonicalizer
+func New(options ...func(*Canonicalizer)) *Canonicalizer {
+
+	c := &Canonicalizer{
+		Params:    DefaultParams,
+		Retailers: DefaultRetailers,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithParams adds params to the ones stripped from every url.
+func WithParams(params ...string) func(*Canonicalizer) {
+	return func(c *Canonicalizer) {
+		c.Params = append(append([]string{}, c.Params...), params...)
+	}
+}
+
+// WithRetailer adds params to the ones stripped from the urls of
+// company.
+func WithRetailer(company string, params ...string) func(*Canonicalizer) {
+	return func(c *Canonicalizer) {
+		retailers := make(map[string][]string, len(c.Retailers)+1)
+		for name, stripped := range c.Retailers {
+			retailers[name] = stripped
+		}
+		retailers[company] = append(append([]string{}, retailers[company]...), params...)
+		c.Retailers = retailers
+	}
+}
+
+// Default canonicalises with DefaultParams and DefaultRetailers.
+var Default = New()
+
+// Canonical returns the canonical form of the url raw of company:
+//
+//   - http becomes https, scheme and host are lower case, the host has
+//     no trailing dot and no default port
+//   - the path has no dot segments, repeated or trailing slashes and
+//     only the percent-encoding it needs
+//   - the stripped query parameters are removed and the others sorted
+//   - the fragment is removed
+//
+// When company is empty it is derived from the url, see Company.
+func (c *Canonicalizer) Canonical(raw, company string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" && strings.HasPrefix(raw, "//") {
+		u.Scheme = "https"
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("not an http url: %q", raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("no host in %q", raw)
+	}
+	u.Scheme = "https"
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.User = nil
+
+	if u.Path != "" {
+		cleaned := path.Clean("/" + u.Path)
+		if cleaned == "/" {
+			cleaned = ""
+		}
+		u.Path = cleaned
+	}
+	u.RawPath = ""
+
+	if company == "" {
+		company, _ = Company(u.String())
+	}
+	query := u.Query()
+	for name := range query {
+		if c.stripped(name, company) {
+			query.Del(name)
+		}
+	}
+	// Encode sorts by name and keeps the order of the values.
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String(), nil
+}
+
+// stripped reports whether the query parameter name is stripped from
+// the urls of company. Parameters match case-insensitively.
+func (c *Canonicalizer) stripped(name, company string) bool {
+	name = strings.ToLower(name)
+	for _, params := range [][]string{c.Params, c.Retailers[company]} {
+		for _, param := range params {
+			param = strings.ToLower(param)
+			if strings.HasSuffix(param, "*") {
+				if strings.HasPrefix(name, strings.TrimSuffix(param, "*")) {
+					return true
+				}
+			} else if name == param {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Company returns the company of a url, the label of its host left of
+// the public suffix, e.g. thomann for https://www.thomann.co.uk/, or
+// an empty string when the url has no host.
+func Company(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return "", nil
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		// Hosts without a public suffix, such as localhost.
+		parts := strings.Split(host, ".")
+		if len(parts) >= 2 {
+			return parts[len(parts)-2], nil
+		}
+		return host, nil
+	}
+	name, _, _ := strings.Cut(domain, ".")
+	return name, nil
+}